@@ -14,9 +14,10 @@ import (
 // Client provides a high-level API for communicating with SFP Wizard devices.
 // It wraps the low-level BLE operations and provides typed methods for each endpoint.
 type Client struct {
-	device  bluetooth.Device
-	ctx     *ble.APIContext
-	timeout time.Duration
+	device      bluetooth.Device
+	ctx         *ble.APIContext
+	timeout     time.Duration
+	reconnected bool // device was connected by the client itself
 }
 
 // New creates a new API client for the given BLE device.
@@ -36,9 +37,14 @@ func (c *Client) Connect() error {
 	return nil
 }
 
-// Disconnect releases resources (device disconnect handled separately).
+// Disconnect releases resources. The caller disconnects the device it
+// passed to New; a device the client reconnected to itself (see
+// UpdateFirmware) is disconnected here.
 func (c *Client) Disconnect() {
-	// Currently nothing to do - device.Disconnect() called by caller
+	if c.reconnected {
+		c.device.Disconnect()
+		c.reconnected = false
+	}
 }
 
 // SetTimeout sets the default request timeout.
@@ -215,67 +221,3 @@ func (c *Client) SendBinary(startEndpoint, dataEndpoint string, data []byte) err
 
 	return nil
 }
-
-// FirmwareProgressCallback reports firmware update progress.
-type FirmwareProgressCallback func(phase string, current, total int64)
-
-// UpdateFirmware uploads and installs firmware with progress reporting.
-func (c *Client) UpdateFirmware(data []byte, progress FirmwareProgressCallback) error {
-	if c.ctx == nil {
-		return fmt.Errorf("not connected")
-	}
-
-	// Step 1: Start firmware update
-	startBody := fmt.Sprintf(`{"size":%d}`, len(data))
-	resp, body, err := c.Send("POST", "/fw/start", []byte(startBody), &RequestOptions{Timeout: 10 * time.Second})
-	if err != nil {
-		return fmt.Errorf("failed to start update: %w", err)
-	}
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("start failed: status %d: %s", resp.StatusCode, string(body))
-	}
-
-	// Parse chunk size from response
-	var startResp struct {
-		Chunk int `json:"chunk"`
-	}
-	json.Unmarshal(body, &startResp)
-	chunkSize := 512
-	if startResp.Chunk > 0 {
-		chunkSize = startResp.Chunk
-	}
-
-	// Step 2: Send firmware data in chunks
-	totalSize := int64(len(data))
-	for offset := 0; offset < len(data); offset += chunkSize {
-		end := offset + chunkSize
-		if end > len(data) {
-			end = len(data)
-		}
-		chunk := data[offset:end]
-
-		resp, body, err := c.Send("POST", "/fw/data", chunk, &RequestOptions{
-			Timeout: 30 * time.Second,
-			RawBody: true,
-		})
-		if err != nil {
-			return fmt.Errorf("failed to send chunk at %d: %w", offset, err)
-		}
-		if resp.StatusCode != 200 {
-			return fmt.Errorf("chunk failed: status %d: %s", resp.StatusCode, string(body))
-		}
-
-		if progress != nil {
-			progress("uploading", int64(offset+len(chunk)), totalSize)
-		}
-
-		// Small delay between chunks
-		time.Sleep(20 * time.Millisecond)
-	}
-
-	if progress != nil {
-		progress("installing", totalSize, totalSize)
-	}
-
-	return nil
-}
@@ -1,11 +1,15 @@
 package api
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/vitaminmoo/sfpw-tool/internal/ble"
 	"github.com/vitaminmoo/sfpw-tool/internal/config"
+	"github.com/vitaminmoo/sfpw-tool/internal/firmware"
 )
 
 // FirmwareStartResponse represents the response from POST /fw/start.
@@ -63,3 +67,262 @@ func (c *Client) AbortFirmwareUpdate() error {
 	}
 	return nil
 }
+
+// Firmware update timing
+const (
+	fwPollInterval     = 2 * time.Second
+	fwPollFailures     = 3                // Consecutive failed polls taken as the reboot
+	fwStallTimeout     = 5 * time.Minute  // Install progress must move within this
+	fwSettleTimeout    = 30 * time.Second // Wait for a reboot once installing stops
+	fwReconnectTimeout = 60 * time.Second
+)
+
+// FirmwareUpdateState identifies the phase a firmware update failed in.
+type FirmwareUpdateState int
+
+const (
+	FirmwareUploadFailed  FirmwareUpdateState = iota + 1 // Transfer to the device failed
+	FirmwareInstallFailed                                // Device reported an error or stalled
+	FirmwareDeviceLost                                   // Device did not come back after rebooting
+	FirmwareOldVersion                                   // Device booted its previous version
+	FirmwareWrongVersion                                 // Device booted neither version
+)
+
+func (s FirmwareUpdateState) String() string {
+	switch s {
+	case FirmwareUploadFailed:
+		return "upload failed"
+	case FirmwareInstallFailed:
+		return "install failed"
+	case FirmwareDeviceLost:
+		return "device did not come back"
+	case FirmwareOldVersion:
+		return "booted old version"
+	case FirmwareWrongVersion:
+		return "booted unexpected version"
+	default:
+		return "unknown"
+	}
+}
+
+// ExitCode returns the process exit code for a failure state, counting up
+// from 10 so scripts can tell them apart from ordinary errors.
+func (s FirmwareUpdateState) ExitCode() int {
+	return 9 + int(s)
+}
+
+// FirmwareUpdateError is returned by UpdateFirmware.
+type FirmwareUpdateError struct {
+	State FirmwareUpdateState
+	Err   error
+}
+
+func (e *FirmwareUpdateError) Error() string {
+	return fmt.Sprintf("%s: %v", e.State, e.Err)
+}
+
+func (e *FirmwareUpdateError) Unwrap() error {
+	return e.Err
+}
+
+// ExitCode returns the exit code for the failure state.
+func (e *FirmwareUpdateError) ExitCode() int {
+	return e.State.ExitCode()
+}
+
+// FirmwareProgress reports the phase of a firmware update: "uploading"
+// (Current and Total in bytes), "installing" (percent of 100, with the
+// device's estimate of the time remaining), "rebooting", "reconnecting" and
+// "verifying".
+type FirmwareProgress struct {
+	Phase     string
+	Current   int64
+	Total     int64
+	Remaining time.Duration
+}
+
+// FirmwareProgressCallback reports firmware update progress.
+type FirmwareProgressCallback func(p FirmwareProgress)
+
+// UpdateFirmware uploads and installs firmware, waits for the device to
+// reboot, reconnects to it and checks that it runs the version embedded in
+// the image. It returns the version now running. Failures are reported as
+// *FirmwareUpdateError. After a reconnect the client uses the new
+// connection; call Disconnect to release it.
+func (c *Client) UpdateFirmware(data []byte, progress FirmwareProgressCallback) (string, error) {
+	if c.ctx == nil {
+		return "", fmt.Errorf("not connected")
+	}
+	report := func(p FirmwareProgress) {
+		if progress != nil {
+			progress(p)
+		}
+	}
+
+	expected := ""
+	if img, err := firmware.ParseESP32ImageReader(bytes.NewReader(data)); err == nil {
+		if desc, err := img.AppDescriptor(); err == nil {
+			expected = desc.Version
+		}
+	}
+	previous := ""
+	if status, err := c.GetFirmwareStatus(); err == nil {
+		previous = status.FWVersion
+	}
+
+	if err := c.uploadFirmware(data, report); err != nil {
+		c.AbortFirmwareUpdate()
+		return "", &FirmwareUpdateError{FirmwareUploadFailed, err}
+	}
+	if err := c.waitForReboot(report); err != nil {
+		return "", &FirmwareUpdateError{FirmwareInstallFailed, err}
+	}
+
+	report(FirmwareProgress{Phase: "reconnecting"})
+	if err := c.reconnect(fwReconnectTimeout); err != nil {
+		return "", &FirmwareUpdateError{FirmwareDeviceLost, err}
+	}
+
+	report(FirmwareProgress{Phase: "verifying"})
+	var status *FirmwareStatus
+	var err error
+	for range fwPollFailures {
+		if status, err = c.GetFirmwareStatus(); err == nil {
+			break
+		}
+		time.Sleep(fwPollInterval)
+	}
+	if err != nil {
+		return "", &FirmwareUpdateError{FirmwareDeviceLost, fmt.Errorf("no status after reconnecting: %w", err)}
+	}
+
+	running := status.FWVersion
+	switch {
+	case expected == "" || firmware.SameVersion(running, expected):
+		return running, nil
+	case previous != "" && firmware.SameVersion(running, previous):
+		return running, &FirmwareUpdateError{FirmwareOldVersion, fmt.Errorf("device still runs %s, expected %s", running, expected)}
+	default:
+		return running, &FirmwareUpdateError{FirmwareWrongVersion, fmt.Errorf("device runs %s, expected %s", running, expected)}
+	}
+}
+
+// uploadFirmware sends the image in the chunk size the device asks for.
+func (c *Client) uploadFirmware(data []byte, report FirmwareProgressCallback) error {
+	startResp, err := c.StartFirmwareUpdate(len(data))
+	if err != nil {
+		return fmt.Errorf("failed to start update: %w", err)
+	}
+	chunkSize := 512
+	if startResp.Chunk > 0 {
+		chunkSize = startResp.Chunk
+	}
+	config.Debugf("Using chunk size: %d bytes", chunkSize)
+
+	total := int64(len(data))
+	for offset := 0; offset < len(data); offset += chunkSize {
+		end := min(offset+chunkSize, len(data))
+		if err := c.SendFirmwareChunk(data[offset:end]); err != nil {
+			return fmt.Errorf("failed to send chunk at %d: %w", offset, err)
+		}
+		report(FirmwareProgress{Phase: "uploading", Current: int64(end), Total: total})
+
+		// Small delay between chunks
+		time.Sleep(20 * time.Millisecond)
+	}
+	return nil
+}
+
+// waitForReboot polls the install until the device drops off to reboot.
+// If the install finishes but the device stays up, a reboot is requested.
+func (c *Client) waitForReboot(report FirmwareProgressCallback) error {
+	lastChange := time.Now()
+	lastPercent := -1
+	var idleSince time.Time
+	rebootRequested := false
+	failures := 0
+
+	for {
+		time.Sleep(fwPollInterval)
+
+		status, err := c.GetFirmwareStatus()
+		if err != nil {
+			failures++
+			config.Debugf("Firmware status poll failed (%d): %v", failures, err)
+			if failures >= fwPollFailures || !c.IsConnected() {
+				report(FirmwareProgress{Phase: "rebooting"})
+				return nil
+			}
+			continue
+		}
+		failures = 0
+
+		if installFailed(status.Status) {
+			return fmt.Errorf("device reported status %q", status.Status)
+		}
+		if status.IsUpdating {
+			idleSince = time.Time{}
+			if status.ProgressPercent != lastPercent {
+				lastPercent = status.ProgressPercent
+				lastChange = time.Now()
+			}
+			if time.Since(lastChange) > fwStallTimeout {
+				return fmt.Errorf("stalled at %d%% for %s", lastPercent, fwStallTimeout)
+			}
+			report(FirmwareProgress{
+				Phase:     "installing",
+				Current:   int64(status.ProgressPercent),
+				Total:     100,
+				Remaining: time.Duration(status.RemainingTime) * time.Second,
+			})
+			continue
+		}
+
+		// Not updating any more: the device should reboot by itself
+		if idleSince.IsZero() {
+			idleSince = time.Now()
+			report(FirmwareProgress{Phase: "installing", Current: 100, Total: 100})
+		}
+		if time.Since(idleSince) < fwSettleTimeout {
+			continue
+		}
+		if !installFinished(status.Status) {
+			return fmt.Errorf("update stopped with status %q", status.Status)
+		}
+		if rebootRequested {
+			return fmt.Errorf("device did not reboot after installing")
+		}
+		config.Debugf("Install finished but device is still up, requesting reboot")
+		c.Reboot()
+		rebootRequested = true
+		idleSince = time.Now()
+	}
+}
+
+// reconnect finds the device again after a reboot and sets up a new API
+// context on it.
+func (c *Client) reconnect(timeout time.Duration) error {
+	c.device.Disconnect()
+	device, err := ble.Reconnect(c.device.Address, timeout)
+	if err != nil {
+		return err
+	}
+	ctx, err := ble.NewAPIContext(device)
+	if err != nil {
+		device.Disconnect()
+		return err
+	}
+	c.device = device
+	c.ctx = ctx
+	c.reconnected = true
+	return nil
+}
+
+func installFinished(status string) bool {
+	return status == "finished" || status == "complete"
+}
+
+func installFailed(status string) bool {
+	s := strings.ToLower(status)
+	return strings.Contains(s, "fail") || strings.Contains(s, "error") || strings.Contains(s, "abort")
+}
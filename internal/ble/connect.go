@@ -6,6 +6,7 @@ import (
 	"log"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/vitaminmoo/sfpw-tool/internal/config"
 	"github.com/vitaminmoo/sfpw-tool/internal/protocol"
@@ -71,13 +72,57 @@ func Connect() bluetooth.Device {
 	return device
 }
 
+// Reconnect scans for the device with the given address and connects to it,
+// giving up after timeout. Used to find a device again after it reboots.
+func Reconnect(address bluetooth.Address, timeout time.Duration) (bluetooth.Device, error) {
+	adapter := bluetooth.DefaultAdapter
+	if err := adapter.Enable(); err != nil {
+		return bluetooth.Device{}, fmt.Errorf("failed to enable Bluetooth: %w", err)
+	}
+
+	var found bool
+	timer := time.AfterFunc(timeout, func() { adapter.StopScan() })
+	err := adapter.Scan(func(adapter *bluetooth.Adapter, result bluetooth.ScanResult) {
+		if result.Address.String() == address.String() {
+			found = true
+			adapter.StopScan()
+		}
+	})
+	timer.Stop()
+	if err != nil {
+		return bluetooth.Device{}, fmt.Errorf("scan error: %w", err)
+	}
+	if !found {
+		return bluetooth.Device{}, fmt.Errorf("%s not seen within %s", address.String(), timeout)
+	}
+
+	var device bluetooth.Device
+	for attempt := 1; attempt <= 3; attempt++ {
+		device, err = adapter.Connect(address, bluetooth.ConnectionParams{})
+		if err == nil {
+			return device, nil
+		}
+		config.Debugf("Reconnect attempt %d failed: %v", attempt, err)
+	}
+	return bluetooth.Device{}, fmt.Errorf("failed to connect: %w", err)
+}
+
 // SetupAPI discovers services/characteristics and gets device MAC for API calls
 func SetupAPI(device bluetooth.Device) *APIContext {
+	ctx, err := NewAPIContext(device)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return ctx
+}
+
+// NewAPIContext is SetupAPI returning an error instead of exiting.
+func NewAPIContext(device bluetooth.Device) (*APIContext, error) {
 	config.Debugf("Discovering services...")
 
 	allServices, err := device.DiscoverServices(nil)
 	if err != nil {
-		log.Fatal("Failed to discover services:", err)
+		return nil, fmt.Errorf("failed to discover services: %w", err)
 	}
 
 	// Find primary SFP service
@@ -92,13 +137,13 @@ func SetupAPI(device bluetooth.Device) *APIContext {
 	}
 
 	if sfpService == nil {
-		log.Fatal("SFP service not found")
+		return nil, fmt.Errorf("SFP service not found")
 	}
 
 	// Discover characteristics
 	chars, err := sfpService.DiscoverCharacteristics(nil)
 	if err != nil {
-		log.Fatal("Failed to discover characteristics:", err)
+		return nil, fmt.Errorf("failed to discover characteristics: %w", err)
 	}
 
 	ctx := &APIContext{}
@@ -122,10 +167,10 @@ func SetupAPI(device bluetooth.Device) *APIContext {
 	}
 
 	if ctx.WriteChar == nil {
-		log.Fatal("Write characteristic not found")
+		return nil, fmt.Errorf("write characteristic not found")
 	}
 	if ctx.NotifyChar == nil {
-		log.Fatal("Notify characteristic (d587c47f) not found")
+		return nil, fmt.Errorf("notify characteristic (d587c47f) not found")
 	}
 
 	// Read device info to get MAC address
@@ -142,8 +187,8 @@ func SetupAPI(device bluetooth.Device) *APIContext {
 	}
 
 	if ctx.MAC == "" {
-		log.Fatal("Could not determine device MAC address")
+		return nil, fmt.Errorf("could not determine device MAC address")
 	}
 
-	return ctx
+	return ctx, nil
 }
@@ -1,16 +1,27 @@
 package cli
 
 import (
+	"bytes"
+	"encoding/csv"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/vitaminmoo/sfpw-tool/internal/ble"
 	"github.com/vitaminmoo/sfpw-tool/internal/commands"
 	"github.com/vitaminmoo/sfpw-tool/internal/config"
+	"github.com/vitaminmoo/sfpw-tool/internal/eeprom"
 	"github.com/vitaminmoo/sfpw-tool/internal/firmware"
+	"github.com/vitaminmoo/sfpw-tool/internal/flash"
 	"github.com/vitaminmoo/sfpw-tool/internal/store"
 	"github.com/vitaminmoo/sfpw-tool/internal/tui"
 )
@@ -25,9 +36,12 @@ type CLI struct {
 	Device   DeviceCmd   `cmd:"" help:"Device info and control"`
 	Module   ModuleCmd   `cmd:"" help:"SFP module operations"`
 	Snapshot SnapshotCmd `cmd:"" help:"Snapshot buffer operations"`
+	Clone    CloneCmd    `cmd:"" help:"Clone profiles onto modules"`
 	Fw       FwCmd       `cmd:"" help:"Firmware operations"`
 	Support  SupportCmd  `cmd:"" help:"Support and diagnostics"`
 	Store    StoreCmd    `cmd:"" help:"Module profile store"`
+	Eeprom   EepromCmd   `cmd:"" help:"Offline EEPROM tools"`
+	Flash    FlashCmd    `cmd:"" help:"Offline tools for raw flash dumps"`
 	Debug    DebugCmd    `cmd:"" help:"Debug and development tools"`
 
 	// Legacy commands for backwards compatibility (hidden)
@@ -172,9 +186,10 @@ func (c *DeviceChargeCtrlCmd) Run(globals *CLI) error {
 // --- Module Commands ---
 
 type ModuleCmd struct {
-	Info ModuleInfoCmd `cmd:"" help:"Get details about the inserted SFP module"`
-	Read ModuleReadCmd `cmd:"" help:"Read EEPROM from physical module to file"`
-	Ddm  ModuleDdmCmd  `cmd:"" help:"Read DDM (Digital Diagnostic Monitoring) data"`
+	Info  ModuleInfoCmd  `cmd:"" help:"Get details about the inserted SFP module"`
+	Read  ModuleReadCmd  `cmd:"" help:"Read EEPROM from physical module to file"`
+	Ddm   ModuleDdmCmd   `cmd:"" help:"Read DDM (Digital Diagnostic Monitoring) data"`
+	Watch ModuleWatchCmd `cmd:"" help:"Watch for inserted modules and capture each into the store"`
 }
 
 type ModuleInfoCmd struct{}
@@ -209,6 +224,25 @@ func (c *ModuleDdmCmd) Run(globals *CLI) error {
 	return nil
 }
 
+type ModuleWatchCmd struct {
+	Log     string        `help:"Append events to this JSONL inventory log"`
+	Exec    string        `help:"Shell command to run for each new module (event JSON on stdin)"`
+	Webhook string        `help:"URL to POST the event JSON to for each new module"`
+	Poll    time.Duration `help:"Poll interval" default:"1s"`
+}
+
+func (c *ModuleWatchCmd) Run(globals *CLI) error {
+	config.Verbose = globals.Verbose
+	device := ble.Connect()
+	defer device.Disconnect()
+	return commands.ModuleWatch(device, commands.ModuleWatchOptions{
+		PollInterval: c.Poll,
+		LogPath:      c.Log,
+		Exec:         c.Exec,
+		Webhook:      c.Webhook,
+	})
+}
+
 // --- Snapshot Commands ---
 
 type SnapshotCmd struct {
@@ -241,60 +275,50 @@ func (c *SnapshotReadCmd) Run(globals *CLI) error {
 }
 
 type SnapshotWriteCmd struct {
-	FileOrProfile string `arg:"" help:"EEPROM file path or store profile hash"`
+	FileOrProfile string        `arg:"" help:"EEPROM file path or store profile hash (hash@variant selects a variant)"`
+	Variant       string        `help:"Store profile variant to write (full-content hash prefix, see store show)"`
+	Force         bool          `help:"Write even if the EEPROM fails lint checks"`
+	Verify        bool          `help:"Wait for the module to be written, then read it back and compare"`
+	Timeout       time.Duration `help:"How long to wait for the module to change when verifying" default:"2m"`
 }
 
 func (c *SnapshotWriteCmd) Run(globals *CLI) error {
 	config.Verbose = globals.Verbose
 
-	// Check if it's a file path or a store profile hash
-	filePath := c.FileOrProfile
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		// Not a file, try to find in store
-		s, err := store.OpenDefault()
-		if err != nil {
-			return fmt.Errorf("failed to open store: %w", err)
-		}
-
-		// Look for matching profile hash
-		profiles, err := s.ListWithHashes()
-		if err != nil {
-			return fmt.Errorf("failed to list profiles: %w", err)
-		}
-
-		var fullHash string
-		for hash := range profiles {
-			if hash == c.FileOrProfile || store.ShortHash(hash) == c.FileOrProfile || hash[7:] == c.FileOrProfile {
-				fullHash = hash
-				break
-			}
-		}
-
-		if fullHash == "" {
-			return fmt.Errorf("not found: %s (not a file or store profile)", c.FileOrProfile)
+	ref := c.FileOrProfile
+	if c.Variant != "" {
+		if _, err := os.Stat(ref); err == nil {
+			return fmt.Errorf("--variant only applies to store profiles, not files")
 		}
-
-		// Export to temp file
-		tmpFile, err := os.CreateTemp("", "sfpw-eeprom-*.bin")
-		if err != nil {
-			return fmt.Errorf("failed to create temp file: %w", err)
+		if strings.Contains(ref, "@") {
+			return fmt.Errorf("give the variant either as hash@variant or with --variant, not both")
 		}
-		tmpPath := tmpFile.Name()
-		tmpFile.Close()
-		defer os.Remove(tmpPath)
+		ref += "@" + c.Variant
+	}
+	data, name, err := loadEEPROM(ref)
+	if err != nil {
+		return err
+	}
 
-		if err := s.Export(fullHash, tmpPath); err != nil {
-			return fmt.Errorf("failed to export profile: %w", err)
+	findings := eeprom.Lint(data)
+	if len(findings) > 0 {
+		fmt.Println("Lint findings:")
+		printLintFindings(findings)
+		fmt.Println()
+	}
+	if eeprom.HasErrors(findings) {
+		if !c.Force {
+			return fmt.Errorf("EEPROM failed lint checks (use --force to write anyway)")
 		}
-
-		entry := profiles[fullHash]
-		fmt.Printf("Using store profile: %s (%s %s)\n", store.ShortHash(fullHash), entry.VendorName, entry.PartNumber)
-		filePath = tmpPath
+		fmt.Println("Continuing despite lint errors (--force)")
 	}
 
 	device := ble.Connect()
 	defer device.Disconnect()
-	commands.SnapshotWrite(device, filePath)
+	if c.Verify {
+		return commands.SnapshotWriteVerify(device, data, name, c.Timeout)
+	}
+	commands.SnapshotWriteData(device, data, name)
 	return nil
 }
 
@@ -311,16 +335,183 @@ func (c *SnapshotRecoverCmd) Run(globals *CLI) error {
 	return nil
 }
 
+// --- Clone Commands ---
+
+type CloneCmd struct {
+	Batch CloneBatchCmd `cmd:"" help:"Write a profile to a series of modules with sequential serial numbers"`
+}
+
+type CloneBatchCmd struct {
+	From          string        `help:"Source EEPROM file path or store profile hash" required:""`
+	SerialPattern string        `help:"Serial number template, e.g. ABC{seq:05}" required:""`
+	Start         int           `help:"First sequence number" default:"1"`
+	Count         int           `help:"Number of modules to provision (0 = until stopped)" default:"0"`
+	Log           string        `help:"CSV run log (used to resume interrupted runs)" default:"clone-log.csv"`
+	Poll          time.Duration `help:"Module insertion poll interval" default:"1s"`
+	Force         bool          `help:"Clone even if the source EEPROM fails lint checks"`
+}
+
+func (c *CloneBatchCmd) Run(globals *CLI) error {
+	config.Verbose = globals.Verbose
+
+	data, name, err := loadEEPROM(c.From)
+	if err != nil {
+		return err
+	}
+
+	findings := eeprom.Lint(data)
+	if len(findings) > 0 {
+		fmt.Println("Lint findings:")
+		printLintFindings(findings)
+		fmt.Println()
+	}
+	if eeprom.HasErrors(findings) && !c.Force {
+		return fmt.Errorf("source EEPROM failed lint checks (use --force to clone anyway)")
+	}
+
+	device := ble.Connect()
+	defer device.Disconnect()
+	return commands.CloneBatch(device, commands.CloneBatchOptions{
+		Source:        data,
+		SourceName:    name,
+		SerialPattern: c.SerialPattern,
+		Start:         c.Start,
+		Count:         c.Count,
+		LogPath:       c.Log,
+		PollInterval:  c.Poll,
+	})
+}
+
 // --- Firmware Commands ---
 
 type FwCmd struct {
 	Status   FwStatusCmd   `cmd:"" help:"Get detailed firmware status"`
+	Check    FwCheckCmd    `cmd:"" help:"Check whether newer firmware is available for the device"`
 	Update   FwUpdateCmd   `cmd:"" help:"Upload and install firmware (from file or downloaded version)"`
 	Abort    FwAbortCmd    `cmd:"" help:"Abort an in-progress firmware update"`
 	Download FwDownloadCmd `cmd:"" help:"Download all available firmware versions from the internet"`
 	List     FwListCmd     `cmd:"" help:"List downloaded firmware files"`
 	Path     FwPathCmd     `cmd:"" help:"Show firmware storage directory path"`
 	Passdb   FwPassdbCmd   `cmd:"" help:"Extract password database from firmware image"`
+	Inspect  FwInspectCmd  `cmd:"" help:"Verify a firmware image and show its header and app description"`
+	Diff     FwDiffCmd     `cmd:"" help:"Compare two firmware images"`
+	Elf      FwElfCmd      `cmd:"" name:"elf" help:"Convert a firmware image to ELF for disassemblers"`
+	Gpio     FwGpioCmd     `cmd:"" name:"gpio" help:"Extract the GPIO configuration table from firmware"`
+	Cache    FwCacheCmd    `cmd:"" help:"Manage downloaded firmware files"`
+
+	ManifestURL string `help:"Firmware manifest API URL, e.g. a local mirror" env:"SFPW_MANIFEST_URL" placeholder:"URL"`
+}
+
+// manifestClient returns a client for the configured manifest API.
+func (c *FwCmd) manifestClient() *firmware.ManifestClient {
+	if c.ManifestURL != "" {
+		return firmware.NewManifestClientAt(c.ManifestURL)
+	}
+	return firmware.NewManifestClient()
+}
+
+// fetchManifest queries the manifest API and saves the result for offline
+// use.
+func (c *FwCmd) fetchManifest(fwStore *firmware.FirmwareStore, filter firmware.ManifestFilter) ([]firmware.FirmwareVersion, error) {
+	client := c.manifestClient()
+	versions, err := client.GetAvailable(filter)
+	if err != nil {
+		return nil, err
+	}
+	saved := &firmware.SavedManifest{Fetched: time.Now(), URL: client.BaseURL(), Filter: filter, Versions: versions}
+	if err := fwStore.SaveManifest(saved); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save manifest: %v\n", err)
+	}
+	return versions, nil
+}
+
+// availableFirmware fetches the manifest for a channel, falling back to the
+// saved one if the manifest API cannot be reached.
+func (c *FwCmd) availableFirmware(fwStore *firmware.FirmwareStore, channel string) ([]firmware.FirmwareVersion, error) {
+	versions, err := c.fetchManifest(fwStore, firmware.SFPWizardFilter(channel))
+	if err == nil {
+		return versions, nil
+	}
+	saved, loadErr := fwStore.LoadManifest(channel)
+	if loadErr != nil || saved == nil {
+		return nil, err
+	}
+	fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	fmt.Fprintf(os.Stderr, "Using %s manifest saved %s\n", channel, saved.Fetched.Format("2006-01-02 15:04"))
+	firmware.SortVersionsNewestFirst(saved.Versions)
+	return saved.Versions, nil
+}
+
+// latestFirmware returns the newest firmware in a channel.
+func (c *FwCmd) latestFirmware(fwStore *firmware.FirmwareStore, channel string) (*firmware.FirmwareVersion, error) {
+	versions, err := c.availableFirmware(fwStore, channel)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("no firmware available in the %s channel", channel)
+	}
+	return &versions[0], nil
+}
+
+type FwCheckCmd struct {
+	Channel string `help:"Release channel to check" enum:"release,beta,rc" default:"release"`
+	JSON    bool   `help:"Output as JSON" short:"j"`
+}
+
+func (c *FwCheckCmd) Run(globals *CLI) error {
+	config.Verbose = globals.Verbose
+
+	fwStore, err := firmware.NewFirmwareStore()
+	if err != nil {
+		return err
+	}
+	latest, err := globals.Fw.latestFirmware(fwStore, c.Channel)
+	if err != nil {
+		return err
+	}
+
+	device := ble.Connect()
+	defer device.Disconnect()
+	status, err := commands.InstalledFirmware(device)
+	if err != nil {
+		return err
+	}
+
+	available := firmware.CompareVersions(latest.Version, status.FWVersion) > 0
+	downloaded := fwStore.Has(latest.Version, latest.SHA256)
+
+	if c.JSON {
+		data, _ := json.MarshalIndent(struct {
+			Installed       string                    `json:"installed"`
+			Channel         string                    `json:"channel"`
+			Latest          *firmware.FirmwareVersion `json:"latest"`
+			UpdateAvailable bool                      `json:"update_available"`
+			Downloaded      bool                      `json:"downloaded"`
+		}{status.FWVersion, c.Channel, latest, available, downloaded}, "", "  ")
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("Installed:      v%s\n", strings.TrimPrefix(status.FWVersion, "v"))
+	fmt.Printf("Latest %-8s v%s (%s, released %s)\n", c.Channel+":",
+		strings.TrimPrefix(latest.Version, "v"), humanizeBytes(latest.FileSize), latest.Created.Format("2006-01-02"))
+	if !available {
+		fmt.Println("\nFirmware is up to date.")
+		return nil
+	}
+
+	fmt.Printf("\nUpdate available: %s to download", humanizeBytes(latest.FileSize))
+	if downloaded {
+		fmt.Print(" (already downloaded)")
+	}
+	fmt.Println()
+	if c.Channel == firmware.ChannelRelease {
+		fmt.Println("Install with: sfpw fw update --latest")
+	} else {
+		fmt.Printf("Install with: sfpw fw update --latest --channel %s\n", c.Channel)
+	}
+	return nil
 }
 
 type FwStatusCmd struct{}
@@ -334,46 +525,95 @@ func (c *FwStatusCmd) Run(globals *CLI) error {
 }
 
 type FwUpdateCmd struct {
-	FileOrVersion string `arg:"" help:"Firmware file path or downloaded version (e.g., v1.1.1)"`
+	FileOrVersion string `arg:"" optional:"" help:"Firmware file path or downloaded version (e.g., v1.1.1)"`
+	Latest        bool   `help:"Download and install the newest firmware in the channel"`
+	Channel       string `help:"Release channel for --latest" enum:"release,beta,rc" default:"release"`
+	Force         bool   `help:"Flash even if the image is for another project or chip, or is a downgrade"`
 }
 
 func (c *FwUpdateCmd) Run(globals *CLI) error {
 	config.Verbose = globals.Verbose
 
-	// Check if it's a file path or a version string
-	filePath := c.FileOrVersion
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		// Not a file, try to find in firmware store
-		store, err := firmware.NewFirmwareStore()
-		if err != nil {
-			return fmt.Errorf("failed to open firmware store: %w", err)
-		}
+	if c.Latest == (c.FileOrVersion != "") {
+		return fmt.Errorf("give either a firmware file or version, or --latest")
+	}
+	if c.Latest {
+		return c.runLatest(globals)
+	}
 
-		// Look for matching version
-		entries, err := store.List()
-		if err != nil {
-			return fmt.Errorf("failed to list firmware: %w", err)
-		}
+	filePath, err := resolveFirmware(c.FileOrVersion)
+	if err != nil {
+		return err
+	}
 
-		version := c.FileOrVersion
-		// Try with and without 'v' prefix
-		for _, e := range entries {
-			if e.Version == version || e.Version == "v"+version || "v"+e.Version == version {
-				filePath = e.Path
-				fmt.Printf("Using downloaded firmware: %s\n", e.Version)
-				break
-			}
-		}
+	device := ble.Connect()
+	defer device.Disconnect()
+	return commands.FirmwareUpdate(device, filePath, c.Force)
+}
 
-		if filePath == c.FileOrVersion {
-			return fmt.Errorf("firmware not found: %s (not a file or downloaded version)", c.FileOrVersion)
-		}
+// runLatest downloads the newest firmware in the channel unless it is
+// already cached, and installs it if it is newer than the device's.
+func (c *FwUpdateCmd) runLatest(globals *CLI) error {
+	fwStore, err := firmware.NewFirmwareStore()
+	if err != nil {
+		return err
+	}
+	latest, err := globals.Fw.latestFirmware(fwStore, c.Channel)
+	if err != nil {
+		return err
 	}
+	fmt.Printf("Latest %s firmware: v%s\n", c.Channel, strings.TrimPrefix(latest.Version, "v"))
 
 	device := ble.Connect()
 	defer device.Disconnect()
-	commands.FirmwareUpdate(device, filePath)
-	return nil
+	status, err := commands.InstalledFirmware(device)
+	if err != nil {
+		return err
+	}
+	if firmware.CompareVersions(latest.Version, status.FWVersion) <= 0 && !c.Force {
+		fmt.Printf("Device already runs v%s, nothing to do.\n", strings.TrimPrefix(status.FWVersion, "v"))
+		return nil
+	}
+
+	filePath := fwStore.Get(latest.Version, latest.SHA256)
+	if filePath == "" {
+		fmt.Printf("Downloading %s...", humanizeBytes(latest.FileSize))
+		progressBar := &CLIProgressBar{width: 30}
+		filePath, err = fwStore.Download(*latest, func(current, total int64, desc string) {
+			progressBar.Update(current, total, "")
+		})
+		progressBar.Complete()
+		if err != nil {
+			return fmt.Errorf("failed to download firmware: %w", err)
+		}
+	}
+
+	return commands.FirmwareUpdate(device, filePath, c.Force)
+}
+
+// resolveFirmware returns the path of a firmware file, or of the downloaded
+// firmware for a version (with or without the "v" prefix).
+func resolveFirmware(fileOrVersion string) (string, error) {
+	if _, err := os.Stat(fileOrVersion); err == nil {
+		return fileOrVersion, nil
+	}
+
+	store, err := firmware.NewFirmwareStore()
+	if err != nil {
+		return "", fmt.Errorf("failed to open firmware store: %w", err)
+	}
+	entries, err := store.List()
+	if err != nil {
+		return "", fmt.Errorf("failed to list firmware: %w", err)
+	}
+
+	for _, e := range entries {
+		if firmware.SameVersion(e.Version, fileOrVersion) {
+			fmt.Fprintf(os.Stderr, "Using downloaded firmware: %s\n", e.Version)
+			return e.Path, nil
+		}
+	}
+	return "", fmt.Errorf("firmware not found: %s (not a file or downloaded version)", fileOrVersion)
 }
 
 type FwAbortCmd struct{}
@@ -399,18 +639,43 @@ func (c *FwListCmd) Run(globals *CLI) error {
 		return err
 	}
 
+	manifest, err := store.LoadManifest(firmware.ChannelRelease)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+
 	if len(entries) == 0 {
 		fmt.Println("No firmware files downloaded.")
 		fmt.Println("Download firmware with: sfpw fw download")
-		return nil
+	} else {
+		firmware.SortEntriesNewestFirst(entries)
+		fmt.Printf("Downloaded firmware files (%d):\n\n", len(entries))
+		for _, e := range entries {
+			fmt.Printf("  %-12s  %-10s  %s\n",
+				e.Version,
+				humanizeBytes(e.FileSize),
+				e.Downloaded.Format("2006-01-02 15:04"))
+		}
 	}
 
-	fmt.Printf("Downloaded firmware files (%d):\n\n", len(entries))
-	for _, e := range entries {
-		fmt.Printf("  %-12s  %-10s  %s\n",
-			e.Version,
-			humanizeBytes(e.FileSize),
-			e.Downloaded.Format("2006-01-02 15:04"))
+	// Versions known from the last manifest fetch but not downloaded
+	if manifest != nil {
+		downloaded := make(map[string]bool)
+		for _, e := range entries {
+			downloaded[e.Version] = true
+		}
+		var missing []firmware.FirmwareVersion
+		for _, v := range manifest.Versions {
+			if !downloaded[v.Version] {
+				missing = append(missing, v)
+			}
+		}
+		if len(missing) > 0 {
+			fmt.Printf("\nAvailable, not downloaded (manifest from %s):\n\n", manifest.Fetched.Format("2006-01-02 15:04"))
+			for _, v := range missing {
+				fmt.Printf("  %-12s  %-10s  %s\n", v.Version, humanizeBytes(v.FileSize), v.Created.Format("2006-01-02 15:04"))
+			}
+		}
 	}
 
 	return nil
@@ -432,8 +697,12 @@ type FwDownloadCmd struct{}
 func (c *FwDownloadCmd) Run(globals *CLI) error {
 	config.Verbose = globals.Verbose
 
-	manifest := firmware.NewManifestClient()
-	versions, err := manifest.GetAvailable(firmware.DefaultSFPWizardFilter())
+	store, err := firmware.NewFirmwareStore()
+	if err != nil {
+		return err
+	}
+
+	versions, err := globals.Fw.fetchManifest(store, firmware.DefaultSFPWizardFilter())
 	if err != nil {
 		return err
 	}
@@ -445,11 +714,6 @@ func (c *FwDownloadCmd) Run(globals *CLI) error {
 
 	fmt.Printf("Found %d firmware version(s) available:\n\n", len(versions))
 
-	store, err := firmware.NewFirmwareStore()
-	if err != nil {
-		return err
-	}
-
 	downloaded := 0
 	skipped := 0
 	for _, v := range versions {
@@ -479,42 +743,36 @@ func (c *FwDownloadCmd) Run(globals *CLI) error {
 }
 
 type FwPassdbCmd struct {
-	FileOrVersion string `arg:"" help:"Firmware file path or downloaded version (e.g., v1.1.1)"`
+	FileOrVersion string `arg:"" optional:"" help:"Firmware file path or downloaded version (e.g., v1.1.1)"`
 	JSON          bool   `help:"Output as JSON" short:"j"`
 	Search        string `help:"Emulate firmware lookup for part number (exact match, shows passwords that would be tried)" short:"s"`
+	For           string `help:"Emulate firmware lookup for the part number in an EEPROM file, store profile hash, or module: for the module in the device"`
+	AllCached     bool   `help:"Merge the databases of all downloaded versions into a per-part-number history"`
+	Format        string `help:"Output format for --all-cached" enum:"text,csv,json,markdown" default:"text"`
 }
 
 func (c *FwPassdbCmd) Run(globals *CLI) error {
 	config.Verbose = globals.Verbose
 
-	// Check if it's a file path or a version string
-	filePath := c.FileOrVersion
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		// Not a file, try to find in firmware store
-		store, err := firmware.NewFirmwareStore()
-		if err != nil {
-			return fmt.Errorf("failed to open firmware store: %w", err)
-		}
-
-		// Look for matching version
-		entries, err := store.List()
-		if err != nil {
-			return fmt.Errorf("failed to list firmware: %w", err)
-		}
-
-		version := c.FileOrVersion
-		// Try with and without 'v' prefix
-		for _, e := range entries {
-			if e.Version == version || e.Version == "v"+version || "v"+e.Version == version {
-				filePath = e.Path
-				fmt.Printf("Using downloaded firmware: %s\n", e.Version)
-				break
-			}
-		}
+	if c.AllCached == (c.FileOrVersion != "") {
+		return fmt.Errorf("give either a firmware file or version, or --all-cached")
+	}
+	if c.Search != "" && c.For != "" {
+		return fmt.Errorf("--search and --for are mutually exclusive")
+	}
+	if c.AllCached && (c.Search != "" || c.For != "") {
+		return fmt.Errorf("--all-cached cannot be combined with --search or --for")
+	}
+	if !c.AllCached && c.Format != "text" {
+		return fmt.Errorf("--format only applies to --all-cached (use --json for other output)")
+	}
+	if c.AllCached {
+		return c.runHistory()
+	}
 
-		if filePath == c.FileOrVersion {
-			return fmt.Errorf("firmware not found: %s (not a file or downloaded version)", c.FileOrVersion)
-		}
+	filePath, err := resolveFirmware(c.FileOrVersion)
+	if err != nil {
+		return err
 	}
 
 	// Parse the firmware image
@@ -533,6 +791,9 @@ func (c *FwPassdbCmd) Run(globals *CLI) error {
 	if c.Search != "" {
 		return c.runSearch(db)
 	}
+	if c.For != "" {
+		return c.runFor(img, db)
+	}
 
 	// Full database listing
 	entries := db.Entries
@@ -692,339 +953,2342 @@ func (c *FwPassdbCmd) runSearch(db *firmware.PasswordDatabase) error {
 	return nil
 }
 
-// CLIProgressBar renders a terminal-friendly progress bar.
-type CLIProgressBar struct {
-	width   int
-	current int64
-	total   int64
-}
+// moduleRef selects the module in the device as the EEPROM for --for.
+const moduleRef = "module:"
+
+// passwordTry is a password from the lookup, with the pages it makes
+// writable and the pages of the image it would not allow writing.
+type passwordTry struct {
+	firmware.PasswordAttempt
+	Writable []string `json:"writable_pages"`
+	Blocked  []string `json:"blocked_pages"`
+}
+
+// runFor emulates the firmware's lookup for the part number of an EEPROM,
+// using the algorithm of the firmware's version.
+func (c *FwPassdbCmd) runFor(img *firmware.ESP32Image, db *firmware.PasswordDatabase) error {
+	var data []byte
+	var name string
+	if c.For == moduleRef {
+		device := ble.Connect()
+		defer device.Disconnect()
+		var err error
+		if data, err = commands.ModuleEEPROM(device); err != nil {
+			return fmt.Errorf("failed to read module: %w", err)
+		}
+		name = "module in device"
+	} else {
+		var err error
+		if data, name, err = loadEEPROM(c.For); err != nil {
+			return err
+		}
+	}
 
-func (p *CLIProgressBar) Update(current, total int64, desc string) {
-	p.current = current
-	p.total = total
-	if total == 0 {
-		fmt.Printf("\r  %s...", desc)
-		return
+	meta := store.ExtractMetadata(data, "")
+	if meta == nil {
+		return fmt.Errorf("%s: cannot parse EEPROM", name)
+	}
+	partNum := meta.Identity.PartNumber
+	if partNum == "" {
+		return fmt.Errorf("%s has no part number", name)
 	}
-	percent := float64(current) / float64(total) * 100
-	filled := min(int(float64(p.width)*float64(current)/float64(total)), p.width)
-	bar := strings.Repeat("=", filled) + strings.Repeat(" ", p.width-filled)
-	fmt.Printf("\r  %s [%s] %.1f%%", desc, bar, percent)
-}
 
-func (p *CLIProgressBar) Complete() {
-	fmt.Println()
-}
+	version := ""
+	if desc, err := img.AppDescriptor(); err == nil {
+		version = desc.Version
+	}
+	algorithm := db.LookupAlgorithm(version)
+	matches := db.FindByPartNumber(partNum)
 
-func humanizeBytes(b int64) string {
-	const unit = 1024
-	if b < unit {
-		return fmt.Sprintf("%d B", b)
+	qsfp := len(data) == eeprom.QSFPSize
+	var pages []string
+	for _, p := range eeprom.Pages(data) {
+		pages = append(pages, p.Name)
 	}
-	div, exp := int64(unit), 0
-	for n := b / unit; n >= unit; n /= unit {
-		div *= unit
-		exp++
+	var tries []passwordTry
+	for _, a := range db.Lookup(partNum, algorithm) {
+		t := passwordTry{PasswordAttempt: a, Writable: a.Entry.WritablePages(qsfp)}
+		for _, p := range pages {
+			if !slices.Contains(t.Writable, p) {
+				t.Blocked = append(t.Blocked, p)
+			}
+		}
+		tries = append(tries, t)
 	}
-	return fmt.Sprintf("%.1f %cB", float64(b)/float64(div), "KMGTPE"[exp])
-}
 
-func truncate(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
+	if c.JSON {
+		data, _ := json.MarshalIndent(struct {
+			Source          string                   `json:"source"`
+			PartNumber      string                   `json:"part_number"`
+			FirmwareVersion string                   `json:"firmware_version,omitempty"`
+			Algorithm       string                   `json:"algorithm"`
+			Pages           []string                 `json:"pages"`
+			Matches         []firmware.PasswordEntry `json:"matches"`
+			Passwords       []passwordTry            `json:"passwords"`
+		}{
+			Source:          name,
+			PartNumber:      partNum,
+			FirmwareVersion: version,
+			Algorithm:       algorithm,
+			Pages:           pages,
+			Matches:         matches,
+			Passwords:       tries,
+		}, "", "  ")
+		fmt.Println(string(data))
+		return nil
 	}
-	return s[:maxLen-1] + "…"
-}
 
-// --- Support Commands ---
+	if version == "" {
+		version = "unknown version"
+	}
+	fmt.Printf("Firmware Password Lookup Emulation\n")
+	fmt.Printf("EEPROM: %s\n", name)
+	fmt.Printf("Part number: %q (exact match)\n", partNum)
+	fmt.Printf("Firmware: %s, %s lookup\n", version, algorithm)
+	fmt.Println(strings.Repeat("-", 60))
 
-type SupportCmd struct {
-	Dump SupportDumpCmd `cmd:"" help:"Download support info archive (syslog, module DB)"`
-	Logs SupportLogsCmd `cmd:"" help:"Show device syslog"`
-}
+	if len(matches) == 0 {
+		fmt.Printf("\nNo entries found for %q\n", partNum)
+		if algorithm == firmware.LookupFirstMatch {
+			fmt.Println("Falling back to every unique password in the database")
+		}
+	} else {
+		fmt.Printf("\nDatabase entries matching %q: %d\n", partNum, len(matches))
+		for i, entry := range matches {
+			note := ""
+			switch {
+			case entry.ReadOnly:
+				note = " (read-only, skipped)"
+			case !entry.Locked:
+				note = " (not locked)"
+			}
+			fmt.Printf("  %d. %s  pages=%s%s\n", i+1, formatPasswordWithASCII(&entry), entry.InterpretFlags(), note)
+		}
+	}
+
+	fmt.Printf("\nPasswords that would be tried: %d\n", len(tries))
+	fmt.Println(strings.Repeat("-", 60))
+	if len(tries) == 0 {
+		fmt.Println("  (none)")
+		return nil
+	}
+	var blocked []string
+	for i, t := range tries {
+		writable := strings.Join(t.Writable, "+")
+		if writable == "" {
+			writable = "none"
+		}
+		fmt.Printf("  %d. %-20s %-9s writable=%s\n", i+1, formatPasswordWithASCII(&t.Entry), t.Source, writable)
+		if len(t.Blocked) > 0 {
+			blocked = append(blocked, strconv.Itoa(i+1))
+		}
+	}
+	if firmware.ValidVersion(version) && firmware.CompareVersions(version, "1.0.10") < 0 {
+		fmt.Println("\nThis version does not verify the unlock, so only the first password counts.")
+	}
+
+	fmt.Printf("\nWriting this image (%s):\n", strings.Join(pages, ", "))
+	switch {
+	case len(blocked) == 0:
+		fmt.Println("  Every page is writable whichever password unlocks the module")
+	case len(blocked) == len(tries):
+		fmt.Println("  Touches pages that are not writable with any of these passwords:")
+	default:
+		plural := ""
+		if len(blocked) > 1 {
+			plural = "s"
+		}
+		fmt.Printf("  Touches pages that are not writable if unlocked with password%s %s:\n", plural, strings.Join(blocked, ", "))
+	}
+	for i, t := range tries {
+		if len(t.Blocked) > 0 {
+			fmt.Printf("  %d. %s not writable\n", i+1, strings.Join(t.Blocked, ", "))
+		}
+	}
+	return nil
+}
+
+// formatPasswordWithASCII formats a password as hex, followed by its ASCII
+// form if printable.
+func formatPasswordWithASCII(entry *firmware.PasswordEntry) string {
+	pwStr := entry.FormatPassword()
+	if ascii := entry.FormatPasswordASCII(); ascii != "" {
+		pwStr = fmt.Sprintf("%s  %q", pwStr, ascii)
+	}
+	return pwStr
+}
+
+// runHistory extracts the database from every downloaded version and
+// prints the merged history.
+func (c *FwPassdbCmd) runHistory() error {
+	store, err := firmware.NewFirmwareStore()
+	if err != nil {
+		return err
+	}
+	entries, err := store.List()
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("no firmware downloaded (run: sfpw fw download)")
+	}
+	firmware.SortEntriesNewestFirst(entries)
+	slices.Reverse(entries)
+
+	versions := make([]string, len(entries))
+	dbs := make([]*firmware.PasswordDatabase, len(entries))
+	errs := make([]error, len(entries))
+	for i, e := range entries {
+		versions[i] = strings.TrimPrefix(e.Version, "v")
+		img, err := firmware.ParseESP32Image(e.Path)
+		if err == nil {
+			dbs[i], err = firmware.ExtractPasswordDatabase(img)
+		}
+		errs[i] = err
+	}
+	history := firmware.BuildPassdbHistory(versions, dbs, errs)
+
+	format := c.Format
+	if c.JSON {
+		format = "json"
+	}
+	switch format {
+	case "json":
+		data, _ := json.MarshalIndent(history, "", "  ")
+		fmt.Println(string(data))
+		return nil
+	case "csv":
+		return writePassdbHistoryCSV(os.Stdout, history)
+	case "markdown":
+		printPassdbHistoryMarkdown(history)
+		return nil
+	}
+
+	fmt.Println("Password databases:")
+	for _, v := range history.Versions {
+		if v.Error != "" {
+			fmt.Printf("  %-10s  %s\n", v.Version, v.Error)
+			continue
+		}
+		fmt.Printf("  %-10s  %3d entries  %2d bytes  %d unique passwords\n", v.Version, v.Entries, v.EntrySize, v.UniquePasswords)
+	}
+	fmt.Println()
+	for _, ph := range history.PartNumbers {
+		status := ""
+		if !ph.Current {
+			status = " (removed)"
+		}
+		fmt.Printf("%s  %s..%s%s\n", ph.PartNumber, ph.FirstSeen, ph.LastSeen, status)
+		for _, ev := range ph.Events {
+			switch ev.Change {
+			case "added":
+				fmt.Printf("  %-10s added\n", ev.Version)
+				printPassdbEntries("               ", ev.Entries)
+			case "removed":
+				fmt.Printf("  %-10s removed\n", ev.Version)
+			default:
+				for _, d := range ev.Details {
+					fmt.Printf("  %-10s %s\n", ev.Version, d)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// passdbPasswords joins the passwords of entries for tables.
+func passdbPasswords(entries []firmware.PasswordEntry, sep string) string {
+	var pws []string
+	for _, e := range entries {
+		pws = append(pws, e.FormatPassword())
+	}
+	return strings.Join(pws, sep)
+}
+
+// passdbEventSummary describes the events of a part number on one line.
+func passdbEventSummary(ph firmware.PartNumberHistory, sep string) string {
+	var parts []string
+	for _, ev := range ph.Events {
+		switch {
+		case ev.Change == "added" && ev.Version == ph.FirstSeen:
+			continue
+		case ev.Change == "changed":
+			for _, d := range ev.Details {
+				parts = append(parts, ev.Version+": "+d)
+			}
+		default:
+			parts = append(parts, ev.Version+": "+ev.Change)
+		}
+	}
+	return strings.Join(parts, sep)
+}
+
+func writePassdbHistoryCSV(out io.Writer, h *firmware.PassdbHistory) error {
+	w := csv.NewWriter(out)
+	w.Write([]string{"part_number", "first_seen", "last_seen", "current", "passwords", "pages", "locked", "read_only", "cable_length", "changes"})
+	for _, ph := range h.PartNumbers {
+		var pages, locked, readOnly, cable []string
+		for _, e := range ph.Entries {
+			pages = append(pages, e.InterpretFlags())
+			locked = append(locked, strconv.FormatBool(e.Locked))
+			readOnly = append(readOnly, strconv.FormatBool(e.ReadOnly))
+			if ph.HasCableLength {
+				cable = append(cable, strconv.Itoa(int(e.CableLength)))
+			}
+		}
+		w.Write([]string{
+			ph.PartNumber, ph.FirstSeen, ph.LastSeen, strconv.FormatBool(ph.Current),
+			passdbPasswords(ph.Entries, ";"), strings.Join(pages, ";"), strings.Join(locked, ";"),
+			strings.Join(readOnly, ";"), strings.Join(cable, ";"), passdbEventSummary(ph, "; "),
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// printPassdbHistoryMarkdown prints the Database Summary table of API.md
+// and a per-part-number history table.
+func printPassdbHistoryMarkdown(h *firmware.PassdbHistory) {
+	fmt.Println("### Database Summary")
+	fmt.Println()
+	fmt.Println("| Firmware | Entries | Entry Size | Unique Passwords |")
+	fmt.Println("|----------|---------|------------|------------------|")
+	for _, v := range h.Versions {
+		if v.Error != "" {
+			continue
+		}
+		fmt.Printf("| %s | %d | %d bytes | %d |\n", v.Version, v.Entries, v.EntrySize, v.UniquePasswords)
+	}
+	fmt.Println()
+	fmt.Println("### Part Number History")
+	fmt.Println()
+	fmt.Println("| Part Number | First Seen | Last Seen | Passwords | Changes |")
+	fmt.Println("|-------------|------------|-----------|-----------|---------|")
+	for _, ph := range h.PartNumbers {
+		changes := passdbEventSummary(ph, "<br>")
+		if changes == "" {
+			changes = "—"
+		}
+		fmt.Printf("| %s | %s | %s | `%s` | %s |\n", strings.ReplaceAll(ph.PartNumber, "|", "\\|"),
+			ph.FirstSeen, ph.LastSeen, passdbPasswords(ph.Entries, "`, `"), changes)
+	}
+}
+
+type FwInspectCmd struct {
+	FileOrVersion string `arg:"" help:"Firmware file path or downloaded version (e.g., v1.1.1)"`
+	JSON          bool   `help:"Output as JSON" short:"j"`
+}
+
+func (c *FwInspectCmd) Run(globals *CLI) error {
+	config.Verbose = globals.Verbose
+
+	filePath, err := resolveFirmware(c.FileOrVersion)
+	if err != nil {
+		return err
+	}
+	img, err := firmware.ParseESP32Image(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to parse firmware: %w", err)
+	}
+	verifyErr := img.Verify()
+	desc, descErr := img.AppDescriptor()
+
+	if c.JSON {
+		type segment struct {
+			LoadAddr string `json:"load_addr"`
+			Size     uint32 `json:"size"`
+			Region   string `json:"region"`
+		}
+		type app struct {
+			ProjectName   string `json:"project_name"`
+			Version       string `json:"version"`
+			IDFVersion    string `json:"idf_version"`
+			Date          string `json:"date"`
+			Time          string `json:"time"`
+			ELFSHA256     string `json:"elf_sha256"`
+			SecureVersion uint32 `json:"secure_version"`
+			SFPWizard     bool   `json:"sfp_wizard"`
+		}
+		out := struct {
+			File         string    `json:"file"`
+			Size         int64     `json:"size"`
+			Chip         string    `json:"chip"`
+			ChipID       uint16    `json:"chip_id"`
+			EntryAddr    string    `json:"entry_addr"`
+			Checksum     string    `json:"checksum"`
+			HashAppended bool      `json:"hash_appended"`
+			SHA256       string    `json:"sha256,omitempty"`
+			Valid        bool      `json:"valid"`
+			Error        string    `json:"error,omitempty"`
+			Segments     []segment `json:"segments"`
+			App          *app      `json:"app,omitempty"`
+			AppError     string    `json:"app_error,omitempty"`
+			Problems     []string  `json:"problems,omitempty"`
+		}{
+			File:         filePath,
+			Size:         img.Size,
+			Chip:         img.ChipName(),
+			ChipID:       img.Header.ChipID,
+			EntryAddr:    fmt.Sprintf("0x%08x", img.Header.EntryAddr),
+			Checksum:     fmt.Sprintf("0x%02x", img.Checksum),
+			HashAppended: img.Header.HashAppended == 1,
+			Valid:        verifyErr == nil,
+			Problems:     img.FlashProblems(""),
+		}
+		if img.Digest != nil {
+			out.SHA256 = hex.EncodeToString(img.Digest)
+		}
+		if verifyErr != nil {
+			out.Error = verifyErr.Error()
+		}
+		for _, seg := range img.Segments {
+			out.Segments = append(out.Segments, segment{fmt.Sprintf("0x%08x", seg.LoadAddr), seg.DataLen, seg.Region()})
+		}
+		if descErr != nil {
+			out.AppError = descErr.Error()
+		} else {
+			out.App = &app{desc.ProjectName, desc.Version, desc.IDFVersion, desc.Date, desc.Time,
+				desc.ELFSHA256Hex(), desc.SecureVersion, desc.IsSFPWizard()}
+		}
+		data, _ := json.MarshalIndent(out, "", "  ")
+		fmt.Println(string(data))
+	} else {
+		fmt.Printf("File:          %s (%s)\n", filePath, humanizeBytes(img.Size))
+		fmt.Printf("Chip:          %s (id %d)\n", img.ChipName(), img.Header.ChipID)
+		fmt.Printf("Entry point:   0x%08x\n", img.Header.EntryAddr)
+		if img.Checksum == img.CalcChecksum {
+			fmt.Printf("Checksum:      0x%02x OK\n", img.Checksum)
+		} else {
+			fmt.Printf("Checksum:      0x%02x MISMATCH (computed 0x%02x)\n", img.Checksum, img.CalcChecksum)
+		}
+		switch {
+		case img.Header.HashAppended != 1:
+			fmt.Println("SHA-256:       not appended")
+		case img.Digest == nil:
+			fmt.Println("SHA-256:       missing")
+		case bytes.Equal(img.Digest, img.CalcDigest):
+			fmt.Printf("SHA-256:       %x OK\n", img.Digest)
+		default:
+			fmt.Printf("SHA-256:       %x MISMATCH\n", img.Digest)
+		}
+
+		fmt.Printf("\nSegments (%d):\n", len(img.Segments))
+		for i, seg := range img.Segments {
+			fmt.Printf("  %2d  0x%08x  %8d bytes  %s\n", i, seg.LoadAddr, seg.DataLen, seg.Region())
+		}
+
+		fmt.Println("\nApplication:")
+		if descErr != nil {
+			fmt.Printf("  %v\n", descErr)
+		} else {
+			fmt.Printf("  Project:     %s\n", desc.ProjectName)
+			fmt.Printf("  Version:     %s\n", desc.Version)
+			fmt.Printf("  IDF version: %s\n", desc.IDFVersion)
+			fmt.Printf("  Built:       %s %s\n", desc.Date, desc.Time)
+			fmt.Printf("  ELF SHA-256: %s\n", desc.ELFSHA256Hex())
+			if desc.SecureVersion != 0 {
+				fmt.Printf("  Secure ver:  %d\n", desc.SecureVersion)
+			}
+		}
+
+		if problems := img.FlashProblems(""); len(problems) > 0 {
+			fmt.Println("\nNot flashable without --force:")
+			for _, p := range problems {
+				fmt.Printf("  - %s\n", p)
+			}
+		}
+	}
+
+	if verifyErr != nil {
+		return fmt.Errorf("image verification failed: %w", verifyErr)
+	}
+	return nil
+}
+
+type FwCacheCmd struct {
+	Verify FwCacheVerifyCmd `cmd:"" help:"Re-hash downloaded firmware against the recorded SHA-256"`
+	Prune  FwCachePruneCmd  `cmd:"" help:"Remove all but the newest downloaded versions"`
+	Export FwCacheExportCmd `cmd:"" help:"Write all downloaded firmware to an archive"`
+	Import FwCacheImportCmd `cmd:"" help:"Merge an archive written by fw cache export"`
+}
+
+type FwCacheVerifyCmd struct{}
+
+func (c *FwCacheVerifyCmd) Run(globals *CLI) error {
+	store, err := firmware.NewFirmwareStore()
+	if err != nil {
+		return err
+	}
+	results, err := store.Verify()
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		fmt.Println("No firmware files downloaded.")
+		return nil
+	}
+
+	bad, unknown := 0, 0
+	for _, r := range results {
+		switch {
+		case r.Err != nil:
+			fmt.Printf("  %-12s  ERROR     %v\n", r.Entry.Version, r.Err)
+			bad++
+		case r.Expected == "":
+			fmt.Printf("  %-12s  UNKNOWN   no checksum recorded (%s)\n", r.Entry.Version, r.Actual)
+			unknown++
+		case r.OK():
+			fmt.Printf("  %-12s  OK        %s\n", r.Entry.Version, r.Actual)
+		default:
+			fmt.Printf("  %-12s  MISMATCH  expected %s, got %s\n", r.Entry.Version, r.Expected, r.Actual)
+			bad++
+		}
+	}
+	fmt.Printf("\n%d file(s): %d OK, %d bad, %d unknown\n", len(results), len(results)-bad-unknown, bad, unknown)
+	if bad > 0 {
+		return fmt.Errorf("%d firmware file(s) failed verification", bad)
+	}
+	return nil
+}
+
+type FwCachePruneCmd struct {
+	Keep   int  `help:"Number of newest versions to keep" required:""`
+	DryRun bool `help:"Show what would be removed without removing it" name:"dry-run"`
+}
+
+func (c *FwCachePruneCmd) Run(globals *CLI) error {
+	if c.Keep < 0 {
+		return fmt.Errorf("--keep must not be negative")
+	}
+	store, err := firmware.NewFirmwareStore()
+	if err != nil {
+		return err
+	}
+	removed, err := store.Prune(c.Keep, c.DryRun)
+	if err != nil {
+		return err
+	}
+
+	verb := "Removed"
+	if c.DryRun {
+		verb = "Would remove"
+	}
+	var freed int64
+	for _, e := range removed {
+		fmt.Printf("  %s %s (%s)\n", verb, e.Version, humanizeBytes(e.FileSize))
+		freed += e.FileSize
+	}
+	fmt.Printf("%s %d version(s), %s\n", verb, len(removed), humanizeBytes(freed))
+	return nil
+}
+
+type FwCacheExportCmd struct {
+	Output string `arg:"" help:"Archive file (.tar, or .tar.gz/.tgz to compress)"`
+}
+
+func (c *FwCacheExportCmd) Run(globals *CLI) error {
+	store, err := firmware.NewFirmwareStore()
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(c.Output)
+	if err != nil {
+		return err
+	}
+	compress := strings.HasSuffix(c.Output, ".gz") || strings.HasSuffix(c.Output, ".tgz")
+	n, err := store.ExportCache(f, compress)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(c.Output)
+		return fmt.Errorf("export failed: %w", err)
+	}
+	fmt.Printf("Exported %d firmware file(s) to %s\n", n, c.Output)
+	return nil
+}
+
+type FwCacheImportCmd struct {
+	Archive string `arg:"" help:"Archive written by fw cache export" type:"existingfile"`
+	Force   bool   `help:"Replace cached firmware files that differ from the archived ones"`
+}
+
+func (c *FwCacheImportCmd) Run(globals *CLI) error {
+	store, err := firmware.NewFirmwareStore()
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(c.Archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	result, err := store.ImportCache(f, c.Force)
+	if err != nil {
+		return fmt.Errorf("import failed: %w", err)
+	}
+	for _, v := range result.Added {
+		fmt.Printf("  Added %s\n", v)
+	}
+	for _, v := range result.Replaced {
+		fmt.Printf("  Replaced %s\n", v)
+	}
+	fmt.Printf("Imported %d new, %d replaced, %d already present\n", len(result.Added), len(result.Replaced), len(result.Skipped))
+	if result.Manifest {
+		fmt.Println("Updated the saved manifest")
+	}
+	return nil
+}
+
+type FwElfCmd struct {
+	FileOrVersion string `arg:"" help:"Firmware file path or downloaded version (e.g., v1.1.1)"`
+	Output        string `help:"ELF file to write" short:"o" required:""`
+	NoSymbols     bool   `help:"Do not add symbols for known structures (password database, GPIO config, app descriptor)"`
+}
+
+func (c *FwElfCmd) Run(globals *CLI) error {
+	config.Verbose = globals.Verbose
+
+	filePath, err := resolveFirmware(c.FileOrVersion)
+	if err != nil {
+		return err
+	}
+	img, err := firmware.ParseESP32Image(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to parse firmware: %w", err)
+	}
+	if err := img.Verify(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+
+	var symbols []firmware.ELFSymbol
+	if !c.NoSymbols {
+		symbols = img.KnownSymbols()
+	}
+
+	f, err := os.Create(c.Output)
+	if err != nil {
+		return err
+	}
+	err = img.WriteELF(f, symbols)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(c.Output)
+		return fmt.Errorf("failed to write ELF: %w", err)
+	}
+
+	fmt.Printf("Wrote %s: entry 0x%08x, %d segment(s), %d symbol(s)\n", c.Output, img.Header.EntryAddr, len(img.Segments), len(symbols))
+	for _, seg := range img.Segments {
+		fmt.Printf("  %-8s  0x%08x  %s\n", seg.Region(), seg.LoadAddr, humanizeBytes(int64(seg.DataLen)))
+	}
+	for _, sym := range symbols {
+		fmt.Printf("  0x%08x  %s\n", sym.Addr, sym.Name)
+	}
+	return nil
+}
+
+type FwGpioCmd struct {
+	FileOrVersion string `arg:"" help:"Firmware file path or downloaded version (e.g., v1.1.1)"`
+	Compare       string `arg:"" optional:"" help:"Newer firmware to compare against"`
+	JSON          bool   `help:"Output as JSON" short:"j"`
+	Markdown      bool   `help:"Output the pin tables in GPIO.md format" short:"m"`
+}
+
+func (c *FwGpioCmd) Run(globals *CLI) error {
+	config.Verbose = globals.Verbose
+
+	var tables []*firmware.GPIOTable
+	for _, ref := range []string{c.FileOrVersion, c.Compare} {
+		if ref == "" {
+			continue
+		}
+		path, err := resolveFirmware(ref)
+		if err != nil {
+			return err
+		}
+		img, err := firmware.ParseESP32Image(path)
+		if err != nil {
+			return fmt.Errorf("%s: failed to parse firmware: %w", ref, err)
+		}
+		table, err := firmware.ExtractGPIOTable(img)
+		if err != nil {
+			return fmt.Errorf("%s: failed to extract GPIO table: %w", ref, err)
+		}
+		tables = append(tables, table)
+	}
+
+	if len(tables) == 2 {
+		diff := firmware.DiffGPIOTables(tables[0], tables[1])
+		if c.JSON {
+			data, _ := json.MarshalIndent(diff, "", "  ")
+			fmt.Println(string(data))
+			return nil
+		}
+		fmt.Printf("GPIO config %s -> %s:\n", c.FileOrVersion, c.Compare)
+		printGPIODiff(diff)
+		return nil
+	}
+
+	table := tables[0]
+	switch {
+	case c.JSON:
+		data, _ := json.MarshalIndent(table, "", "  ")
+		fmt.Println(string(data))
+	case c.Markdown:
+		fmt.Printf("Configuration array located at `0x%08x` (%d entries, 0x%x bytes each).\n\n", table.Addr, len(table.Pins), firmware.GPIOEntrySize)
+		printGPIOMarkdown("GPIO", table.Pins, false)
+		if table.Expander != nil {
+			fmt.Printf("\nCA9554 configuration array located at `0x%08x` (%d entries).\n\n", table.ExpanderAddr, len(table.Expander))
+			printGPIOMarkdown("IOE Pin", table.Expander, true)
+		}
+		fmt.Println("\nClass groups pins that share a handler (vtable) in the firmware. Direction and Function are")
+		fmt.Println("annotations looked up by signal name, not decoded from the firmware; \"(inferred)\" marks a")
+		fmt.Println("direction taken from an annotated pin of the same class.")
+	default:
+		fmt.Printf("GPIO config array at 0x%08x, %d entries of 0x%x bytes:\n\n", table.Addr, len(table.Pins), firmware.GPIOEntrySize)
+		printGPIOPins(table.Pins, false)
+		if table.Expander != nil {
+			fmt.Printf("\nCA9554 expander config array at 0x%08x, %d entries:\n\n", table.ExpanderAddr, len(table.Expander))
+			printGPIOPins(table.Expander, true)
+		} else {
+			fmt.Printf("\nCA9554 expander config array not found: %s\n", table.ExpanderError)
+		}
+		fmt.Println("\nDirection and function (*) are annotations from GPIO.md, not decoded from the firmware.")
+		fmt.Println("Directions marked ? are inferred from annotated pins of the same class (same handler).")
+	}
+	return nil
+}
+
+// gpioPinName formats a pin number as GPIOn, or Pn on the expander.
+func gpioPinName(pin uint32, expander bool) string {
+	if expander {
+		return fmt.Sprintf("P%d", pin)
+	}
+	return fmt.Sprintf("GPIO%d", pin)
+}
+
+func printGPIOPins(pins []firmware.GPIOPin, expander bool) {
+	fmt.Printf("  %-3s  %-6s  %-14s  %-5s  %-10s  %-11s  %s\n", "#", "Pin", "Signal", "Class", "Handler", "Direction*", "Function*")
+	for i, p := range pins {
+		direction := p.Annotation.Direction
+		if p.Annotation.Inferred {
+			direction += "?"
+		}
+		fmt.Printf("  %-3d  %-6s  %-14s  %-5d  0x%08x  %-11s  %s\n",
+			i, gpioPinName(p.Pin, expander), p.Name, p.Class, p.Handler, direction, p.Annotation.Function)
+	}
+}
+
+// printGPIOMarkdown prints pins sorted by number as a GPIO.md table.
+func printGPIOMarkdown(pinHeader string, pins []firmware.GPIOPin, expander bool) {
+	pins = append([]firmware.GPIOPin(nil), pins...)
+	sort.SliceStable(pins, func(i, j int) bool { return pins[i].Pin < pins[j].Pin })
+	fmt.Printf("| %s | Signal Name | Class | Direction | Function |\n", pinHeader)
+	fmt.Printf("| %s | ----------- | ----- | --------- | -------- |\n", strings.Repeat("-", len(pinHeader)))
+	for _, p := range pins {
+		direction := "-"
+		if d := p.Annotation.Direction; d != "" {
+			direction = strings.ToUpper(d[:1]) + d[1:]
+			if p.Annotation.Inferred {
+				direction += " (inferred)"
+			}
+		}
+		function := p.Annotation.Function
+		if function == "" {
+			function = "-"
+		}
+		pin := fmt.Sprint(p.Pin)
+		if expander {
+			pin = gpioPinName(p.Pin, true)
+		}
+		fmt.Printf("| %s | %s | %d | %s | %s |\n", pin, p.Name, p.Class, direction, function)
+	}
+}
+
+type FwDiffCmd struct {
+	A          string `arg:"" help:"Older firmware file path or downloaded version"`
+	B          string `arg:"" help:"Newer firmware file path or downloaded version"`
+	JSON       bool   `help:"Output as JSON" short:"j"`
+	MaxStrings int    `help:"Limit added/removed DROM strings shown in text output (0 for all)" default:"50"`
+}
+
+func (c *FwDiffCmd) Run(globals *CLI) error {
+	config.Verbose = globals.Verbose
+
+	var imgs [2]*firmware.ESP32Image
+	for i, ref := range []string{c.A, c.B} {
+		path, err := resolveFirmware(ref)
+		if err != nil {
+			return err
+		}
+		if imgs[i], err = firmware.ParseESP32Image(path); err != nil {
+			return fmt.Errorf("%s: failed to parse firmware: %w", ref, err)
+		}
+	}
+	diff := firmware.DiffImages(imgs[0], imgs[1])
+
+	if c.JSON {
+		data, _ := json.MarshalIndent(diff, "", "  ")
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("Comparing %s -> %s\n", c.A, c.B)
+
+	fmt.Println("\nSegments:")
+	segment := func(seg *firmware.SegmentInfo) string {
+		if seg == nil {
+			return fmt.Sprintf("%-30s", "-")
+		}
+		return fmt.Sprintf("0x%08x %9d %-8s", seg.LoadAddr, seg.Size, seg.Region)
+	}
+	for _, sc := range diff.Segments {
+		mark := ""
+		switch {
+		case sc.Changed:
+			mark = "  layout changed"
+		case sc.SameData:
+			mark = "  identical"
+		}
+		fmt.Printf("  %2d  %s  %s%s\n", sc.Index, segment(sc.A), segment(sc.B), mark)
+	}
+
+	fmt.Println("\nHeader and app description:")
+	if len(diff.Fields) == 0 {
+		fmt.Println("  (unchanged)")
+	}
+	for _, f := range diff.Fields {
+		fmt.Printf("  %-15s %s -> %s\n", f.Field+":", f.A, f.B)
+	}
+
+	fmt.Println("\nAPI routes:")
+	printSetDiff(diff.RoutesAdded, diff.RoutesRemoved, 0)
+
+	fmt.Println("\nPassword database:")
+	switch {
+	case diff.Passdb == nil:
+		fmt.Printf("  %s\n", diff.PassdbError)
+	case diff.Passdb.Empty():
+		fmt.Printf("  (unchanged, %d-byte entries)\n", diff.Passdb.EntrySizeA)
+	default:
+		db := diff.Passdb
+		if db.EntrySizeA != db.EntrySizeB {
+			fmt.Printf("  Entry size: %d -> %d bytes\n", db.EntrySizeA, db.EntrySizeB)
+		}
+		for _, ch := range db.Added {
+			fmt.Printf("  + %s\n", ch.PartNumber)
+			printPassdbEntries("      ", ch.New)
+		}
+		for _, ch := range db.Removed {
+			fmt.Printf("  - %s\n", ch.PartNumber)
+			printPassdbEntries("      ", ch.Old)
+		}
+		changed := db.Changed
+		if db.Default != nil {
+			changed = append(changed, firmware.PassdbChange{PartNumber: "(default)", Old: db.Default.Old, New: db.Default.New})
+		}
+		for _, ch := range changed {
+			fmt.Printf("  ~ %s\n", ch.PartNumber)
+			printPassdbEntries("    - ", ch.Old)
+			printPassdbEntries("    + ", ch.New)
+		}
+	}
+
+	fmt.Println("\nGPIO config:")
+	if diff.GPIO == nil {
+		fmt.Printf("  %s\n", diff.GPIOError)
+	} else {
+		printGPIODiff(diff.GPIO)
+	}
+
+	fmt.Printf("\nDROM strings (+%d -%d):\n", len(diff.StringsAdded), len(diff.StringsRemoved))
+	printSetDiff(diff.StringsAdded, diff.StringsRemoved, c.MaxStrings)
+	return nil
+}
+
+func printGPIODiff(d *firmware.GPIODiff) {
+	if d.Empty() {
+		fmt.Println("  (unchanged)")
+		return
+	}
+	for _, ch := range d.Added {
+		fmt.Printf("  + %-14s %s %s\n", ch.Name, gpioPinName(ch.New.Pin, ch.Expander), ch.New.Annotation.Direction)
+	}
+	for _, ch := range d.Removed {
+		fmt.Printf("  - %-14s %s %s\n", ch.Name, gpioPinName(ch.Old.Pin, ch.Expander), ch.Old.Annotation.Direction)
+	}
+	for _, ch := range d.Changed {
+		fmt.Printf("  ~ %-14s %s %s -> %s %s\n", ch.Name,
+			gpioPinName(ch.Old.Pin, ch.Expander), ch.Old.Annotation.Direction,
+			gpioPinName(ch.New.Pin, ch.Expander), ch.New.Annotation.Direction)
+	}
+}
+
+// printSetDiff prints added and removed strings, at most limit of each
+// (all if limit is 0).
+func printSetDiff(added, removed []string, limit int) {
+	if len(added) == 0 && len(removed) == 0 {
+		fmt.Println("  (unchanged)")
+		return
+	}
+	for _, set := range []struct {
+		mark  string
+		items []string
+	}{{"+", added}, {"-", removed}} {
+		for i, item := range set.items {
+			if limit > 0 && i == limit {
+				fmt.Printf("  %s ... %d more\n", set.mark, len(set.items)-limit)
+				break
+			}
+			fmt.Printf("  %s %q\n", set.mark, item)
+		}
+	}
+}
+
+func printPassdbEntries(prefix string, entries []firmware.PasswordEntry) {
+	for i := range entries {
+		fmt.Printf("%s%s\n", prefix, entries[i].String())
+	}
+}
+
+// CLIProgressBar renders a terminal-friendly progress bar.
+type CLIProgressBar struct {
+	width   int
+	current int64
+	total   int64
+}
+
+func (p *CLIProgressBar) Update(current, total int64, desc string) {
+	p.current = current
+	p.total = total
+	if total == 0 {
+		fmt.Printf("\r  %s...", desc)
+		return
+	}
+	percent := float64(current) / float64(total) * 100
+	filled := min(int(float64(p.width)*float64(current)/float64(total)), p.width)
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", p.width-filled)
+	fmt.Printf("\r  %s [%s] %.1f%%", desc, bar, percent)
+}
+
+func (p *CLIProgressBar) Complete() {
+	fmt.Println()
+}
+
+func humanizeBytes(b int64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := int64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(b)/float64(div), "KMGTPE"[exp])
+}
+
+func truncate(s string, maxLen int) string {
+	if len(s) <= maxLen {
+		return s
+	}
+	return s[:maxLen-1] + "…"
+}
+
+// --- Support Commands ---
+
+type SupportCmd struct {
+	Dump SupportDumpCmd `cmd:"" help:"Download support info archive (syslog, module DB)"`
+	Logs SupportLogsCmd `cmd:"" help:"Show device syslog"`
+}
 
 type SupportDumpCmd struct{}
 
-func (c *SupportDumpCmd) Run(globals *CLI) error {
+func (c *SupportDumpCmd) Run(globals *CLI) error {
+	config.Verbose = globals.Verbose
+	device := ble.Connect()
+	defer device.Disconnect()
+	commands.SupportDump(device)
+	return nil
+}
+
+type SupportLogsCmd struct{}
+
+func (c *SupportLogsCmd) Run(globals *CLI) error {
+	config.Verbose = globals.Verbose
+	device := ble.Connect()
+	defer device.Disconnect()
+	commands.Logs(device)
+	return nil
+}
+
+// --- Debug Commands ---
+
+type DebugCmd struct {
+	Explore     DebugExploreCmd     `cmd:"" help:"List all BLE services and characteristics"`
+	DumpAll     DebugDumpAllCmd     `cmd:"" name:"dump-all" help:"Dump all read-only API responses as raw JSON"`
+	TestEncode  DebugTestEncodeCmd  `cmd:"" name:"test-encode" help:"Test protocol encoding"`
+	TestPackets DebugTestPacketsCmd `cmd:"" name:"test-packets" help:"Decode packets from TSV file"`
+	ParseEeprom DebugParseEepromCmd `cmd:"" name:"parse-eeprom" help:"Parse SFP/QSFP EEPROM file"`
+	RawAPI      DebugRawAPICmd      `cmd:"" name:"raw-api" help:"Send raw API request"`
+}
+
+type DebugExploreCmd struct{}
+
+func (c *DebugExploreCmd) Run(globals *CLI) error {
+	config.Verbose = globals.Verbose
+	device := ble.Connect()
+	defer device.Disconnect()
+	commands.Explore(device)
+	return nil
+}
+
+type DebugDumpAllCmd struct{}
+
+func (c *DebugDumpAllCmd) Run(globals *CLI) error {
+	config.Verbose = globals.Verbose
+	device := ble.Connect()
+	defer device.Disconnect()
+	commands.DumpAll(device)
+	return nil
+}
+
+type DebugTestEncodeCmd struct{}
+
+func (c *DebugTestEncodeCmd) Run(globals *CLI) error {
+	config.Verbose = globals.Verbose
+	commands.TestEncode()
+	return nil
+}
+
+type DebugTestPacketsCmd struct {
+	File string `arg:"" help:"TSV file containing packet data"`
+}
+
+func (c *DebugTestPacketsCmd) Run(globals *CLI) error {
+	config.Verbose = globals.Verbose
+	commands.TestPackets(c.File)
+	return nil
+}
+
+type DebugParseEepromCmd struct {
+	File string `arg:"" help:"EEPROM binary file to parse"`
+}
+
+func (c *DebugParseEepromCmd) Run(globals *CLI) error {
+	config.Verbose = globals.Verbose
+	commands.ParseEEPROM(c.File)
+	return nil
+}
+
+type DebugRawAPICmd struct {
+	Method string `arg:"" help:"HTTP method (GET or POST)"`
+	Path   string `arg:"" help:"API path (e.g., /xsfp/sync/cancel)"`
+	Body   string `optional:"" help:"Request body (optional)"`
+}
+
+func (c *DebugRawAPICmd) Run(globals *CLI) error {
+	config.Verbose = globals.Verbose
+	device := ble.Connect()
+	defer device.Disconnect()
+	commands.RawAPI(device, c.Method, c.Path, c.Body)
+	return nil
+}
+
+// --- Store Commands ---
+
+type StoreCmd struct {
+	List    StoreListCmd    `cmd:"" help:"List all stored module profiles"`
+	Find    StoreFindCmd    `cmd:"" help:"Search stored profiles with a query expression"`
+	Show    StoreShowCmd    `cmd:"" help:"Show details of a stored profile"`
+	Import  StoreImportCmd  `cmd:"" help:"Import an EEPROM file into the store"`
+	Export  StoreExportCmd  `cmd:"" help:"Export a profile to a file"`
+	Report  StoreReportCmd  `cmd:"" help:"Summarise stored modules by vendor, part number and type"`
+	Tag     StoreTagCmd     `cmd:"" help:"Add tags to a stored profile"`
+	Untag   StoreUntagCmd   `cmd:"" help:"Remove tags from a stored profile"`
+	Note    StoreNoteCmd    `cmd:"" help:"Add a note to a stored profile, or list its notes"`
+	Fsck    StoreFsckCmd    `cmd:"" help:"Check store consistency and re-derive metadata"`
+	Reindex StoreReindexCmd `cmd:"" help:"Regenerate the store index from metadata"`
+	Rm      StoreRmCmd      `cmd:"" help:"Remove profiles from the store"`
+	Gc      StoreGcCmd      `cmd:"" help:"Remove orphaned profile data and blobs"`
+	Pack    StorePackCmd    `cmd:"" help:"Pack matching profiles into a bundle for sharing"`
+	Unpack  StoreUnpackCmd  `cmd:"" help:"Merge a profile bundle into the store"`
+	Serve   StoreServeCmd   `cmd:"" help:"Serve the store over HTTP for team sync"`
+	Push    StorePushCmd    `cmd:"" help:"Upload changed profiles to a store server"`
+	Pull    StorePullCmd    `cmd:"" help:"Download changed profiles from a store server"`
+}
+
+type StoreListCmd struct {
+	Tag []string `help:"Only list profiles with this tag (repeatable)"`
+}
+
+func (c *StoreListCmd) Run(globals *CLI) error {
+	config.Verbose = globals.Verbose
+
+	s, err := store.OpenDefault()
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+
+	profiles, err := s.ListWithHashes()
+	if err != nil {
+		return fmt.Errorf("failed to list profiles: %w", err)
+	}
+
+	if len(profiles) == 0 {
+		fmt.Println("No profiles in store.")
+		fmt.Println("Import profiles with: sfpw store import <eeprom.bin>")
+		return nil
+	}
+
+	if len(c.Tag) > 0 {
+		terms := make([]string, len(c.Tag))
+		for i, tag := range c.Tag {
+			terms[i] = "tag=" + strconv.Quote(tag)
+		}
+		q, err := store.ParseQuery(strings.Join(terms, " and "))
+		if err != nil {
+			return err
+		}
+		if profiles, err = s.Find(q); err != nil {
+			return fmt.Errorf("failed to search profiles: %w", err)
+		}
+	}
+
+	printProfiles(profiles)
+	return nil
+}
+
+type StoreFindCmd struct {
+	Query []string `arg:"" help:"Search expression, e.g. vendor~\"FS\" and wavelength=1310 and bitrate>=10000"`
+}
+
+func (c *StoreFindCmd) Help() string {
+	return `Fields: vendor, pn, sn, rev, oui, date, type, connector, wavelength, bitrate,
+compliance, checksum (valid/invalid), method, device, tag, note, hash.
+
+Operators: = != ~ (substring) !~ < <= > >=. String matching is case-insensitive.
+List fields (compliance, method, device, tag, note) match if any value matches.
+Combine terms with and, or, not and parentheses.`
+}
+
+func (c *StoreFindCmd) Run(globals *CLI) error {
+	config.Verbose = globals.Verbose
+
+	q, err := store.ParseQuery(strings.Join(c.Query, " "))
+	if err != nil {
+		return fmt.Errorf("invalid query: %w", err)
+	}
+
+	s, err := store.OpenDefault()
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+
+	profiles, err := s.Find(q)
+	if err != nil {
+		return fmt.Errorf("failed to search profiles: %w", err)
+	}
+
+	if len(profiles) == 0 {
+		fmt.Println("No matching profiles.")
+		return nil
+	}
+
+	printProfiles(profiles)
+	return nil
+}
+
+// printProfiles lists profiles one per line, newest first.
+func printProfiles(profiles map[string]store.IndexEntry) {
+	hashes := make([]string, 0, len(profiles))
+	for hash := range profiles {
+		hashes = append(hashes, hash)
+	}
+	sort.Slice(hashes, func(i, j int) bool {
+		return profiles[hashes[i]].CreatedAt.After(profiles[hashes[j]].CreatedAt)
+	})
+
+	fmt.Printf("%d profile(s)\n\n", len(profiles))
+	for _, hash := range hashes {
+		entry := profiles[hash]
+		shortHash := store.ShortHash(hash)
+		wavelength := ""
+		if entry.WavelengthNM > 0 {
+			wavelength = fmt.Sprintf("%dnm", entry.WavelengthNM)
+		}
+		tags := ""
+		if len(entry.Tags) > 0 {
+			tags = "[" + strings.Join(entry.Tags, ", ") + "]"
+		}
+		fmt.Printf("  %-12s  %-16s  %-16s  %-16s  %-6s  %s\n",
+			shortHash,
+			truncate(entry.VendorName, 16),
+			truncate(entry.PartNumber, 16),
+			truncate(entry.SerialNumber, 16),
+			wavelength,
+			tags)
+	}
+}
+
+type StoreShowCmd struct {
+	Hash string   `arg:"" help:"Profile hash (full or short)"`
+	Diff []string `help:"Show a byte diff of a variant against the primary dump, or between two variants" placeholder:"VARIANT"`
+}
+
+func (c *StoreShowCmd) Run(globals *CLI) error {
+	config.Verbose = globals.Verbose
+
+	s, err := store.OpenDefault()
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+
+	// Try to find matching hash
+	profiles, err := s.ListWithHashes()
+	if err != nil {
+		return fmt.Errorf("failed to list profiles: %w", err)
+	}
+
+	var fullHash string
+	for hash := range profiles {
+		if hash == c.Hash || store.ShortHash(hash) == c.Hash || hash[7:] == c.Hash {
+			fullHash = hash
+			break
+		}
+	}
+
+	if fullHash == "" {
+		return fmt.Errorf("profile not found: %s", c.Hash)
+	}
+
+	entry := profiles[fullHash]
+	meta, err := s.GetMetadata(fullHash)
+	if err != nil {
+		return fmt.Errorf("failed to get metadata: %w", err)
+	}
+
+	shortHash := store.ShortHash(fullHash)
+	fmt.Printf("Hash:        %s\n", shortHash)
+	fmt.Printf("Type:        %s\n", entry.ModuleType)
+	fmt.Printf("Vendor:      %s\n", entry.VendorName)
+	fmt.Printf("Part Number: %s\n", entry.PartNumber)
+	fmt.Printf("Serial:      %s\n", entry.SerialNumber)
+
+	if meta != nil {
+		if meta.Identity.DateCode != "" {
+			fmt.Printf("Date Code:   %s\n", meta.Identity.DateCode)
+		}
+		if meta.Specs.WavelengthNM > 0 {
+			fmt.Printf("Wavelength:  %d nm\n", meta.Specs.WavelengthNM)
+		}
+		if meta.Specs.ConnectorType != "" {
+			fmt.Printf("Connector:   %s\n", meta.Specs.ConnectorType)
+		}
+		fmt.Printf("Sources:     %d\n", len(meta.Sources))
+		if len(meta.Tags) > 0 {
+			fmt.Printf("Tags:        %s\n", strings.Join(meta.Tags, ", "))
+		}
+		if len(meta.Notes) > 0 {
+			fmt.Printf("\nNotes:\n")
+			printNotes(meta.Notes)
+		}
+
+		if len(meta.Variants) > 0 {
+			primary, err := s.Get(fullHash)
+			if err != nil {
+				return fmt.Errorf("failed to read profile: %w", err)
+			}
+			fmt.Printf("\nVariants (%d):\n", len(meta.Variants))
+			for i, v := range meta.Variants {
+				data, _, err := s.GetVariant(fullHash, v.Hash)
+				summary := "primary"
+				if err != nil {
+					summary = err.Error()
+				} else if i > 0 {
+					summary = diffSummary(primary, data)
+				}
+				fmt.Printf("  %-12s  %4d bytes  %s  %d source(s)  %s\n", store.ShortHash(v.Hash), v.Size,
+					v.FirstSeen.Format("2006-01-02 15:04"), meta.SourceCount(v.Hash), summary)
+			}
+		}
+	}
+
+	if len(c.Diff) > 0 {
+		if len(c.Diff) > 2 {
+			return fmt.Errorf("--diff takes one or two variants")
+		}
+		a, err := s.Get(fullHash)
+		if err != nil {
+			return fmt.Errorf("failed to read profile: %w", err)
+		}
+		aName := "primary"
+		if len(c.Diff) == 2 {
+			var blob string
+			if a, blob, err = s.GetVariant(fullHash, c.Diff[0]); err != nil {
+				return err
+			}
+			aName = "variant " + store.ShortHash(blob)
+		}
+		b, blob, err := s.GetVariant(fullHash, c.Diff[len(c.Diff)-1])
+		if err != nil {
+			return err
+		}
+		fmt.Println()
+		printByteDiff(aName, a, "variant "+store.ShortHash(blob), b)
+	}
+
+	fmt.Printf("\nExport: sfpw store export %s <file>\n", shortHash)
+
+	return nil
+}
+
+func printNotes(notes []store.Note) {
+	for _, n := range notes {
+		fmt.Printf("  %s  %s\n", n.CreatedAt.Format("2006-01-02 15:04"), n.Text)
+	}
+}
+
+// diffSummary describes how a variant differs from the primary dump, by page.
+func diffSummary(primary, data []byte) string {
+	ranges := eeprom.DiffBytes(primary, data)
+	if len(ranges) == 0 {
+		return "identical to primary"
+	}
+	counts := make(map[string]int)
+	var pages []string
+	for _, r := range ranges {
+		for i := r.Start; i < r.End; i++ {
+			page := eeprom.PageName(len(data), i)
+			if counts[page] == 0 {
+				pages = append(pages, page)
+			}
+			counts[page]++
+		}
+	}
+	parts := make([]string, len(pages))
+	for i, p := range pages {
+		parts[i] = fmt.Sprintf("%s: %d bytes", p, counts[p])
+	}
+	return "differs from primary (" + strings.Join(parts, ", ") + ")"
+}
+
+// printByteDiff prints each differing range of two images side by side.
+func printByteDiff(aName string, a []byte, bName string, b []byte) {
+	ranges := eeprom.DiffBytes(a, b)
+	if len(ranges) == 0 {
+		fmt.Printf("%s and %s are identical\n", aName, bName)
+		return
+	}
+	fmt.Printf("--- %s\n+++ %s\n", aName, bName)
+	for _, r := range ranges {
+		fmt.Printf("@@ 0x%03X-0x%03X (%s, %d bytes)\n", r.Start, r.End-1, eeprom.PageName(max(len(a), len(b)), r.Start), r.End-r.Start)
+		for off := r.Start; off < r.End; off += 16 {
+			end := min(off+16, r.End)
+			fmt.Printf("  0x%03X  - % x\n", off, a[min(off, len(a)):min(end, len(a))])
+			fmt.Printf("  0x%03X  + % x\n", off, b[min(off, len(b)):min(end, len(b))])
+		}
+	}
+}
+
+type StoreImportCmd struct {
+	File string `arg:"" help:"EEPROM file to import (raw, ethtool, switch CLI or i2cdump)"`
+}
+
+func (c *StoreImportCmd) Run(globals *CLI) error {
+	config.Verbose = globals.Verbose
+
+	input, err := os.ReadFile(c.File)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+
+	data, format, err := eeprom.Decode(input)
+	if err != nil {
+		return fmt.Errorf("failed to decode %s: %w", c.File, err)
+	}
+	if format != eeprom.FormatRaw {
+		fmt.Printf("Detected %s dump, normalised to %d bytes\n", format, len(data))
+	}
+
+	s, err := store.OpenDefault()
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+
+	source := store.Source{
+		Timestamp: time.Now(),
+		Method:    "import",
+		Filename:  c.File,
+		Format:    format,
+	}
+
+	hash, isNew, err := s.Import(data, source)
+	if err != nil {
+		return fmt.Errorf("failed to import: %w", err)
+	}
+
+	if isNew {
+		fmt.Printf("Imported new profile: %s\n", store.ShortHash(hash))
+	} else {
+		fmt.Printf("Profile already exists: %s (added source)\n", store.ShortHash(hash))
+	}
+
+	// Exported documents carry tags and notes along
+	if docMeta := store.DocumentMetadata(input); docMeta != nil {
+		merged := false
+		err := s.UpdateMetadata(hash, func(meta *store.Metadata) error {
+			merged = meta.MergeAnnotations(docMeta)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to merge tags and notes: %w", err)
+		}
+		if merged {
+			fmt.Println("Merged tags and notes from document")
+		}
+	}
+
+	// Show summary
+	meta, _ := s.GetMetadata(hash)
+	if meta != nil {
+		fmt.Printf("  Vendor: %s\n", meta.Identity.VendorName)
+		fmt.Printf("  Part:   %s\n", meta.Identity.PartNumber)
+		fmt.Printf("  S/N:    %s\n", meta.Identity.SerialNumber)
+	}
+
+	return nil
+}
+
+type StoreTagCmd struct {
+	Hash string   `arg:"" help:"Profile hash (full or short)"`
+	Tags []string `arg:"" help:"Tags to add"`
+}
+
+func (c *StoreTagCmd) Run(globals *CLI) error {
+	config.Verbose = globals.Verbose
+
+	s, hash, err := openProfile(c.Hash)
+	if err != nil {
+		return err
+	}
+	var tags []string
+	err = s.UpdateMetadata(hash, func(meta *store.Metadata) error {
+		meta.AddTags(c.Tags...)
+		tags = meta.Tags
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s tags: %s\n", store.ShortHash(hash), strings.Join(tags, ", "))
+	return nil
+}
+
+type StoreUntagCmd struct {
+	Hash string   `arg:"" help:"Profile hash (full or short)"`
+	Tags []string `arg:"" help:"Tags to remove"`
+}
+
+func (c *StoreUntagCmd) Run(globals *CLI) error {
 	config.Verbose = globals.Verbose
-	device := ble.Connect()
-	defer device.Disconnect()
-	commands.SupportDump(device)
+
+	s, hash, err := openProfile(c.Hash)
+	if err != nil {
+		return err
+	}
+	var tags []string
+	err = s.UpdateMetadata(hash, func(meta *store.Metadata) error {
+		if len(meta.RemoveTags(c.Tags...)) == 0 {
+			return fmt.Errorf("%s has none of those tags", store.ShortHash(hash))
+		}
+		tags = meta.Tags
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		fmt.Printf("%s has no tags\n", store.ShortHash(hash))
+	} else {
+		fmt.Printf("%s tags: %s\n", store.ShortHash(hash), strings.Join(tags, ", "))
+	}
 	return nil
 }
 
-type SupportLogsCmd struct{}
+type StoreNoteCmd struct {
+	Hash  string   `arg:"" help:"Profile hash (full or short)"`
+	Text  []string `arg:"" optional:"" help:"Note text (omit to list notes)"`
+	Clear bool     `help:"Remove all notes from the profile"`
+}
 
-func (c *SupportLogsCmd) Run(globals *CLI) error {
+func (c *StoreNoteCmd) Run(globals *CLI) error {
 	config.Verbose = globals.Verbose
-	device := ble.Connect()
-	defer device.Disconnect()
-	commands.Logs(device)
+
+	s, hash, err := openProfile(c.Hash)
+	if err != nil {
+		return err
+	}
+	text := strings.TrimSpace(strings.Join(c.Text, " "))
+
+	if text == "" && !c.Clear {
+		meta, err := s.GetMetadata(hash)
+		if err != nil {
+			return fmt.Errorf("failed to get metadata: %w", err)
+		}
+		if len(meta.Notes) == 0 {
+			fmt.Printf("%s has no notes\n", store.ShortHash(hash))
+		}
+		printNotes(meta.Notes)
+		return nil
+	}
+
+	return s.UpdateMetadata(hash, func(meta *store.Metadata) error {
+		if c.Clear {
+			meta.Notes = nil
+		}
+		if text != "" {
+			meta.AddNote(store.Note{Text: text, CreatedAt: time.Now()})
+		}
+		return nil
+	})
+}
+
+// openProfile opens the default store and resolves a profile hash.
+func openProfile(ref string) (*store.Store, string, error) {
+	s, err := store.OpenDefault()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open store: %w", err)
+	}
+	hash, err := s.Resolve(ref)
+	if err != nil {
+		return nil, "", err
+	}
+	return s, hash, nil
+}
+
+type StoreFsckCmd struct {
+	Repair bool `help:"Rewrite stale metadata, recreate missing metadata and rebuild the index"`
+}
+
+func (c *StoreFsckCmd) Run(globals *CLI) error {
+	config.Verbose = globals.Verbose
+
+	s, err := store.OpenDefault()
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+
+	issues, err := s.Fsck(c.Repair)
+	if err != nil {
+		return fmt.Errorf("fsck failed: %w", err)
+	}
+	if len(issues) == 0 {
+		fmt.Println("Store is consistent.")
+		return nil
+	}
+
+	unfixed := 0
+	for _, issue := range issues {
+		status := ""
+		if issue.Fixed {
+			status = " (fixed)"
+		} else {
+			unfixed++
+		}
+		hash := "-"
+		if issue.Hash != "" {
+			hash = store.ShortHash(issue.Hash)
+		}
+		fmt.Printf("  %-12s  %-16s  %s%s\n", hash, issue.Kind, issue.Detail, status)
+	}
+
+	fmt.Printf("\n%d issue(s)", len(issues))
+	if c.Repair {
+		fmt.Printf(", %d fixed", len(issues)-unfixed)
+	}
+	fmt.Println()
+	if unfixed > 0 {
+		if !c.Repair {
+			fmt.Println("Run with --repair to fix metadata and the index; use store rm and store gc for the rest.")
+		}
+		return fmt.Errorf("%d issue(s) remain", unfixed)
+	}
 	return nil
 }
 
-// --- Debug Commands ---
+type StoreReindexCmd struct{}
 
-type DebugCmd struct {
-	Explore     DebugExploreCmd     `cmd:"" help:"List all BLE services and characteristics"`
-	DumpAll     DebugDumpAllCmd     `cmd:"" name:"dump-all" help:"Dump all read-only API responses as raw JSON"`
-	TestEncode  DebugTestEncodeCmd  `cmd:"" name:"test-encode" help:"Test protocol encoding"`
-	TestPackets DebugTestPacketsCmd `cmd:"" name:"test-packets" help:"Decode packets from TSV file"`
-	ParseEeprom DebugParseEepromCmd `cmd:"" name:"parse-eeprom" help:"Parse SFP/QSFP EEPROM file"`
-	RawAPI      DebugRawAPICmd      `cmd:"" name:"raw-api" help:"Send raw API request"`
+func (c *StoreReindexCmd) Run(globals *CLI) error {
+	config.Verbose = globals.Verbose
+
+	s, err := store.OpenDefault()
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	n, err := s.Reindex()
+	if err != nil {
+		return fmt.Errorf("failed to rebuild index: %w", err)
+	}
+	fmt.Printf("Indexed %d profile(s)\n", n)
+	return nil
 }
 
-type DebugExploreCmd struct{}
+type StoreRmCmd struct {
+	Hashes []string `arg:"" help:"Profile hashes (full or short)"`
+	Yes    bool     `short:"y" help:"Do not ask for confirmation"`
+}
 
-func (c *DebugExploreCmd) Run(globals *CLI) error {
+func (c *StoreRmCmd) Run(globals *CLI) error {
 	config.Verbose = globals.Verbose
-	device := ble.Connect()
-	defer device.Disconnect()
-	commands.Explore(device)
+
+	s, err := store.OpenDefault()
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+
+	var hashes []string
+	for _, ref := range c.Hashes {
+		hash, err := s.Resolve(ref)
+		if err != nil {
+			return err
+		}
+		hashes = append(hashes, hash)
+		if meta, err := s.GetMetadata(hash); err == nil {
+			fmt.Printf("  %s  %s %s S/N %s (%d source(s), %d variant(s))\n", store.ShortHash(hash),
+				meta.Identity.VendorName, meta.Identity.PartNumber, meta.Identity.SerialNumber,
+				len(meta.Sources), len(meta.Variants))
+		}
+	}
+
+	if !c.Yes && !commands.ConfirmAction(fmt.Sprintf("Remove %d profile(s)? Type 'yes' to continue: ", len(hashes))) {
+		return fmt.Errorf("cancelled")
+	}
+
+	for _, hash := range hashes {
+		if err := s.Remove(hash); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", store.ShortHash(hash), err)
+		}
+		fmt.Printf("Removed %s\n", store.ShortHash(hash))
+	}
+	return nil
+}
+
+type StoreGcCmd struct {
+	DryRun         bool `help:"List what would be removed without removing it"`
+	OrphanProfiles bool `help:"Also remove profile data that has no metadata, instead of keeping it for store fsck --repair"`
+}
+
+func (c *StoreGcCmd) Run(globals *CLI) error {
+	config.Verbose = globals.Verbose
+
+	s, err := store.OpenDefault()
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+
+	removed, kept, err := s.GC(c.DryRun, c.OrphanProfiles)
+	if err != nil {
+		return fmt.Errorf("gc failed: %w", err)
+	}
+	for _, path := range removed {
+		fmt.Printf("  %s\n", path)
+	}
+	if c.DryRun {
+		fmt.Printf("Would remove %d item(s)\n", len(removed))
+	} else {
+		fmt.Printf("Removed %d item(s)\n", len(removed))
+	}
+	if len(kept) > 0 {
+		fmt.Printf("\nKept %d profile(s) without metadata:\n", len(kept))
+		for _, hash := range kept {
+			fmt.Printf("  %s\n", store.ShortHash(hash))
+		}
+		fmt.Println("Run 'store fsck --repair' to recover them, or 'store gc --orphan-profiles' to remove them.")
+	}
+	return nil
+}
+
+type StorePackCmd struct {
+	Query  []string `arg:"" optional:"" help:"Search expression selecting profiles (see store find; default: all)"`
+	Output string   `short:"o" required:"" help:"Bundle file (.tar.zst, .tar.gz or .tar)"`
+	Strip  bool     `help:"Blank serial numbers and drop device MACs and filenames for external sharing"`
+}
+
+func (c *StorePackCmd) Run(globals *CLI) error {
+	config.Verbose = globals.Verbose
+
+	q, err := store.ParseQuery(strings.Join(c.Query, " "))
+	if err != nil {
+		return fmt.Errorf("invalid query: %w", err)
+	}
+	s, err := store.OpenDefault()
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	profiles, err := s.Find(q)
+	if err != nil {
+		return fmt.Errorf("failed to search profiles: %w", err)
+	}
+	if len(profiles) == 0 {
+		return fmt.Errorf("no profiles match")
+	}
+	hashes := make([]string, 0, len(profiles))
+	for hash := range profiles {
+		hashes = append(hashes, hash)
+	}
+
+	f, err := os.Create(c.Output)
+	if err != nil {
+		return fmt.Errorf("failed to create bundle: %w", err)
+	}
+	manifest, err := s.Pack(f, hashes, store.BundleCompression(c.Output), c.Strip)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(c.Output)
+		return fmt.Errorf("failed to pack: %w", err)
+	}
+
+	variants := 0
+	for _, entry := range manifest.Profiles {
+		variants += len(entry.Blobs)
+	}
+	fmt.Printf("Packed %d profile(s), %d variant(s) to %s\n", len(manifest.Profiles), variants, c.Output)
+	if c.Strip {
+		fmt.Println("Serial numbers, device MACs and filenames were stripped")
+	}
+	return nil
+}
+
+type StoreUnpackCmd struct {
+	Bundle string `arg:"" help:"Bundle file written by store pack"`
+}
+
+func (c *StoreUnpackCmd) Run(globals *CLI) error {
+	config.Verbose = globals.Verbose
+
+	f, err := os.Open(c.Bundle)
+	if err != nil {
+		return fmt.Errorf("failed to open bundle: %w", err)
+	}
+	defer f.Close()
+
+	s, err := store.OpenDefault()
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	result, err := s.Unpack(f)
+	if err != nil {
+		return fmt.Errorf("failed to unpack: %w", err)
+	}
+
+	for _, hash := range result.New {
+		fmt.Printf("  %s  new\n", store.ShortHash(hash))
+	}
+	for _, hash := range result.Merged {
+		fmt.Printf("  %s  merged\n", store.ShortHash(hash))
+	}
+	for _, entry := range result.Manifest.Profiles {
+		for _, conflict := range result.Conflicts[entry.Hash] {
+			fmt.Printf("  %s  conflict: %s\n", store.ShortHash(entry.Hash), conflict)
+		}
+	}
+	fmt.Printf("\n%d new, %d merged, %d unchanged, %d with conflicts\n",
+		len(result.New), len(result.Merged), len(result.Unchanged), len(result.Conflicts))
+	return nil
+}
+
+type StoreServeCmd struct {
+	Listen string `help:"Address to listen on" default:"127.0.0.1:8780"`
+	Token  string `help:"Require this bearer token on every request" env:"SFPW_STORE_TOKEN"`
+}
+
+func (c *StoreServeCmd) Run(globals *CLI) error {
+	config.Verbose = globals.Verbose
+
+	s, err := store.OpenDefault()
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	fmt.Printf("Serving store on http://%s/api/v1/profiles\n", c.Listen)
+	server := &http.Server{
+		Addr:              c.Listen,
+		Handler:           s.Handler(c.Token),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return server.ListenAndServe()
+}
+
+type StorePushCmd struct {
+	URL   string `arg:"" help:"Store server URL, e.g. http://host:8780"`
+	Token string `help:"Bearer token for the server" env:"SFPW_STORE_TOKEN"`
+	Full  bool   `help:"Push every profile, not just those changed since the last push"`
+}
+
+func (c *StorePushCmd) Run(globals *CLI) error {
+	config.Verbose = globals.Verbose
+
+	s, err := store.OpenDefault()
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	result, err := s.Push(store.NewRemote(c.URL, c.Token), c.Full)
+	if result != nil && (err == nil || result.Unchanged+len(result.New)+len(result.Merged) > 0) {
+		printSyncResult(result)
+	}
+	if err != nil {
+		return fmt.Errorf("push failed: %w", err)
+	}
+	return nil
+}
+
+type StorePullCmd struct {
+	URL   string `arg:"" help:"Store server URL, e.g. http://host:8780"`
+	Token string `help:"Bearer token for the server" env:"SFPW_STORE_TOKEN"`
+	Full  bool   `help:"Pull every profile, not just those changed since the last pull"`
+}
+
+func (c *StorePullCmd) Run(globals *CLI) error {
+	config.Verbose = globals.Verbose
+
+	s, err := store.OpenDefault()
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	result, err := s.Pull(store.NewRemote(c.URL, c.Token), c.Full)
+	if result != nil && (err == nil || result.Unchanged+len(result.New)+len(result.Merged) > 0) {
+		printSyncResult(result)
+	}
+	if err != nil {
+		return fmt.Errorf("pull failed: %w", err)
+	}
+	return nil
+}
+
+func printSyncResult(result *store.SyncResult) {
+	for _, hash := range result.New {
+		fmt.Printf("  %s  new\n", store.ShortHash(hash))
+	}
+	for _, hash := range result.Merged {
+		fmt.Printf("  %s  merged\n", store.ShortHash(hash))
+	}
+	for hash, conflicts := range result.Conflicts {
+		for _, conflict := range conflicts {
+			fmt.Printf("  %s  conflict: %s\n", store.ShortHash(hash), conflict)
+		}
+	}
+	fmt.Printf("%d new, %d merged, %d unchanged\n", len(result.New), len(result.Merged), result.Unchanged)
+}
+
+type StoreExportCmd struct {
+	Hash   string `arg:"" help:"Profile hash (full or short), or 'all' with --format csv"`
+	Output string `arg:"" optional:"" help:"Output file path (default: stdout)"`
+	Format string `help:"Output format" enum:"raw,intel-hex,ethtool,json,yaml,csv" default:"raw" short:"f"`
+}
+
+func (c *StoreExportCmd) Run(globals *CLI) error {
+	config.Verbose = globals.Verbose
+
+	s, err := store.OpenDefault()
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+
+	// Render first so a bad hash or format leaves an existing output file alone
+	var buf bytes.Buffer
+	if c.Hash == "all" {
+		if c.Format != store.ExportCSV {
+			return fmt.Errorf("'all' is only supported with --format csv")
+		}
+		metas, err := s.AllMetadata()
+		if err != nil {
+			return err
+		}
+		if err := store.WriteCSV(&buf, metas); err != nil {
+			return fmt.Errorf("failed to export: %w", err)
+		}
+	} else {
+		fullHash, err := s.Resolve(c.Hash)
+		if err != nil {
+			return err
+		}
+		data, err := s.Get(fullHash)
+		if err != nil {
+			return fmt.Errorf("failed to read profile: %w", err)
+		}
+		meta, _ := s.GetMetadata(fullHash)
+		if err := store.WriteProfile(&buf, c.Format, data, meta); err != nil {
+			return fmt.Errorf("failed to export: %w", err)
+		}
+	}
+
+	if c.Output == "" {
+		_, err := os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	if err := os.WriteFile(c.Output, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	fmt.Printf("Exported to: %s\n", c.Output)
 	return nil
 }
 
-type DebugDumpAllCmd struct{}
+type StoreReportCmd struct {
+	Format string `help:"Output format" enum:"table,csv,markdown,html" default:"table" short:"f"`
+	Output string `help:"Output file path (default: stdout)" short:"o"`
+	Since  string `help:"Only modules with a source on or after this date (YYYY-MM-DD or RFC 3339)"`
+	Until  string `help:"Only modules with a source on or before this date (YYYY-MM-DD or RFC 3339)"`
+	Device string `help:"Only modules read by this device MAC"`
+	Tag    string `help:"Only modules with this tag"`
+}
 
-func (c *DebugDumpAllCmd) Run(globals *CLI) error {
+func (c *StoreReportCmd) Run(globals *CLI) error {
 	config.Verbose = globals.Verbose
-	device := ble.Connect()
-	defer device.Disconnect()
-	commands.DumpAll(device)
-	return nil
-}
 
-type DebugTestEncodeCmd struct{}
+	filter := store.ReportFilter{DeviceMAC: c.Device, Tag: c.Tag}
+	var err error
+	if c.Since != "" {
+		if filter.Since, _, err = parseDate(c.Since); err != nil {
+			return err
+		}
+	}
+	if c.Until != "" {
+		var dateOnly bool
+		if filter.Until, dateOnly, err = parseDate(c.Until); err != nil {
+			return err
+		}
+		if dateOnly {
+			filter.Until = filter.Until.AddDate(0, 0, 1) // Include the whole day
+		}
+	}
 
-func (c *DebugTestEncodeCmd) Run(globals *CLI) error {
-	config.Verbose = globals.Verbose
-	commands.TestEncode()
-	return nil
-}
+	s, err := store.OpenDefault()
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	metas, err := s.AllMetadata()
+	if err != nil {
+		return err
+	}
 
-type DebugTestPacketsCmd struct {
-	File string `arg:"" help:"TSV file containing packet data"`
-}
+	out := os.Stdout
+	if c.Output != "" {
+		f, err := os.Create(c.Output)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer f.Close()
+		out = f
+	}
 
-func (c *DebugTestPacketsCmd) Run(globals *CLI) error {
-	config.Verbose = globals.Verbose
-	commands.TestPackets(c.File)
-	return nil
+	return store.BuildReport(metas, filter).Write(out, c.Format)
 }
 
-type DebugParseEepromCmd struct {
-	File string `arg:"" help:"EEPROM binary file to parse"`
+// parseDate parses a YYYY-MM-DD date in local time or an RFC 3339 timestamp.
+// Returns true if only a date was given.
+func parseDate(s string) (time.Time, bool, error) {
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid date %q (use YYYY-MM-DD or RFC 3339)", s)
+	}
+	return t, false, nil
 }
 
-func (c *DebugParseEepromCmd) Run(globals *CLI) error {
-	config.Verbose = globals.Verbose
-	commands.ParseEEPROM(c.File)
-	return nil
+// --- EEPROM Commands ---
+
+type EepromCmd struct {
+	Lint  EepromLintCmd  `cmd:"" help:"Check an EEPROM image for SFF-8472/8636 spec violations"`
+	Build EepromBuildCmd `cmd:"" help:"Generate an EEPROM image from a YAML spec"`
 }
 
-type DebugRawAPICmd struct {
-	Method string `arg:"" help:"HTTP method (GET or POST)"`
-	Path   string `arg:"" help:"API path (e.g., /xsfp/sync/cancel)"`
-	Body   string `optional:"" help:"Request body (optional)"`
+type EepromLintCmd struct {
+	FileOrProfile string `arg:"" help:"EEPROM file path or store profile hash"`
+	JSON          bool   `help:"Output as JSON" short:"j"`
 }
 
-func (c *DebugRawAPICmd) Run(globals *CLI) error {
+func (c *EepromLintCmd) Run(globals *CLI) error {
 	config.Verbose = globals.Verbose
-	device := ble.Connect()
-	defer device.Disconnect()
-	commands.RawAPI(device, c.Method, c.Path, c.Body)
-	return nil
-}
 
-// --- Store Commands ---
+	data, name, err := loadEEPROM(c.FileOrProfile)
+	if err != nil {
+		return err
+	}
 
-type StoreCmd struct {
-	List   StoreListCmd   `cmd:"" help:"List all stored module profiles"`
-	Show   StoreShowCmd   `cmd:"" help:"Show details of a stored profile"`
-	Import StoreImportCmd `cmd:"" help:"Import an EEPROM file into the store"`
-	Export StoreExportCmd `cmd:"" help:"Export a profile to a file"`
+	findings := eeprom.Lint(data)
+
+	if c.JSON {
+		if findings == nil {
+			findings = []eeprom.Finding{}
+		}
+		out, _ := json.MarshalIndent(findings, "", "  ")
+		fmt.Println(string(out))
+	} else {
+		fmt.Printf("%s (%d bytes)\n", name, len(data))
+		if len(findings) == 0 {
+			fmt.Println("No problems found.")
+		} else {
+			printLintFindings(findings)
+		}
+	}
+
+	if eeprom.HasErrors(findings) {
+		return fmt.Errorf("EEPROM has lint errors")
+	}
+	return nil
 }
 
-type StoreListCmd struct{}
+type EepromBuildCmd struct {
+	Spec   string `arg:"" help:"YAML spec file"`
+	Output string `help:"Output file path" short:"o" required:""`
+	Force  bool   `help:"Write the image even if it has lint errors"`
+}
 
-func (c *StoreListCmd) Run(globals *CLI) error {
+func (c *EepromBuildCmd) Run(globals *CLI) error {
 	config.Verbose = globals.Verbose
 
-	s, err := store.OpenDefault()
+	input, err := os.ReadFile(c.Spec)
 	if err != nil {
-		return fmt.Errorf("failed to open store: %w", err)
+		return fmt.Errorf("failed to read spec: %w", err)
 	}
-
-	profiles, err := s.ListWithHashes()
+	spec, err := eeprom.ParseSpec(input)
 	if err != nil {
-		return fmt.Errorf("failed to list profiles: %w", err)
+		return err
 	}
 
-	if len(profiles) == 0 {
-		fmt.Println("No profiles in store.")
-		fmt.Println("Import profiles with: sfpw store import <eeprom.bin>")
-		return nil
+	base, ok := eeprom.Blank(spec.Base)
+	name := "blank " + spec.Base
+	if !ok {
+		// Relative base paths are resolved against the spec file first
+		ref := spec.Base
+		if rel := filepath.Join(filepath.Dir(c.Spec), ref); !filepath.IsAbs(ref) {
+			if _, err := os.Stat(rel); err == nil {
+				ref = rel
+			}
+		}
+		base, name, err = loadEEPROM(ref)
+		if err != nil {
+			return fmt.Errorf("base: %w", err)
+		}
 	}
 
-	fmt.Printf("%d profile(s)\n\n", len(profiles))
-	for hash, entry := range profiles {
-		shortHash := store.ShortHash(hash)
-		wavelength := ""
-		if entry.WavelengthNM > 0 {
-			wavelength = fmt.Sprintf("%dnm", entry.WavelengthNM)
-		}
-		fmt.Printf("  %-12s  %-16s  %-16s  %s\n",
-			shortHash,
-			truncate(entry.VendorName, 16),
-			truncate(entry.PartNumber, 16),
-			wavelength)
+	data, err := eeprom.Build(base, spec)
+	if err != nil {
+		return err
 	}
 
-	return nil
-}
+	findings := eeprom.Lint(data)
+	if len(findings) > 0 {
+		fmt.Println("Lint findings:")
+		printLintFindings(findings)
+	}
+	if eeprom.HasErrors(findings) && !c.Force {
+		return fmt.Errorf("built image has lint errors (use --force to write anyway)")
+	}
 
-type StoreShowCmd struct {
-	Hash string `arg:"" help:"Profile hash (full or short)"`
+	if err := os.WriteFile(c.Output, data, 0644); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	fmt.Printf("Built %d-byte image from %s: %s\n", len(data), name, c.Output)
+	return nil
 }
 
-func (c *StoreShowCmd) Run(globals *CLI) error {
-	config.Verbose = globals.Verbose
+// loadEEPROM reads EEPROM data from a file, falling back to a store profile hash.
+// Files may be raw dumps or any hex dump format understood by eeprom.Decode.
+// Returns the normalised data and a display name for its origin.
+func loadEEPROM(fileOrProfile string) ([]byte, string, error) {
+	if _, err := os.Stat(fileOrProfile); err == nil {
+		input, err := os.ReadFile(fileOrProfile)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read file: %w", err)
+		}
+		data, format, err := eeprom.Decode(input)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to decode %s: %w", fileOrProfile, err)
+		}
+		if format != eeprom.FormatRaw {
+			return data, fmt.Sprintf("%s (%s)", fileOrProfile, format), nil
+		}
+		return data, fileOrProfile, nil
+	}
 
 	s, err := store.OpenDefault()
 	if err != nil {
-		return fmt.Errorf("failed to open store: %w", err)
+		return nil, "", fmt.Errorf("failed to open store: %w", err)
 	}
-
-	// Try to find matching hash
-	profiles, err := s.ListWithHashes()
+	ref, variant, hasVariant := strings.Cut(fileOrProfile, "@")
+	hash, err := s.Resolve(ref)
 	if err != nil {
-		return fmt.Errorf("failed to list profiles: %w", err)
+		return nil, "", fmt.Errorf("not found: %s (not a file or store profile)", fileOrProfile)
 	}
+	name := "store profile " + store.ShortHash(hash)
 
-	var fullHash string
-	for hash := range profiles {
-		if hash == c.Hash || store.ShortHash(hash) == c.Hash || hash[7:] == c.Hash {
-			fullHash = hash
-			break
+	var data []byte
+	if hasVariant {
+		var blob string
+		data, blob, err = s.GetVariant(hash, variant)
+		if err != nil {
+			return nil, "", err
 		}
+		name += " variant " + store.ShortHash(blob)
+	} else if data, err = s.Get(hash); err != nil {
+		return nil, "", fmt.Errorf("failed to read profile: %w", err)
+	}
+	if meta, err := s.GetMetadata(hash); err == nil {
+		name = fmt.Sprintf("%s (%s %s)", name, meta.Identity.VendorName, meta.Identity.PartNumber)
 	}
+	return data, name, nil
+}
 
-	if fullHash == "" {
-		return fmt.Errorf("profile not found: %s", c.Hash)
+func printLintFindings(findings []eeprom.Finding) {
+	for _, f := range findings {
+		fmt.Printf("  %s\n", f)
 	}
+}
 
-	entry := profiles[fullHash]
-	meta, err := s.GetMetadata(fullHash)
+// --- Flash Dump Commands ---
+
+type FlashCmd struct {
+	Info    FlashInfoCmd    `cmd:"" help:"Show the partition table, OTA state and app images of a flash dump"`
+	App     FlashAppCmd     `cmd:"" help:"Extract an app image from a flash dump"`
+	Ls      FlashLsCmd      `cmd:"" help:"List files in the LittleFS partition of a flash dump"`
+	Extract FlashExtractCmd `cmd:"" help:"Extract files from the LittleFS partition of a flash dump"`
+}
+
+type FlashInfoCmd struct {
+	Dump string `arg:"" help:"Raw flash dump (starting at offset 0)" type:"existingfile"`
+}
+
+func (c *FlashInfoCmd) Run(globals *CLI) error {
+	img, err := flash.Open(c.Dump)
 	if err != nil {
-		return fmt.Errorf("failed to get metadata: %w", err)
+		return err
 	}
+	defer img.Close()
 
-	shortHash := store.ShortHash(fullHash)
-	fmt.Printf("Hash:        %s\n", shortHash)
-	fmt.Printf("Type:        %s\n", entry.ModuleType)
-	fmt.Printf("Vendor:      %s\n", entry.VendorName)
-	fmt.Printf("Part Number: %s\n", entry.PartNumber)
-	fmt.Printf("Serial:      %s\n", entry.SerialNumber)
-
-	if meta != nil {
-		if meta.Identity.DateCode != "" {
-			fmt.Printf("Date Code:   %s\n", meta.Identity.DateCode)
+	md5 := "none"
+	if img.HasMD5 {
+		md5 = "ok"
+		if !img.MD5Valid {
+			md5 = "MISMATCH"
 		}
-		if meta.Specs.WavelengthNM > 0 {
-			fmt.Printf("Wavelength:  %d nm\n", meta.Specs.WavelengthNM)
+	}
+	fmt.Printf("Flash dump: %s (%s)\n", c.Dump, humanizeBytes(img.Size()))
+	fmt.Printf("Partition table at 0x%x, %d partition(s), MD5 %s\n\n", flash.PartitionTableOffset, len(img.Partitions), md5)
+
+	boot, otaSel, bootErr := img.BootPartition()
+	fmt.Printf("  %-16s %-5s %-9s %-10s %-10s %s\n", "Label", "Type", "Subtype", "Offset", "Size", "Contents")
+	for i := range img.Partitions {
+		p := &img.Partitions[i]
+		contents := ""
+		switch {
+		case p.IsApp():
+			contents = describeApp(img, p)
+			if p == boot {
+				contents += " [boot]"
+			}
+		case p.Type == flash.TypeData && (p.Subtype == flash.SubtypeLittleFS || p.Subtype == flash.SubtypeSPIFFS):
+			if fs, _, err := img.LittleFS(p.Label); err == nil {
+				contents = fmt.Sprintf("LittleFS v%d.%d, %d blocks of %d bytes", fs.Version>>16, fs.Version&0xffff, fs.BlockCount, fs.BlockSize)
+			}
 		}
-		if meta.Specs.ConnectorType != "" {
-			fmt.Printf("Connector:   %s\n", meta.Specs.ConnectorType)
+		if p.Flags&flash.FlagEncrypted != 0 {
+			contents += " (encrypted)"
 		}
-		fmt.Printf("Sources:     %d\n", len(meta.Sources))
+		fmt.Printf("  %-16s %-5s %-9s 0x%08x %-10s %s\n", p.Label, p.TypeName(), p.SubtypeName(), p.Offset, humanizeBytes(int64(p.Size)), contents)
 	}
 
-	fmt.Printf("\nExport: sfpw store export %s <file>\n", shortHash)
-
+	fmt.Println()
+	switch {
+	case bootErr != nil:
+		fmt.Printf("Boot partition: %v\n", bootErr)
+	case boot == nil:
+		fmt.Println("Boot partition: none")
+	case otaSel != nil:
+		fmt.Printf("Boot partition: %s (otadata sequence %d, state %s)\n", boot.Label, otaSel.Seq, otaSel.StateName())
+	default:
+		fmt.Printf("Boot partition: %s (no otadata selection)\n", boot.Label)
+	}
 	return nil
 }
 
-type StoreImportCmd struct {
-	File string `arg:"" help:"EEPROM file to import"`
+// describeApp summarises the app image in a partition.
+func describeApp(img *flash.Image, p *flash.Partition) string {
+	app, err := img.AppImage(p)
+	if err != nil {
+		return "empty or invalid"
+	}
+	desc := fmt.Sprintf("%s image", app.ChipName())
+	if d, err := app.AppDescriptor(); err == nil {
+		desc = fmt.Sprintf("%s v%s", d.ProjectName, strings.TrimPrefix(d.Version, "v"))
+	}
+	if err := app.Verify(); err != nil {
+		desc += " (corrupt)"
+	}
+	return desc
 }
 
-func (c *StoreImportCmd) Run(globals *CLI) error {
-	config.Verbose = globals.Verbose
+type FlashAppCmd struct {
+	Dump      string `arg:"" help:"Raw flash dump (starting at offset 0)" type:"existingfile"`
+	Partition string `help:"App partition label (default: the one the bootloader selects)" short:"p"`
+	Output    string `help:"File to write the app image to" short:"o" required:""`
+}
 
-	data, err := os.ReadFile(c.File)
+func (c *FlashAppCmd) Run(globals *CLI) error {
+	img, err := flash.Open(c.Dump)
 	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
+		return err
 	}
+	defer img.Close()
 
-	s, err := store.OpenDefault()
-	if err != nil {
-		return fmt.Errorf("failed to open store: %w", err)
+	var p *flash.Partition
+	if c.Partition != "" {
+		if p = img.Find(c.Partition); p == nil {
+			return fmt.Errorf("no partition labelled %q", c.Partition)
+		}
+	} else if p, _, err = img.BootPartition(); err != nil {
+		return err
+	} else if p == nil {
+		return fmt.Errorf("no app partition found")
 	}
 
-	source := store.Source{
-		Timestamp: time.Now(),
-		Method:    "import",
-		Filename:  c.File,
+	app, err := img.AppImage(p)
+	if err != nil {
+		return fmt.Errorf("partition %s: %w", p.Label, err)
+	}
+	if err := app.Verify(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: partition %s: %v\n", p.Label, err)
 	}
 
-	hash, isNew, err := s.Import(data, source)
+	// Write the image itself, not the erased space after it
+	r, err := img.Reader(p)
 	if err != nil {
-		return fmt.Errorf("failed to import: %w", err)
+		return err
 	}
-
-	if isNew {
-		fmt.Printf("Imported new profile: %s\n", store.ShortHash(hash))
-	} else {
-		fmt.Printf("Profile already exists: %s (added source)\n", store.ShortHash(hash))
+	data := make([]byte, min(app.Size, int64(p.Size)))
+	if _, err := r.ReadAt(data, 0); err != nil {
+		return err
 	}
-
-	// Show summary
-	meta, _ := s.GetMetadata(hash)
-	if meta != nil {
-		fmt.Printf("  Vendor: %s\n", meta.Identity.VendorName)
-		fmt.Printf("  Part:   %s\n", meta.Identity.PartNumber)
-		fmt.Printf("  S/N:    %s\n", meta.Identity.SerialNumber)
+	if err := os.WriteFile(c.Output, data, 0644); err != nil {
+		return err
 	}
-
+	fmt.Printf("Wrote %s from partition %s (%s)\n", c.Output, p.Label, humanizeBytes(int64(len(data))))
 	return nil
 }
 
-type StoreExportCmd struct {
-	Hash   string `arg:"" help:"Profile hash (full or short)"`
-	Output string `arg:"" help:"Output file path"`
+type FlashLsCmd struct {
+	Dump      string `arg:"" help:"Raw flash dump (starting at offset 0)" type:"existingfile"`
+	Path      string `arg:"" optional:"" help:"Directory to list" default:"/"`
+	Recursive bool   `help:"List subdirectories too" short:"r"`
+	Partition string `help:"Filesystem partition label (default: the first littlefs or spiffs partition)" short:"p"`
+	JSON      bool   `help:"Output as JSON" short:"j"`
 }
 
-func (c *StoreExportCmd) Run(globals *CLI) error {
-	config.Verbose = globals.Verbose
-
-	s, err := store.OpenDefault()
+func (c *FlashLsCmd) Run(globals *CLI) error {
+	img, err := flash.Open(c.Dump)
 	if err != nil {
-		return fmt.Errorf("failed to open store: %w", err)
+		return err
+	}
+	defer img.Close()
+	fs, _, err := img.LittleFS(c.Partition)
+	if err != nil {
+		return err
 	}
 
-	// Try to find matching hash
-	profiles, err := s.ListWithHashes()
+	var files []flash.FileInfo
+	if c.Recursive {
+		err = fs.Walk(c.Path, func(info flash.FileInfo) error {
+			files = append(files, info)
+			return nil
+		})
+	} else {
+		files, err = fs.ReadDir(c.Path)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to list profiles: %w", err)
+		return err
 	}
 
-	var fullHash string
-	for hash := range profiles {
-		if hash == c.Hash || store.ShortHash(hash) == c.Hash || hash[7:] == c.Hash {
-			fullHash = hash
-			break
+	if c.JSON {
+		data, _ := json.MarshalIndent(files, "", "  ")
+		fmt.Println(string(data))
+		return nil
+	}
+	for _, f := range files {
+		if f.IsDir {
+			fmt.Printf("  %10s  %s/\n", "-", f.Path)
+		} else {
+			fmt.Printf("  %10d  %s\n", f.Size, f.Path)
 		}
 	}
+	return nil
+}
 
-	if fullHash == "" {
-		return fmt.Errorf("profile not found: %s", c.Hash)
+type FlashExtractCmd struct {
+	Dump      string `arg:"" help:"Raw flash dump (starting at offset 0)" type:"existingfile"`
+	Path      string `arg:"" optional:"" help:"File or directory to extract" default:"/"`
+	Output    string `help:"Directory to extract into" short:"o" default:"."`
+	Partition string `help:"Filesystem partition label (default: the first littlefs or spiffs partition)" short:"p"`
+}
+
+func (c *FlashExtractCmd) Run(globals *CLI) error {
+	img, err := flash.Open(c.Dump)
+	if err != nil {
+		return err
+	}
+	defer img.Close()
+	fs, _, err := img.LittleFS(c.Partition)
+	if err != nil {
+		return err
 	}
 
-	if err := s.Export(fullHash, c.Output); err != nil {
-		return fmt.Errorf("failed to export: %w", err)
+	root, err := fs.Stat(c.Path)
+	if err != nil {
+		return err
+	}
+	files := []flash.FileInfo{*root}
+	if root.IsDir {
+		files = nil
+		if err := fs.Walk(root.Path, func(info flash.FileInfo) error {
+			files = append(files, info)
+			return nil
+		}); err != nil {
+			return err
+		}
 	}
 
-	fmt.Printf("Exported to: %s\n", c.Output)
+	count := 0
+	for _, f := range files {
+		// Paths keep their full LittleFS location under the output directory
+		dest := filepath.Join(c.Output, filepath.FromSlash(strings.TrimPrefix(f.Path, "/")))
+		if f.IsDir {
+			if err := os.MkdirAll(dest, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		data, err := fs.Read(&f)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(dest, data, 0644); err != nil {
+			return err
+		}
+		if globals.Verbose {
+			fmt.Printf("  %s\n", f.Path)
+		}
+		count++
+	}
+	fmt.Printf("Extracted %d file(s) to %s\n", count, c.Output)
 	return nil
 }
 
@@ -1186,8 +3450,7 @@ func (c *FwUpdateLegacyCmd) Run(globals *CLI) error {
 	config.Verbose = globals.Verbose
 	device := ble.Connect()
 	defer device.Disconnect()
-	commands.FirmwareUpdate(device, c.File)
-	return nil
+	return commands.FirmwareUpdate(device, c.File, false)
 }
 
 type FwAbortLegacyCmd struct{}
@@ -0,0 +1,323 @@
+package commands
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vitaminmoo/sfpw-tool/internal/api"
+	"github.com/vitaminmoo/sfpw-tool/internal/config"
+	"github.com/vitaminmoo/sfpw-tool/internal/eeprom"
+	"github.com/vitaminmoo/sfpw-tool/internal/store"
+
+	"tinygo.org/x/bluetooth"
+)
+
+// CloneBatchOptions configures a batch clone run.
+type CloneBatchOptions struct {
+	Source        []byte // Base EEPROM image
+	SourceName    string // Display name of the base image
+	SerialPattern string // Serial template, e.g. "ABC{seq:05}"
+	Start         int    // First sequence number (ignored when resuming past it)
+	Count         int    // Modules to provision, 0 for no limit
+	LogPath       string // CSV run log, also used to resume
+	PollInterval  time.Duration
+}
+
+// Clone run log statuses
+const (
+	cloneStatusOK       = "ok"
+	cloneStatusMismatch = "mismatch"
+	cloneStatusSkipped  = "skipped"
+)
+
+var cloneLogHeader = []string{"timestamp", "pattern", "seq", "serial", "source_hash", "profile_hash", "device_mac", "previous_serial", "status", "diff_pages"}
+
+var seqRe = regexp.MustCompile(`\{seq(?::(0?)(\d+))?\}`)
+
+// FormatSerial expands the {seq}, {seq:N} or {seq:0N} placeholder in pattern.
+func FormatSerial(pattern string, seq int) (string, error) {
+	locs := seqRe.FindAllStringSubmatchIndex(pattern, -1)
+	if len(locs) != 1 {
+		return "", fmt.Errorf("serial pattern %q must contain exactly one {seq} placeholder", pattern)
+	}
+	m := seqRe.FindStringSubmatch(pattern)
+	verb := "%" + m[1] + m[2] + "d"
+	return pattern[:locs[0][0]] + fmt.Sprintf(verb, seq) + pattern[locs[0][1]:], nil
+}
+
+// CloneBatch writes the source image to a series of modules, giving each a
+// unique serial number from the pattern. Each successfully verified module is
+// imported into the store and appended to the run log; rerunning with the
+// same source, pattern and log resumes after the last verified sequence number.
+func CloneBatch(device bluetooth.Device, opts CloneBatchOptions) error {
+	sourceHash, err := store.ContentHash(opts.Source)
+	if err != nil {
+		return err
+	}
+	if _, err := FormatSerial(opts.SerialPattern, opts.Start); err != nil {
+		return err
+	}
+	if opts.PollInterval == 0 {
+		opts.PollInterval = time.Second
+	}
+
+	seq := opts.Start
+	done := make(map[string]bool) // Serials already provisioned in this run
+	rows, err := readCloneLog(opts.LogPath)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if row.pattern != opts.SerialPattern || row.sourceHash != sourceHash || row.status != cloneStatusOK {
+			continue
+		}
+		done[row.serial] = true
+		if row.seq >= seq {
+			seq = row.seq + 1
+		}
+	}
+	if seq != opts.Start {
+		fmt.Printf("Resuming at sequence %d (%d modules already provisioned in %s)\n", seq, len(done), opts.LogPath)
+	}
+
+	s, err := store.OpenDefault()
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+
+	client := api.New(device)
+	if err := client.Connect(); err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	if err := CancelXSFPSync(client.Context()); err != nil {
+		return err
+	}
+
+	fmt.Printf("Cloning %s (%s)\n", opts.SourceName, store.ShortHash(sourceHash))
+	fmt.Printf("Serial pattern: %s, run log: %s\n", opts.SerialPattern, opts.LogPath)
+
+	stdin := bufio.NewReader(os.Stdin)
+	lastSerial := ""
+	provisioned := 0
+
+	for opts.Count == 0 || provisioned < opts.Count {
+		serial, err := FormatSerial(opts.SerialPattern, seq)
+		if err != nil {
+			return err
+		}
+		image, err := eeprom.Build(opts.Source, &eeprom.Spec{SerialNumber: &serial})
+		if err != nil {
+			return fmt.Errorf("failed to build image for %s: %w", serial, err)
+		}
+
+		fmt.Printf("\n[%d] Insert a module for serial %s...\n", seq, serial)
+		details, err := waitForModule(client, opts.PollInterval, func(d *api.ModuleDetails) bool {
+			if d.SN == lastSerial {
+				return false // Still the module we just wrote
+			}
+			if done[d.SN] {
+				fmt.Printf("Module %s was already provisioned in this run, insert the next one\n", d.SN)
+				lastSerial = d.SN
+				return false
+			}
+			return true
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Detected %s %s (S/N %s)\n", details.Vendor, details.PartNumber, details.SN)
+		lastSerial = details.SN
+
+		if details.Type != "" && (details.Type == "qsfp") != (len(image) == eeprom.QSFPSize) {
+			return fmt.Errorf("inserted %s module does not match the %d-byte source image", details.Type, len(image))
+		}
+
+		before, err := client.ReadModule()
+		if err != nil {
+			return fmt.Errorf("failed to read module before write: %w", err)
+		}
+		if err := client.WriteSnapshot(image); err != nil {
+			return fmt.Errorf("failed to write snapshot: %w", err)
+		}
+		fmt.Printf("Snapshot loaded with serial %s.\n", serial)
+
+		fmt.Print("Press Write on the device, then Enter to verify (s = skip, q = quit): ")
+		answer, err := stdin.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "q":
+			fmt.Println("Stopped.")
+			return nil
+		case "s":
+			fmt.Println("Skipped.")
+			if err := appendCloneLog(opts.LogPath, cloneLogRow{opts.SerialPattern, seq, serial, sourceHash, "", client.MAC(), details.SN, cloneStatusSkipped, nil}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		data, err := client.ReadModule()
+		if err != nil {
+			return fmt.Errorf("failed to re-read module: %w", err)
+		}
+		readHash, err := store.ContentHash(data)
+		if err != nil {
+			return fmt.Errorf("failed to hash module data: %w", err)
+		}
+
+		if outcome, diffs := eeprom.VerifyWrite(image, before, data); outcome != eeprom.VerifyExact {
+			fmt.Printf("Verify FAILED (%s): module content does not match the image\n", outcome)
+			var pages []string
+			for _, d := range diffs {
+				fmt.Printf("  %-8s %3d bytes differ (first at 0x%03X)\n", d.Page, d.Count, d.First)
+				pages = append(pages, d.Page)
+			}
+			fmt.Printf("Serial %s will be reused for the next module.\n", serial)
+			if err := appendCloneLog(opts.LogPath, cloneLogRow{opts.SerialPattern, seq, serial, sourceHash, readHash, client.MAC(), details.SN, cloneStatusMismatch, pages}); err != nil {
+				return err
+			}
+			fmt.Println("Remove the module to continue.")
+			if err := waitForRemoval(client, opts.PollInterval); err != nil {
+				return err
+			}
+			lastSerial = ""
+			continue
+		}
+
+		hash, _, err := s.Import(data, store.Source{
+			DeviceMAC: client.MAC(),
+			Timestamp: time.Now(),
+			Method:    "clone_batch",
+		})
+		if err != nil {
+			return fmt.Errorf("failed to save to store: %w", err)
+		}
+		if err := appendCloneLog(opts.LogPath, cloneLogRow{opts.SerialPattern, seq, serial, sourceHash, hash, client.MAC(), details.SN, cloneStatusOK, nil}); err != nil {
+			return err
+		}
+		fmt.Printf("Verified %s, saved to store: %s\n", serial, store.ShortHash(hash))
+
+		done[serial] = true
+		lastSerial = serial
+		provisioned++
+		seq++
+	}
+
+	fmt.Printf("\nProvisioned %d modules.\n", provisioned)
+	return nil
+}
+
+// waitForModule polls module details until a module accepted by want is inserted.
+func waitForModule(client *api.Client, interval time.Duration, want func(*api.ModuleDetails) bool) (*api.ModuleDetails, error) {
+	for {
+		details, err := client.GetModuleDetails()
+		if err != nil {
+			if client.IsDisconnectError(err) {
+				return nil, err
+			}
+			config.Debugf("Module details failed: %v", err)
+		} else if details.IsModulePresent() && want(details) {
+			return details, nil
+		}
+		time.Sleep(interval)
+	}
+}
+
+// waitForRemoval polls module details until no module is inserted.
+func waitForRemoval(client *api.Client, interval time.Duration) error {
+	for {
+		details, err := client.GetModuleDetails()
+		if err != nil {
+			if client.IsDisconnectError(err) {
+				return err
+			}
+			config.Debugf("Module details failed: %v", err)
+		} else if !details.IsModulePresent() {
+			return nil
+		}
+		time.Sleep(interval)
+	}
+}
+
+type cloneLogRow struct {
+	pattern     string
+	seq         int
+	serial      string
+	sourceHash  string
+	profileHash string
+	deviceMAC   string
+	prevSerial  string
+	status      string
+	diffPages   []string // Pages that did not verify, for mismatches
+}
+
+// readCloneLog reads the run log, returning no rows if it does not exist yet.
+func readCloneLog(path string) ([]cloneLogRow, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open run log: %w", err)
+	}
+	defer f.Close()
+
+	cr := csv.NewReader(f)
+	cr.FieldsPerRecord = -1 // Logs from before diff_pages have one column less
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse run log %s: %w", path, err)
+	}
+
+	var rows []cloneLogRow
+	for _, r := range records {
+		if len(r) < len(cloneLogHeader)-1 || r[0] == cloneLogHeader[0] {
+			continue
+		}
+		seq, err := strconv.Atoi(r[2])
+		if err != nil {
+			continue
+		}
+		row := cloneLogRow{r[1], seq, r[3], r[4], r[5], r[6], r[7], r[8], nil}
+		if len(r) > 9 && r[9] != "" {
+			row.diffPages = strings.Split(r[9], ";")
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// appendCloneLog appends a row to the run log, writing the header for a new file.
+func appendCloneLog(path string, row cloneLogRow) error {
+	_, statErr := os.Stat(path)
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open run log: %w", err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	if os.IsNotExist(statErr) {
+		w.Write(cloneLogHeader)
+	}
+	w.Write([]string{
+		time.Now().Format(time.RFC3339), row.pattern, strconv.Itoa(row.seq), row.serial,
+		row.sourceHash, row.profileHash, row.deviceMAC, row.prevSerial, row.status,
+		strings.Join(row.diffPages, ";"),
+	})
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed to write run log: %w", err)
+	}
+	return f.Sync()
+}
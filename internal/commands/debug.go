@@ -201,19 +201,24 @@ func TestPackets(filename string) {
 
 // ParseEEPROM parses and displays SFP/QSFP EEPROM data from a file
 func ParseEEPROM(filename string) {
-	data, err := os.ReadFile(filename)
+	input, err := os.ReadFile(filename)
 	if err != nil {
 		log.Fatalf("Failed to read file: %v", err)
 	}
 
-	fmt.Printf("File: %s (%d bytes)\n\n", filename, len(data))
-
 	// Check for empty/invalid data
-	if len(data) == 0 {
+	if len(input) == 0 {
 		fmt.Println("ERROR: File is empty")
 		return
 	}
 
+	data, format, err := eeprom.Decode(input)
+	if err != nil {
+		log.Fatalf("Failed to decode %s: %v", filename, err)
+	}
+
+	fmt.Printf("File: %s (%d bytes, %s)\n\n", filename, len(data), format)
+
 	// Check if all 0xff (no module)
 	allFF := true
 	for _, b := range data {
@@ -1,14 +1,17 @@
 package commands
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/vitaminmoo/sfpw-tool/internal/api"
 	"github.com/vitaminmoo/sfpw-tool/internal/ble"
-	"github.com/vitaminmoo/sfpw-tool/internal/config"
+	"github.com/vitaminmoo/sfpw-tool/internal/firmware"
 
 	"tinygo.org/x/bluetooth"
 )
@@ -23,47 +26,69 @@ type FirmwareStatus struct {
 	RemainingTime   int    `json:"remainingTime"`
 }
 
-// FirmwareStartResponse represents the response from POST /fw/start
-type FirmwareStartResponse struct {
-	Status string `json:"status"`
-	Offset int    `json:"offset"`
-	Chunk  int    `json:"chunk"`
-	Size   int    `json:"size"`
-}
-
-// FirmwareUpdate uploads and installs new firmware
-func FirmwareUpdate(device bluetooth.Device, filename string) {
-	ctx := ble.SetupAPI(device)
-
+// FirmwareUpdate uploads and installs new firmware, then waits for the
+// device to reboot and checks the version it comes back with. The image is
+// verified first; images for another project or chip, and downgrades, are
+// refused unless force is set. Failures after the upload starts are
+// *api.FirmwareUpdateError, which carries an exit code.
+func FirmwareUpdate(device bluetooth.Device, filename string, force bool) error {
 	// Read the firmware file
 	fwData, err := os.ReadFile(filename)
 	if err != nil {
-		log.Fatalf("Failed to read firmware file: %v", err)
+		return fmt.Errorf("failed to read firmware file: %w", err)
 	}
 
 	fmt.Printf("Loaded firmware file: %d bytes from %s\n", len(fwData), filename)
 
+	img, err := firmware.ParseESP32ImageReader(bytes.NewReader(fwData))
+	if err != nil {
+		return fmt.Errorf("invalid firmware image: %w", err)
+	}
+	if err := img.Verify(); err != nil {
+		return fmt.Errorf("corrupt firmware image: %w", err)
+	}
+	if desc, err := img.AppDescriptor(); err == nil {
+		fmt.Printf("Image: %s v%s for %s (IDF %s, built %s %s)\n",
+			desc.ProjectName, strings.TrimPrefix(desc.Version, "v"), img.ChipName(), desc.IDFVersion, desc.Date, desc.Time)
+	}
+
+	client := api.New(device)
+	if err := client.Connect(); err != nil {
+		return err
+	}
+	defer client.Disconnect()
+
 	// Get current firmware status
 	fmt.Println("Checking current firmware status...")
-	status, err := getFirmwareStatus(ctx)
+	status, err := client.GetFirmwareStatus()
 	if err != nil {
-		log.Fatalf("Failed to get firmware status: %v", err)
+		return fmt.Errorf("failed to get firmware status: %w", err)
 	}
 
 	fmt.Printf("Current firmware: v%s (hw: %d)\n", status.FWVersion, status.HWVersion)
 	fmt.Printf("Update status: %s\n", status.Status)
 
+	if problems := img.FlashProblems(status.FWVersion); len(problems) > 0 {
+		for _, p := range problems {
+			fmt.Printf("WARNING: %s\n", p)
+		}
+		if !force {
+			return fmt.Errorf("refusing to flash this image (use --force to override)")
+		}
+		fmt.Println("Continuing because of --force.")
+	}
+
 	if status.IsUpdating {
 		fmt.Println("WARNING: A firmware update is already in progress!")
 		if !ConfirmAction("Abort existing update and start new one? (yes/no): ") {
 			fmt.Println("Aborted.")
-			return
+			return nil
 		}
 
 		// Abort existing update
 		fmt.Println("Aborting existing update...")
-		if err := abortFirmwareUpdate(ctx); err != nil {
-			log.Fatalf("Failed to abort existing update: %v", err)
+		if err := client.AbortFirmwareUpdate(); err != nil {
+			return fmt.Errorf("failed to abort existing update: %w", err)
 		}
 		time.Sleep(1 * time.Second)
 	}
@@ -74,119 +99,35 @@ func FirmwareUpdate(device bluetooth.Device, filename string) {
 	fmt.Printf("File size: %d bytes\n", len(fwData))
 	if !ConfirmAction("Type 'yes' to start firmware update: ") {
 		fmt.Println("Aborted.")
-		return
+		return nil
 	}
 
-	// Step 1: Start firmware update
 	fmt.Println("\nStarting firmware update...")
-	startResp, err := startFirmwareUpdate(ctx, len(fwData))
-	if err != nil {
-		log.Fatalf("Failed to start firmware update: %v", err)
-	}
-	config.Debugf("Start response: %+v", startResp)
-
-	// Determine chunk size (use what device tells us, or default)
-	chunkSize := 512 // default
-	if startResp.Chunk > 0 {
-		chunkSize = startResp.Chunk
-	}
-	config.Debugf("Using chunk size: %d bytes", chunkSize)
-
-	// Step 2: Send firmware data in chunks
-	fmt.Printf("Uploading firmware (%d bytes in %d-byte chunks)...\n", len(fwData), chunkSize)
-
-	totalChunks := (len(fwData) + chunkSize - 1) / chunkSize
-	for offset := 0; offset < len(fwData); offset += chunkSize {
-		end := offset + chunkSize
-		if end > len(fwData) {
-			end = len(fwData)
+	phase := ""
+	version, err := client.UpdateFirmware(fwData, func(p api.FirmwareProgress) {
+		if p.Phase != phase && phase != "" {
+			fmt.Println()
 		}
-		chunk := fwData[offset:end]
-		chunkNum := (offset / chunkSize) + 1
-
-		// Send chunk
-		err := sendFirmwareChunk(ctx, chunk, offset)
-		if err != nil {
-			fmt.Printf("\nFailed to send chunk at offset %d: %v\n", offset, err)
-			fmt.Println("Aborting firmware update...")
-			abortFirmwareUpdate(ctx)
-			return
+		phase = p.Phase
+		switch p.Phase {
+		case "uploading":
+			fmt.Printf("\r  Uploading: %d/%d bytes (%.1f%%)", p.Current, p.Total, float64(p.Current)/float64(p.Total)*100)
+		case "installing":
+			fmt.Printf("\r  Installing: %d%%, %s remaining    ", p.Current, p.Remaining)
+		case "rebooting":
+			fmt.Print("  Device is rebooting...")
+		case "reconnecting":
+			fmt.Print("  Waiting for the device to come back...")
+		case "verifying":
+			fmt.Print("  Reconnected, checking firmware version...")
 		}
-
-		// Progress bar
-		progress := float64(offset+len(chunk)) / float64(len(fwData)) * 100
-		fmt.Printf("\r  Chunk %d/%d: %d-%d bytes (%.1f%%)", chunkNum, totalChunks, offset, end, progress)
-
-		// Small delay between chunks
-		time.Sleep(20 * time.Millisecond)
-	}
+	})
 	fmt.Println()
-
-	// Step 3: Monitor update progress
-	fmt.Println("Firmware uploaded. Monitoring installation progress...")
-
-	for {
-		time.Sleep(2 * time.Second)
-
-		status, err := getFirmwareStatus(ctx)
-		if err != nil {
-			// Connection may drop during update - that's often expected
-			fmt.Printf("Status check failed (connection may have dropped): %v\n", err)
-			fmt.Println("The device may be rebooting. Please check device status manually.")
-			return
-		}
-
-		fmt.Printf("\r  Status: %s, Progress: %d%%, Remaining: %ds    ",
-			status.Status, status.ProgressPercent, status.RemainingTime)
-
-		if !status.IsUpdating {
-			fmt.Println()
-			if status.Status == "finished" || status.Status == "complete" {
-				fmt.Printf("Firmware update complete! New version: v%s\n", status.FWVersion)
-			} else {
-				fmt.Printf("Update finished with status: %s\n", status.Status)
-			}
-			break
-		}
-	}
-}
-
-// startFirmwareUpdate initiates a firmware update
-func startFirmwareUpdate(ctx *ble.APIContext, size int) (*FirmwareStartResponse, error) {
-	// Send size as JSON body
-	reqBody := fmt.Sprintf(`{"size":%d}`, size)
-
-	resp, body, err := ctx.SendRequest("POST", ctx.APIPath("/fw/start"), []byte(reqBody), 10*time.Second)
-	if err != nil {
-		return nil, err
-	}
-
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("status %d: %s", resp.StatusCode, string(body))
-	}
-
-	var startResp FirmwareStartResponse
-	if err := json.Unmarshal(body, &startResp); err != nil {
-		// Return with defaults if we can't parse
-		config.Debugf("Could not parse start response: %v, body: %s", err, string(body))
-		return &FirmwareStartResponse{Status: "ready"}, nil
-	}
-
-	return &startResp, nil
-}
-
-// sendFirmwareChunk sends a chunk of firmware data
-func sendFirmwareChunk(ctx *ble.APIContext, chunk []byte, offset int) error {
-	resp, body, err := ctx.SendRawBodyRequest("POST", ctx.APIPath("/fw/data"), chunk, 30*time.Second)
 	if err != nil {
 		return err
 	}
 
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("status %d: %s", resp.StatusCode, string(body))
-	}
-
-	config.Debugf("Chunk at offset %d sent successfully", offset)
+	fmt.Printf("Firmware update complete! Device runs v%s\n", strings.TrimPrefix(version, "v"))
 	return nil
 }
 
@@ -209,6 +150,20 @@ func getFirmwareStatus(ctx *ble.APIContext) (*FirmwareStatus, error) {
 	return &status, nil
 }
 
+// InstalledFirmware reads the firmware version and update state of the
+// device.
+func InstalledFirmware(device bluetooth.Device) (*FirmwareStatus, error) {
+	ctx, err := ble.NewAPIContext(device)
+	if err != nil {
+		return nil, err
+	}
+	status, err := getFirmwareStatus(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get firmware status: %w", err)
+	}
+	return status, nil
+}
+
 // abortFirmwareUpdate aborts an in-progress firmware update
 func abortFirmwareUpdate(ctx *ble.APIContext) error {
 	resp, body, err := ctx.SendRequest("POST", ctx.APIPath("/fw/abort"), nil, 10*time.Second)
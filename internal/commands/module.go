@@ -103,17 +103,24 @@ func ModuleRead(device bluetooth.Device, filename string) {
 // ModuleReadData reads EEPROM from the physical module and returns the data.
 // This is the low-level function used by both CLI and TUI.
 func ModuleReadData(ctx *ble.APIContext) ([]byte, error) {
+	data, _, err := moduleReadSized(ctx)
+	return data, err
+}
+
+// moduleReadSized is ModuleReadData that also returns the size reported by
+// /xsfp/module/start, or 0 if the device did not report one.
+func moduleReadSized(ctx *ble.APIContext) ([]byte, int, error) {
 	// Step 1: GET /xsfp/module/start to initialize read and get size
 	resp, body, err := ctx.SendRequest("GET", ctx.APIPath("/xsfp/module/start"), nil, 10*time.Second)
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize: %w", err)
+		return nil, 0, fmt.Errorf("failed to initialize: %w", err)
 	}
 
 	if resp.StatusCode != 200 {
 		if len(body) > 0 {
-			return nil, fmt.Errorf("error initializing: status %d: %s", resp.StatusCode, string(body))
+			return nil, 0, fmt.Errorf("error initializing: status %d: %s", resp.StatusCode, string(body))
 		}
-		return nil, fmt.Errorf("error initializing: status %d", resp.StatusCode)
+		return nil, 0, fmt.Errorf("error initializing: status %d", resp.StatusCode)
 	}
 
 	// Parse to get size info
@@ -121,10 +128,13 @@ func ModuleReadData(ctx *ble.APIContext) ([]byte, error) {
 		Size  int `json:"size"`
 		Chunk int `json:"chunk"`
 	}
+	var reported int
 	if err := json.Unmarshal(body, &startResp); err != nil {
 		// Default to SFP size
 		startResp.Size = 512
 		startResp.Chunk = 512
+	} else {
+		reported = startResp.Size
 	}
 	if startResp.Size == 0 {
 		startResp.Size = 512
@@ -134,17 +144,33 @@ func ModuleReadData(ctx *ble.APIContext) ([]byte, error) {
 	reqBody := fmt.Sprintf(`{"offset":0,"chunk":%d}`, startResp.Size)
 	resp, body, err = ctx.SendRequest("GET", ctx.APIPath("/xsfp/module/data"), []byte(reqBody), 30*time.Second)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read module data: %w", err)
+		return nil, 0, fmt.Errorf("failed to read module data: %w", err)
 	}
 
 	if resp.StatusCode != 200 {
 		if len(body) > 0 {
-			return nil, fmt.Errorf("error reading module data: status %d: %s", resp.StatusCode, string(body))
+			return nil, 0, fmt.Errorf("error reading module data: status %d: %s", resp.StatusCode, string(body))
 		}
-		return nil, fmt.Errorf("error reading module data: status %d", resp.StatusCode)
+		return nil, 0, fmt.Errorf("error reading module data: status %d", resp.StatusCode)
 	}
 
-	return body, nil
+	return body, reported, nil
+}
+
+// ModuleEEPROM reads the EEPROM of the module in the device.
+func ModuleEEPROM(device bluetooth.Device) ([]byte, error) {
+	ctx, err := ble.NewAPIContext(device)
+	if err != nil {
+		return nil, err
+	}
+	data, size, err := moduleReadSized(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < size {
+		return nil, fmt.Errorf("short read: got %d of %d bytes", len(data), size)
+	}
+	return data, nil
 }
 
 // DDM reads DDM (Digital Diagnostic Monitoring) data from the module.
@@ -7,7 +7,10 @@ import (
 	"os"
 	"time"
 
+	"github.com/vitaminmoo/sfpw-tool/internal/api"
 	"github.com/vitaminmoo/sfpw-tool/internal/ble"
+	"github.com/vitaminmoo/sfpw-tool/internal/config"
+	"github.com/vitaminmoo/sfpw-tool/internal/eeprom"
 	"github.com/vitaminmoo/sfpw-tool/internal/store"
 
 	"tinygo.org/x/bluetooth"
@@ -139,6 +142,18 @@ func SnapshotReadData(ctx *ble.APIContext) ([]byte, error) {
 // SnapshotWrite writes EEPROM data to the snapshot buffer
 // Use device screen to apply snapshot to physical module
 func SnapshotWrite(device bluetooth.Device, filename string) {
+	// Read the EEPROM file
+	eepromData, err := os.ReadFile(filename)
+	if err != nil {
+		log.Fatalf("Failed to read file: %v", err)
+	}
+
+	SnapshotWriteData(device, eepromData, filename)
+}
+
+// SnapshotWriteData writes already-loaded EEPROM data to the snapshot buffer.
+// name describes where the data came from and is only used for display.
+func SnapshotWriteData(device bluetooth.Device, eepromData []byte, name string) {
 	ctx := ble.SetupAPI(device)
 
 	// Cancel any in-progress sync operation
@@ -146,12 +161,14 @@ func SnapshotWrite(device bluetooth.Device, filename string) {
 		log.Fatal(err)
 	}
 
-	// Read the EEPROM file
-	eepromData, err := os.ReadFile(filename)
-	if err != nil {
-		log.Fatalf("Failed to read file: %v", err)
+	if snapshotWrite(ctx, eepromData, name) {
+		fmt.Println("\nUse the device screen to apply snapshot to module.")
 	}
+}
 
+// snapshotWrite confirms and pushes data to the snapshot buffer.
+// Returns true if the snapshot was written.
+func snapshotWrite(ctx *ble.APIContext, eepromData []byte, name string) bool {
 	// Validate size
 	if len(eepromData) != 512 && len(eepromData) != 640 {
 		log.Fatalf("Invalid EEPROM size: %d bytes (expected 512 for SFP or 640 for QSFP)", len(eepromData))
@@ -162,7 +179,7 @@ func SnapshotWrite(device bluetooth.Device, filename string) {
 		moduleType = "QSFP"
 	}
 
-	fmt.Printf("Loaded %s EEPROM data: %d bytes from %s\n", moduleType, len(eepromData), filename)
+	fmt.Printf("Loaded %s EEPROM data: %d bytes from %s\n", moduleType, len(eepromData), name)
 
 	// Parse and display what we're about to write
 	DisplayEEPROMInfo(eepromData)
@@ -172,7 +189,7 @@ func SnapshotWrite(device bluetooth.Device, filename string) {
 	fmt.Println("Use the device screen to apply snapshot to module.")
 	if !ConfirmAction("Type 'yes' to continue: ") {
 		fmt.Println("Aborted.")
-		return
+		return false
 	}
 
 	// Step 1: POST /xsfp/sync/start with size
@@ -188,7 +205,7 @@ func SnapshotWrite(device bluetooth.Device, filename string) {
 		if len(body) > 0 {
 			fmt.Printf("Response: %s\n", string(body))
 		}
-		return
+		return false
 	}
 
 	fmt.Printf("Snapshot initialized: %s\n", string(body))
@@ -205,15 +222,138 @@ func SnapshotWrite(device bluetooth.Device, filename string) {
 		if len(body) > 0 {
 			fmt.Printf("Response: %s\n", string(body))
 		}
-		return
+		return false
 	}
 
 	fmt.Printf("Snapshot write complete!\n")
 	if len(body) > 0 {
 		PrintJSON(body)
 	}
+	return true
+}
+
+// SnapshotWriteVerify writes data to the snapshot buffer, then waits for the
+// user to apply it and reads the module back to check the result. The
+// outcome is recorded as a "snapshot_write" source on the intended profile.
+func SnapshotWriteVerify(device bluetooth.Device, eepromData []byte, name string, timeout time.Duration) error {
+	ctx := ble.SetupAPI(device)
+
+	if err := CancelXSFPSync(ctx); err != nil {
+		return err
+	}
+
+	before, err := ModuleReadData(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read module before write (is a module inserted?): %w", err)
+	}
+	beforeDetails, _ := moduleDetails(ctx)
+
+	if !snapshotWrite(ctx, eepromData, name) {
+		return fmt.Errorf("snapshot write failed")
+	}
+
+	// A module that already holds the image will not change, so there is
+	// nothing to wait for
+	after := before
+	if len(eeprom.DiffPages(eepromData, before)) == 0 {
+		fmt.Println("\nModule already holds this image, no write needed.")
+	} else {
+		fmt.Printf("\nPress Write on the device screen now. Waiting up to %s for the module to change...\n", timeout)
+		after = waitForModuleChange(ctx, before, beforeDetails, timeout)
+	}
+
+	outcome, diffs := eeprom.VerifyWrite(eepromData, before, after)
+	switch outcome {
+	case eeprom.VerifyExact:
+		fmt.Println("Verify: exact match")
+	case eeprom.VerifyUnchanged:
+		fmt.Println("Verify: module unchanged (write not applied or unlock failed)")
+	case eeprom.VerifyPartial:
+		fmt.Println("Verify: partial write")
+	}
+	var pages []string
+	for _, d := range diffs {
+		fmt.Printf("  %-8s %3d bytes differ (first at 0x%03X)\n", d.Page, d.Count, d.First)
+		pages = append(pages, d.Page)
+	}
+
+	s, err := store.OpenDefault()
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	hash, _, err := s.Import(eepromData, store.Source{
+		DeviceMAC: ctx.MAC,
+		Timestamp: time.Now(),
+		Method:    "snapshot_write",
+		Filename:  name,
+		Verify:    outcome,
+		DiffPages: pages,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save to store: %w", err)
+	}
+	fmt.Printf("Recorded in store: %s\n", store.ShortHash(hash))
+
+	if outcome != eeprom.VerifyExact {
+		return fmt.Errorf("verification failed: %s", outcome)
+	}
+	return nil
+}
+
+// waitForModuleChange polls the module until its contents differ from before
+// and are stable across two reads, or the timeout expires. Module details are
+// cheap and checked every poll; the full EEPROM is read when they change, and
+// periodically otherwise since a write may not touch the identity strings.
+// Returns the last contents read.
+func waitForModuleChange(ctx *ble.APIContext, before []byte, beforeDetails *api.ModuleDetails, timeout time.Duration) []byte {
+	deadline := time.Now().Add(timeout)
+	last := before
+	for poll := 1; time.Now().Before(deadline); poll++ {
+		time.Sleep(2 * time.Second)
+
+		details, err := moduleDetails(ctx)
+		if err != nil || !details.IsModulePresent() {
+			continue // Module is busy or being written
+		}
+		detailsChanged := beforeDetails == nil || *details != *beforeDetails
+		if !detailsChanged && poll%5 != 0 && len(eeprom.DiffPages(before, last)) == 0 {
+			continue
+		}
+
+		data, err := ModuleReadData(ctx)
+		if err != nil {
+			config.Debugf("Module read failed: %v", err)
+			continue
+		}
+		changed := len(eeprom.DiffPages(before, data)) > 0
+		stable := len(eeprom.DiffPages(last, data)) == 0
+		last = data
+		if changed && stable {
+			return data
+		}
+	}
 
-	fmt.Println("\nUse the device screen to apply snapshot to module.")
+	// Timed out: return a fresh read if possible
+	if data, err := ModuleReadData(ctx); err == nil {
+		return data
+	}
+	return last
+}
+
+// moduleDetails fetches the inserted module's identity strings.
+func moduleDetails(ctx *ble.APIContext) (*api.ModuleDetails, error) {
+	resp, body, err := ctx.SendRequest("GET", ctx.APIPath("/xsfp/module/details"), nil, 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("status %d: %s", resp.StatusCode, string(body))
+	}
+	var details api.ModuleDetails
+	if err := json.Unmarshal(body, &details); err != nil {
+		return nil, err
+	}
+	return &details, nil
 }
 
 // Recover restores module EEPROM from saved "golden snapshot" in device database.
@@ -0,0 +1,263 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/vitaminmoo/sfpw-tool/internal/api"
+	"github.com/vitaminmoo/sfpw-tool/internal/config"
+	"github.com/vitaminmoo/sfpw-tool/internal/store"
+
+	"tinygo.org/x/bluetooth"
+)
+
+// ModuleWatchOptions configures the module hot-plug watcher.
+type ModuleWatchOptions struct {
+	PollInterval time.Duration
+	LogPath      string // JSONL inventory log, empty to disable
+	Exec         string // Shell command run with the event JSON on stdin
+	Webhook      string // URL the event JSON is POSTed to
+}
+
+// Module watch event types
+const (
+	ModuleInserted = "inserted"
+	ModuleRemoved  = "removed"
+	ModuleSwapped  = "swapped" // Serial changed between polls without a removal
+)
+
+// ModuleEvent is a module insertion or removal. The flat fields are written
+// to the inventory log; Metadata is only sent to hooks.
+type ModuleEvent struct {
+	Time           time.Time       `json:"time"`
+	Event          string          `json:"event"`
+	DeviceMAC      string          `json:"device_mac"`
+	ModuleType     string          `json:"module_type,omitempty"`
+	Vendor         string          `json:"vendor,omitempty"`
+	PartNumber     string          `json:"part_number,omitempty"`
+	SerialNumber   string          `json:"serial_number,omitempty"`
+	PreviousSerial string          `json:"previous_serial,omitempty"`
+	WavelengthNM   int             `json:"wavelength_nm,omitempty"`
+	BitrateMbps    int             `json:"bitrate_mbps,omitempty"`
+	ChecksumValid  *bool           `json:"checksum_valid,omitempty"`
+	ContentHash    string          `json:"content_hash,omitempty"`
+	NewProfile     bool            `json:"new_profile,omitempty"`
+	Error          string          `json:"error,omitempty"`
+	Metadata       *store.Metadata `json:"metadata,omitempty"`
+}
+
+// ModuleWatch polls for module insertion, removal and swaps until the
+// connection drops. Each new module is read, imported into the store and
+// summarised on one line.
+func ModuleWatch(device bluetooth.Device, opts ModuleWatchOptions) error {
+	if opts.PollInterval == 0 {
+		opts.PollInterval = time.Second
+	}
+
+	s, err := store.OpenDefault()
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+
+	client := api.New(device)
+	if err := client.Connect(); err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	if err := CancelXSFPSync(client.Context()); err != nil {
+		return err
+	}
+
+	fmt.Println("Watching for modules (Ctrl-C to stop)...")
+
+	var current *api.ModuleDetails
+	var failed *ModuleEvent // Capture of current that needs retrying
+	for {
+		details, err := client.GetModuleDetails()
+		if err != nil {
+			if client.IsDisconnectError(err) {
+				return err
+			}
+			config.Debugf("Module details failed: %v", err)
+			time.Sleep(opts.PollInterval)
+			continue
+		}
+
+		present := details.IsModulePresent()
+		switch {
+		case current != nil && !present:
+			ev := &ModuleEvent{
+				Time:         time.Now(),
+				Event:        ModuleRemoved,
+				DeviceMAC:    client.MAC(),
+				Vendor:       current.Vendor,
+				PartNumber:   current.PartNumber,
+				SerialNumber: current.SN,
+			}
+			fmt.Printf("%s  removed   %s %s S/N %s\n", ev.Time.Format("15:04:05"), ev.Vendor, ev.PartNumber, ev.SerialNumber)
+			emitModuleEvent(ev, opts, false)
+			current, failed = nil, nil
+
+		case present && (current == nil || !sameModule(details, current)):
+			event := ModuleInserted
+			previous := ""
+			if current != nil {
+				event = ModuleSwapped
+				previous = current.SN
+			}
+			ev := captureModule(client, s, details, event)
+			ev.PreviousSerial = previous
+			printModuleEvent(ev)
+			emitModuleEvent(ev, opts, true)
+			current, failed = details, nil
+			if ev.Error != "" {
+				failed = ev
+			}
+
+		case present && failed != nil:
+			// Retry a failed read quietly until it succeeds
+			ev := captureModule(client, s, details, failed.Event)
+			ev.PreviousSerial = failed.PreviousSerial
+			if ev.Error != "" {
+				config.Debugf("Retrying module capture: %s", ev.Error)
+				break
+			}
+			printModuleEvent(ev)
+			emitModuleEvent(ev, opts, true)
+			failed = nil
+		}
+
+		time.Sleep(opts.PollInterval)
+	}
+}
+
+// sameModule reports whether two detail snapshots describe the same module.
+func sameModule(a, b *api.ModuleDetails) bool {
+	return a.SN == b.SN && a.Vendor == b.Vendor && a.PartNumber == b.PartNumber
+}
+
+// captureModule reads the inserted module and imports it into the store.
+func captureModule(client *api.Client, s *store.Store, details *api.ModuleDetails, event string) *ModuleEvent {
+	ev := &ModuleEvent{
+		Time:         time.Now(),
+		Event:        event,
+		DeviceMAC:    client.MAC(),
+		Vendor:       details.Vendor,
+		PartNumber:   details.PartNumber,
+		SerialNumber: details.SN,
+	}
+
+	data, err := client.ReadModule()
+	if err != nil {
+		ev.Error = fmt.Sprintf("read failed: %v", err)
+		return ev
+	}
+
+	hash, isNew, err := s.Import(data, store.Source{
+		DeviceMAC: client.MAC(),
+		Timestamp: ev.Time,
+		Method:    "module_read",
+	})
+	if err != nil {
+		ev.Error = fmt.Sprintf("import failed: %v", err)
+		return ev
+	}
+	ev.ContentHash = hash
+	ev.NewProfile = isNew
+
+	if meta, err := s.GetMetadata(hash); err == nil {
+		ev.Metadata = meta
+		ev.ModuleType = meta.ModuleType
+		ev.WavelengthNM = meta.Specs.WavelengthNM
+		ev.BitrateMbps = meta.Specs.BitrateMbps
+		valid := meta.Checksums.Valid
+		ev.ChecksumValid = &valid
+	}
+	return ev
+}
+
+func printModuleEvent(ev *ModuleEvent) {
+	prefix := fmt.Sprintf("%s  %-9s %s %s S/N %s", ev.Time.Format("15:04:05"), ev.Event, ev.Vendor, ev.PartNumber, ev.SerialNumber)
+	if ev.Error != "" {
+		fmt.Printf("%s  ERROR: %s\n", prefix, ev.Error)
+		return
+	}
+
+	status := "existing"
+	if ev.NewProfile {
+		status = "new"
+	}
+	checksum := ""
+	if ev.ChecksumValid != nil && !*ev.ChecksumValid {
+		checksum = ", BAD CHECKSUM"
+	}
+	fmt.Printf("%s  %s %dnm %dMbps  %s (%s%s)\n", prefix, ev.ModuleType, ev.WavelengthNM, ev.BitrateMbps,
+		store.ShortHash(ev.ContentHash), status, checksum)
+}
+
+// emitModuleEvent appends the event to the inventory log and, for new
+// modules, runs the configured hooks. Hook failures are reported but do not
+// stop the watcher.
+func emitModuleEvent(ev *ModuleEvent, opts ModuleWatchOptions, runHooks bool) {
+	if opts.LogPath != "" {
+		flat := *ev
+		flat.Metadata = nil
+		if err := appendJSONL(opts.LogPath, &flat); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write inventory log: %v\n", err)
+		}
+	}
+
+	if !runHooks || (opts.Exec == "" && opts.Webhook == "") {
+		return
+	}
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to encode event: %v\n", err)
+		return
+	}
+
+	if opts.Exec != "" {
+		cmd := exec.Command("sh", "-c", opts.Exec)
+		cmd.Stdin = bytes.NewReader(payload)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		cmd.Env = append(os.Environ(), "SFPW_EVENT="+ev.Event, "SFPW_SERIAL="+ev.SerialNumber, "SFPW_HASH="+ev.ContentHash)
+		if err := cmd.Run(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: exec hook failed: %v\n", err)
+		}
+	}
+
+	if opts.Webhook != "" {
+		httpClient := &http.Client{Timeout: 10 * time.Second}
+		resp, err := httpClient.Post(opts.Webhook, "application/json", bytes.NewReader(payload))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: webhook failed: %v\n", err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			fmt.Fprintf(os.Stderr, "Warning: webhook returned status %d\n", resp.StatusCode)
+		}
+	}
+}
+
+// appendJSONL appends v as a single JSON line to path.
+func appendJSONL(path string, v any) error {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return f.Sync()
+}
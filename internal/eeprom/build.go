@@ -0,0 +1,378 @@
+package eeprom
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Spec describes an EEPROM image as a base image plus symbolic overrides.
+// Unset fields keep the value from the base.
+type Spec struct {
+	// Base is a file path, store profile hash, or a blank module type
+	// ("sfp", "qsfp", "qsfp+", "qsfp28"). Resolved by the caller.
+	Base string `yaml:"base"`
+
+	VendorName   *string `yaml:"vendor_name"`
+	VendorOUI    *string `yaml:"vendor_oui"` // "00:90:65"
+	PartNumber   *string `yaml:"part_number"`
+	Revision     *string `yaml:"revision"`
+	SerialNumber *string `yaml:"serial_number"`
+	DateCode     *string `yaml:"date_code"` // YYMMDD plus optional 2-char lot code
+
+	Connector   *string  `yaml:"connector"` // SFF-8024 name, e.g. "LC", or code "0x07"
+	Encoding    *string  `yaml:"encoding"`  // SFF-8024 name, e.g. "64B/66B", or code
+	Compliance  []string `yaml:"compliance"`
+	BitrateMbps *int     `yaml:"bitrate_mbps"`
+	Wavelength  *int     `yaml:"wavelength"` // nm
+
+	SMFLengthKM   *int `yaml:"smf_length_km"`
+	SMFLengthM    *int `yaml:"smf_length_m"`
+	OM1LengthM    *int `yaml:"om1_length_m"`
+	OM2LengthM    *int `yaml:"om2_length_m"`
+	OM3LengthM    *int `yaml:"om3_length_m"`
+	OM4LengthM    *int `yaml:"om4_length_m"`
+	CopperLengthM *int `yaml:"copper_length_m"`
+
+	DDM string `yaml:"ddm"` // "none", "internal" or "external" (SFP only)
+}
+
+// ParseSpec parses a YAML build spec. Unknown keys are rejected so typos
+// don't silently produce an unmodified image.
+func ParseSpec(input []byte) (*Spec, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(input))
+	dec.KnownFields(true)
+	var spec Spec
+	if err := dec.Decode(&spec); err != nil {
+		return nil, fmt.Errorf("invalid spec: %w", err)
+	}
+	if spec.Base == "" {
+		return nil, fmt.Errorf("invalid spec: base is required")
+	}
+	return &spec, nil
+}
+
+// Blank returns an empty image for a module type name, or false if the
+// name is not a module type.
+func Blank(moduleType string) ([]byte, bool) {
+	var id byte
+	switch strings.ToLower(moduleType) {
+	case "sfp", "sfp+":
+		data := make([]byte, SFPSize)
+		data[0] = 0x03
+		data[1] = 0x04
+		return data, true
+	case "qsfp":
+		id = 0x0c
+	case "qsfp+":
+		id = 0x0d
+	case "qsfp28":
+		id = 0x11
+	default:
+		return nil, false
+	}
+	data := make([]byte, QSFPSize)
+	data[0] = id
+	data[128] = id
+	return data, true
+}
+
+// layout holds the offsets of the fields a Spec can set.
+type layout struct {
+	vendorName, vendorOUI, partNumber, revision, revisionLen int
+	serialNumber, dateCode                                   int
+	connector, encoding, bitrate, bitrateExt, wavelength     int
+	wavelengthScale                                          int // Units per nm divisor (QSFP stores nm/20)
+}
+
+var (
+	sfpLayout = layout{
+		vendorName: 20, vendorOUI: 37, partNumber: 40, revision: 56, revisionLen: 4,
+		serialNumber: 68, dateCode: 84,
+		connector: 2, encoding: 11, bitrate: 12, bitrateExt: 66, wavelength: 60,
+		wavelengthScale: 1,
+	}
+	qsfpLayout = layout{
+		vendorName: 148, vendorOUI: 165, partNumber: 168, revision: 184, revisionLen: 2,
+		serialNumber: 196, dateCode: 212,
+		connector: 130, encoding: 139, bitrate: 140, bitrateExt: 222, wavelength: 186,
+		wavelengthScale: 20,
+	}
+)
+
+// Build applies a spec to a copy of base and recomputes the checksums.
+func Build(base []byte, spec *Spec) ([]byte, error) {
+	data, err := Normalize(append([]byte(nil), base...))
+	if err != nil {
+		return nil, err
+	}
+	qsfp := len(data) == QSFPSize
+	lay := sfpLayout
+	if qsfp {
+		lay = qsfpLayout
+	}
+
+	b := &builder{data: data}
+	b.ascii("vendor_name", lay.vendorName, 16, spec.VendorName)
+	b.ascii("part_number", lay.partNumber, 16, spec.PartNumber)
+	b.ascii("revision", lay.revision, lay.revisionLen, spec.Revision)
+	b.ascii("serial_number", lay.serialNumber, 16, spec.SerialNumber)
+	if spec.DateCode != nil {
+		dc := *spec.DateCode
+		if len(dc) < 6 || strings.Trim(dc[:6], "0123456789") != "" {
+			b.fail("date_code %q must start with YYMMDD", dc)
+		}
+		b.ascii("date_code", lay.dateCode, 8, &dc)
+	}
+	if spec.VendorOUI != nil {
+		oui, err := hex.DecodeString(strings.NewReplacer(":", "", "-", "").Replace(*spec.VendorOUI))
+		if err != nil || len(oui) != 3 {
+			b.fail("vendor_oui %q is not a 3-byte OUI", *spec.VendorOUI)
+		} else {
+			copy(data[lay.vendorOUI:], oui)
+		}
+	}
+
+	if spec.Connector != nil {
+		b.code("connector", lay.connector, *spec.Connector, GetConnectorType)
+	}
+	if spec.Encoding != nil {
+		b.code("encoding", lay.encoding, *spec.Encoding, GetEncodingType)
+	}
+	if spec.BitrateMbps != nil {
+		rate := *spec.BitrateMbps
+		if rate > 25400 {
+			// Nominal rate above 25.4G moves to the extended field in 250 Mbps units
+			data[lay.bitrate] = 0xff
+			b.scaled("bitrate_mbps", lay.bitrateExt, rate, 250)
+		} else {
+			b.scaled("bitrate_mbps", lay.bitrate, rate, 100)
+		}
+	}
+	if spec.Wavelength != nil {
+		v := *spec.Wavelength * lay.wavelengthScale
+		if v < 0 || v > 0xffff {
+			b.fail("wavelength %d out of range", *spec.Wavelength)
+		} else {
+			data[lay.wavelength] = byte(v >> 8)
+			data[lay.wavelength+1] = byte(v)
+		}
+	}
+	if spec.Compliance != nil {
+		b.compliance(spec.Compliance, qsfp)
+	}
+
+	if qsfp {
+		b.scaledPtr("smf_length_km", 142, spec.SMFLengthKM, 1)
+		b.scaledPtr("om3_length_m", 143, spec.OM3LengthM, 2)
+		b.scaledPtr("om2_length_m", 144, spec.OM2LengthM, 1)
+		b.scaledPtr("om1_length_m", 145, spec.OM1LengthM, 1)
+		b.scaledPtr("copper_length_m", 146, spec.CopperLengthM, 1)
+		if spec.SMFLengthM != nil {
+			b.fail("smf_length_m is not defined for QSFP (use smf_length_km)")
+		}
+		if spec.OM4LengthM != nil {
+			b.fail("om4_length_m is not defined for QSFP")
+		}
+		if spec.DDM != "" {
+			b.fail("ddm is only supported for SFP images")
+		}
+	} else {
+		b.scaledPtr("smf_length_km", 14, spec.SMFLengthKM, 1)
+		b.scaledPtr("smf_length_m", 15, spec.SMFLengthM, 100)
+		b.scaledPtr("om2_length_m", 16, spec.OM2LengthM, 10)
+		b.scaledPtr("om1_length_m", 17, spec.OM1LengthM, 10)
+		b.scaledPtr("om4_length_m", 18, spec.OM4LengthM, 10)
+		b.scaledPtr("om3_length_m", 19, spec.OM3LengthM, 10)
+		if spec.CopperLengthM != nil {
+			// Byte 18 holds the cable length in metres for copper/DAC modules
+			if spec.OM4LengthM != nil {
+				b.fail("copper_length_m and om4_length_m share byte 18")
+			}
+			b.scaledPtr("copper_length_m", 18, spec.CopperLengthM, 1)
+		}
+		b.ddm(spec.DDM)
+	}
+
+	if len(b.errs) > 0 {
+		return nil, fmt.Errorf("spec errors:\n  %s", strings.Join(b.errs, "\n  "))
+	}
+
+	FixChecksums(data)
+	return data, nil
+}
+
+// FixChecksums recomputes CC_BASE, CC_EXT and, for SFP images with DDM,
+// CC_DMI in place.
+func FixChecksums(data []byte) {
+	sum := func(start, end int) {
+		var s byte
+		for _, b := range data[start:end] {
+			s += b
+		}
+		data[end] = s
+	}
+
+	if len(data) >= 256 && isQSFPIdentifier(data[0]) {
+		sum(128, 191)
+		sum(192, 223)
+		return
+	}
+	if len(data) < 96 {
+		return
+	}
+	sum(0, 63)
+	sum(64, 95)
+	if len(data) >= SFPSize && data[92]&0x40 != 0 {
+		sum(256, 256+95)
+	}
+}
+
+// StripSerial returns a copy of data with the vendor serial number blanked.
+// CC_EXT is recomputed only if it was valid, so a bad checksum stays bad.
+func StripSerial(data []byte) []byte {
+	out := append([]byte(nil), data...)
+	lay, ccExt := sfpLayout, 95
+	if len(out) >= 256 && isQSFPIdentifier(out[0]) {
+		lay, ccExt = qsfpLayout, 223
+	}
+	if len(out) <= ccExt {
+		return out
+	}
+
+	start := ccExt - 31
+	var sum byte
+	for _, b := range out[start:ccExt] {
+		sum += b
+	}
+	valid := sum == out[ccExt]
+
+	copy(out[lay.serialNumber:lay.serialNumber+16], strings.Repeat(" ", 16))
+	if valid {
+		sum = 0
+		for _, b := range out[start:ccExt] {
+			sum += b
+		}
+		out[ccExt] = sum
+	}
+	return out
+}
+
+type builder struct {
+	data []byte
+	errs []string
+}
+
+func (b *builder) fail(format string, args ...any) {
+	b.errs = append(b.errs, fmt.Sprintf(format, args...))
+}
+
+// ascii writes a space-padded printable ASCII field.
+func (b *builder) ascii(field string, offset, length int, value *string) {
+	if value == nil {
+		return
+	}
+	v := *value
+	if len(v) > length {
+		b.fail("%s %q is longer than %d characters", field, v, length)
+		return
+	}
+	for _, c := range []byte(v) {
+		if c < 0x20 || c > 0x7e {
+			b.fail("%s %q contains non-printable characters", field, v)
+			return
+		}
+	}
+	copy(b.data[offset:offset+length], v+strings.Repeat(" ", length-len(v)))
+}
+
+// code writes an SFF-8024 code given by name or as a number. Names are
+// looked up by reverse-matching the decoder tables.
+func (b *builder) code(field string, offset int, value string, describe func(byte) string) {
+	if v, err := strconv.ParseUint(value, 0, 8); err == nil {
+		b.data[offset] = byte(v)
+		return
+	}
+	for c := 0; c < 256; c++ {
+		if strings.EqualFold(describe(byte(c)), value) {
+			b.data[offset] = byte(c)
+			return
+		}
+	}
+	b.fail("unknown %s %q", field, value)
+}
+
+func (b *builder) scaled(field string, offset, value, unit int) {
+	if value < 0 || value%unit != 0 || value/unit > 0xff {
+		b.fail("%s %d must be a multiple of %d up to %d", field, value, unit, 0xff*unit)
+		return
+	}
+	b.data[offset] = byte(value / unit)
+}
+
+func (b *builder) scaledPtr(field string, offset int, value *int, unit int) {
+	if value != nil {
+		b.scaled(field, offset, *value, unit)
+	}
+}
+
+// compliance replaces all compliance code bits with the named codes.
+func (b *builder) compliance(names []string, qsfp bool) {
+	codes := SFPComplianceCodes
+	start, end := 3, 11
+	if qsfp {
+		codes = QSFPComplianceCodes
+		start, end = 131, 139
+		b.data[192] = 0
+	}
+	clear(b.data[start:end])
+
+	for _, name := range names {
+		found := false
+		for _, c := range codes {
+			if strings.EqualFold(c.Name, name) {
+				b.data[c.Offset] |= c.Mask
+				found = true
+				break
+			}
+		}
+		if !found && qsfp {
+			for code, ext := range QSFPExtendedCompliance {
+				if strings.EqualFold(ext, name) {
+					b.data[131] |= 0x80
+					b.data[192] = code
+					found = true
+					break
+				}
+			}
+		}
+		if !found {
+			b.fail("unknown compliance code %q", name)
+		}
+	}
+}
+
+// ddm sets the SFF-8472 diagnostic monitoring type (byte 92).
+func (b *builder) ddm(mode string) {
+	const (
+		implemented = 0x40
+		internal    = 0x20
+		external    = 0x10
+	)
+	d := &b.data[92]
+	switch strings.ToLower(mode) {
+	case "":
+	case "none":
+		*d &^= implemented | internal | external
+	case "internal":
+		*d = *d&^external | implemented | internal
+	case "external":
+		*d = *d&^internal | implemented | external
+	default:
+		b.fail("ddm %q must be none, internal or external", mode)
+	}
+}
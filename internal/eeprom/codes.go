@@ -0,0 +1,150 @@
+package eeprom
+
+// ComplianceCode is a transceiver compliance bit from SFF-8472 bytes 3-10
+// or SFF-8636 bytes 131-138. Offset is absolute within the snapshot image.
+type ComplianceCode struct {
+	Name   string
+	Offset int
+	Mask   byte
+}
+
+// SFPComplianceCodes lists the SFF-8472 transceiver compliance bits.
+var SFPComplianceCodes = []ComplianceCode{
+	// Byte 3: 10G Ethernet / Infiniband
+	{"10GBASE-ER", 3, 0x80},
+	{"10GBASE-LRM", 3, 0x40},
+	{"10GBASE-LR", 3, 0x20},
+	{"10GBASE-SR", 3, 0x10},
+	{"1X SX", 3, 0x08},
+	{"1X LX", 3, 0x04},
+	{"1X Copper Active", 3, 0x02},
+	{"1X Copper Passive", 3, 0x01},
+
+	// Byte 4-5: SONET
+	{"OC-192 SR", 4, 0x20},
+	{"OC-48 LR", 4, 0x04},
+	{"OC-48 IR", 4, 0x02},
+	{"OC-48 SR", 4, 0x01},
+	{"OC-12 SM LR", 5, 0x40},
+	{"OC-12 SM IR", 5, 0x20},
+	{"OC-12 SR", 5, 0x10},
+	{"OC-3 SM LR", 5, 0x04},
+	{"OC-3 SM IR", 5, 0x02},
+	{"OC-3 SR", 5, 0x01},
+
+	// Byte 6: Ethernet
+	{"BASE-PX", 6, 0x80},
+	{"BASE-BX10", 6, 0x40},
+	{"100BASE-FX", 6, 0x20},
+	{"100BASE-LX", 6, 0x10},
+	{"1000BASE-T", 6, 0x08},
+	{"1000BASE-CX", 6, 0x04},
+	{"1000BASE-LX", 6, 0x02},
+	{"1000BASE-SX", 6, 0x01},
+
+	// Byte 8: SFP+ cable technology
+	{"Active Cable", 8, 0x08},
+	{"Passive Cable", 8, 0x04},
+}
+
+// QSFPComplianceCodes lists the SFF-8636 specification compliance bits.
+var QSFPComplianceCodes = []ComplianceCode{
+	// Byte 131: 10/40G/100G Ethernet
+	{"10GBASE-LRM", 131, 0x40},
+	{"10GBASE-LR", 131, 0x20},
+	{"10GBASE-SR", 131, 0x10},
+	{"40GBASE-CR4", 131, 0x08},
+	{"40GBASE-SR4", 131, 0x04},
+	{"40GBASE-LR4", 131, 0x02},
+	{"40G Active Cable", 131, 0x01},
+
+	// Byte 134: Gigabit Ethernet
+	{"1000BASE-T", 134, 0x08},
+	{"1000BASE-CX", 134, 0x04},
+	{"1000BASE-LX", 134, 0x02},
+	{"1000BASE-SX", 134, 0x01},
+}
+
+// QSFPExtendedCompliance maps SFF-8024 extended compliance codes (SFF-8636
+// byte 192, valid when byte 131 bit 7 is set) to names.
+var QSFPExtendedCompliance = map[byte]string{
+	0x01: "100G AOC",
+	0x02: "100GBASE-SR4",
+	0x03: "100GBASE-LR4",
+	0x04: "100GBASE-ER4",
+	0x05: "100GBASE-SR10",
+	0x06: "100G CWDM4",
+	0x07: "100G PSM4",
+	0x08: "100G ACC",
+	0x0B: "100GBASE-CR4",
+	0x10: "40GBASE-ER4",
+	0x11: "4x10GBASE-SR",
+	0x12: "40G PSM4",
+	0x16: "10GBASE-T SFI",
+	0x17: "100G CLR4",
+	0x18: "100G AOC (BER 1e-12)",
+	0x19: "100G ACC (BER 1e-12)",
+	0x1C: "10GBASE-T SR",
+}
+
+// ComplianceNames returns the names of all compliance codes set in an image.
+func ComplianceNames(data []byte) []string {
+	var names []string
+	if len(data) >= 256 && isQSFPIdentifier(data[0]) {
+		for _, c := range QSFPComplianceCodes {
+			if data[c.Offset]&c.Mask != 0 {
+				names = append(names, c.Name)
+			}
+		}
+		if data[131]&0x80 != 0 {
+			if name, ok := QSFPExtendedCompliance[data[192]]; ok {
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+
+	if len(data) < 11 {
+		return nil
+	}
+	for _, c := range SFPComplianceCodes {
+		if data[c.Offset]&c.Mask != 0 {
+			names = append(names, c.Name)
+		}
+	}
+	return names
+}
+
+// GetIdentifierType returns a string description for an SFF-8024 identifier
+func GetIdentifierType(code byte) string {
+	switch code {
+	case 0x01:
+		return "GBIC"
+	case 0x02:
+		return "Module soldered to motherboard"
+	case 0x03:
+		return "SFP/SFP+"
+	case 0x04:
+		return "300 pin XBI"
+	case 0x05:
+		return "XENPAK"
+	case 0x06:
+		return "XFP"
+	case 0x07:
+		return "XFF"
+	case 0x08:
+		return "XFP-E"
+	case 0x09:
+		return "XPAK"
+	case 0x0A:
+		return "X2"
+	case 0x0C:
+		return "QSFP"
+	case 0x0D:
+		return "QSFP+"
+	case 0x11:
+		return "QSFP28"
+	default:
+		return "Unknown"
+	}
+}
@@ -13,6 +13,25 @@ func Log10(x float64) float64 {
 	return math.Log10(x)
 }
 
+// NominalBitrate returns the nominal bitrate in Mbps from byte 12 (SFP) or
+// 140 (QSFP), in units of 100 Mbps. Above 25.4G that byte is 0xFF and the
+// rate is in byte 66 or 222, in units of 250 Mbps.
+func NominalBitrate(data []byte, qsfp bool) int {
+	br, ext := 12, 66
+	if qsfp {
+		br, ext = 140, 222
+	}
+	switch {
+	case len(data) <= br:
+		return 0
+	case data[br] != 0xff:
+		return int(data[br]) * 100
+	case len(data) <= ext:
+		return 0
+	}
+	return int(data[ext]) * 250
+}
+
 // GetConnectorType returns a string description for connector type code
 func GetConnectorType(code byte) string {
 	switch code {
@@ -0,0 +1,335 @@
+package eeprom
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Snapshot sizes used by the device
+const (
+	SFPSize  = 512 // A0h + A2h
+	QSFPSize = 640 // Lower page + upper pages 00h-03h
+)
+
+// Dump formats recognised by Decode
+const (
+	FormatRaw        = "raw"
+	FormatEthtoolRaw = "ethtool-raw"
+	FormatEthtoolHex = "ethtool-hex"
+	FormatCisco      = "cisco-idprom"
+	FormatJuniper    = "juniper-pic"
+	FormatArista     = "arista-idprom"
+	FormatI2CDump    = "i2cdump"
+	FormatHex        = "hex"
+	FormatIntelHex   = "intel-hex"
+	FormatJSON       = "json"
+	FormatYAML       = "yaml"
+)
+
+// Decode auto-detects the format of an EEPROM dump and returns it normalised
+// to the device's 512-byte (SFP) or 640-byte (QSFP) snapshot layout.
+//
+// Binary input is treated as a raw dump (device snapshot, sysfs eeprom file or
+// `ethtool -m <if> raw on`) and must be 128, 256, 512 or 640 bytes. Text input is parsed as an sfpw JSON/YAML profile
+// document, Intel HEX, or a hex dump: `ethtool -m hex on`, Cisco `show
+// idprom`, Juniper `show chassis pic`, Arista `show idprom transceiver` and
+// i2c-tools `i2cdump` output for 0x50/0x51.
+func Decode(input []byte) ([]byte, string, error) {
+	if len(input) == 0 {
+		return nil, "", fmt.Errorf("empty input")
+	}
+
+	// Profile documents and Intel HEX come first: exported documents may
+	// carry non-ASCII text (notes, 0xFF-padded vendor fields) and must not
+	// be mistaken for binary
+	text := isText(input)
+	data, format, err := decodeDocument(input)
+	if err != nil && text {
+		return nil, "", err
+	}
+	if err == nil && format != "" {
+		data, err = Normalize(data)
+		return data, format, err
+	}
+
+	if bytes.HasPrefix(bytes.TrimSpace(input), []byte(":")) {
+		raw, err := decodeIntelHex(input)
+		if err == nil {
+			data, err := Normalize(raw)
+			return data, FormatIntelHex, err
+		}
+		if text {
+			return nil, "", err
+		}
+	}
+
+	if !text {
+		switch len(input) {
+		case SFPSize, QSFPSize:
+			return input, FormatRaw, nil
+		case 128, 256:
+			data, err := Normalize(input)
+			return data, FormatEthtoolRaw, err
+		}
+		return nil, "", fmt.Errorf("unrecognised binary dump of %d bytes (expected 128, 256, 512 or 640)", len(input))
+	}
+
+	raw, err := parseHexDump(input)
+	if err != nil {
+		return nil, "", err
+	}
+	data, err = Normalize(raw)
+	return data, detectTextFormat(input), err
+}
+
+// isText reports whether input looks like a text document: valid UTF-8
+// without NUL or other control characters besides tabs and line breaks.
+func isText(input []byte) bool {
+	if !utf8.Valid(input) {
+		return false
+	}
+	for _, r := range string(input) {
+		if unicode.IsControl(r) && r != '\t' && r != '\n' && r != '\r' {
+			return false
+		}
+	}
+	return true
+}
+
+// Normalize pads or validates a raw dump to the device snapshot layout.
+// SFP dumps of A0h only (128 or 256 bytes) get a zero-filled A2h page;
+// SFF-8436 QSFP dumps (256 bytes) get zero-filled upper pages 01h-03h.
+func Normalize(raw []byte) ([]byte, error) {
+	if len(raw) == SFPSize || len(raw) == QSFPSize {
+		return raw, nil
+	}
+	if len(raw) < 96 {
+		return nil, fmt.Errorf("dump too short: %d bytes", len(raw))
+	}
+
+	size := SFPSize
+	if isQSFPIdentifier(raw[0]) {
+		size = QSFPSize
+		if len(raw) < 256 {
+			return nil, fmt.Errorf("QSFP dump too short: %d bytes (need lower page and upper page 00h)", len(raw))
+		}
+	}
+	if len(raw) > size {
+		return nil, fmt.Errorf("unexpected dump size %d (expected at most %d)", len(raw), size)
+	}
+
+	data := make([]byte, size)
+	copy(data, raw)
+	return data, nil
+}
+
+// textFormats identify the tool that produced a text hex dump by its
+// command line or header lines, checked in order. Arista and Cisco share
+// "show idprom", so the longer Arista command comes first.
+var textFormats = []struct {
+	format string
+	re     *regexp.Regexp
+}{
+	{FormatEthtoolHex, regexp.MustCompile(`(?m)^\s*offset\s+values\s*$`)},
+	{FormatI2CDump, regexp.MustCompile(`(?m)^\s*0\s+1\s+2\s+3\s+4\s+5\s+6\s+7\s+8\s+9\s+a\s+b\s+c\s+d\s+e\s+f\b|^\S*[#$>]?\s*i2cdump\b`)},
+	{FormatArista, regexp.MustCompile(`(?m)^\S*[#>]?\s*show\s+idprom\s+transceiver\b`)},
+	{FormatCisco, regexp.MustCompile(`(?m)^\S*[#>]?\s*show\s+idprom\b|^\s*(?:sfp|qsfp)?\s*idprom\s+page\b|^\s*idprom\s+for\s+transceiver\b`)},
+	{FormatJuniper, regexp.MustCompile(`(?m)^\S*>?\s*show\s+chassis\s+pic\b|^\s*fpc\s+slot\s+\d+,\s+pic\s+slot\s+\d+`)},
+}
+
+// detectTextFormat guesses which tool produced a text hex dump.
+func detectTextFormat(input []byte) string {
+	lower := strings.ToLower(string(input))
+	for _, f := range textFormats {
+		if f.re.MatchString(lower) {
+			return f.format
+		}
+	}
+	return FormatHex
+}
+
+var (
+	// Lines announcing the second two-wire address (A2h / 0x51)
+	a2MarkerRe = regexp.MustCompile(`\ba2h?\b|\b0xa2\b|\b0x51\b`)
+	// Lines announcing A0h / 0x50
+	a0MarkerRe = regexp.MustCompile(`\ba0h?\b|\b0xa0\b|\b0x50\b`)
+	// Lines announcing a QSFP upper page 01h-03h
+	pageMarkerRe = regexp.MustCompile(`\bpage\s+(?:0x)?0?([1-3])h?\b`)
+	offsetRe     = regexp.MustCompile(`^(0x)?([0-9a-f]{1,5})h?:?$`)
+	byteRe       = regexp.MustCompile(`^([0-9a-f]{2}|xx)$`)
+)
+
+type hexLine struct {
+	offset string // Offset token as printed
+	isHex  bool   // Offset had an explicit 0x prefix
+	data   []byte
+}
+
+type hexBlock struct {
+	base  int  // Image offset the block is placed at
+	upper bool // QSFP upper page: offsets 128-255 map to the page start
+	lines []hexLine
+}
+
+// parseHexDump extracts bytes from a text hex dump. Each run of increasing
+// offsets forms a block; blocks are placed by page markers or, failing
+// that, back to back on 256-byte boundaries.
+func parseHexDump(input []byte) ([]byte, error) {
+	var blocks []*hexBlock
+	var cur *hexBlock
+	nextBase := -1
+	nextUpper := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(input))
+	for scanner.Scan() {
+		line := strings.ToLower(scanner.Text())
+
+		hl, ok := parseHexLine(line)
+		if !ok {
+			switch {
+			case pageMarkerRe.MatchString(line):
+				n, _ := strconv.Atoi(pageMarkerRe.FindStringSubmatch(line)[1])
+				nextBase, nextUpper = 128*(n+1), true
+				cur = nil
+			case a2MarkerRe.MatchString(line):
+				nextBase, nextUpper = 256, false
+				cur = nil
+			case a0MarkerRe.MatchString(line):
+				nextBase, nextUpper = 0, false
+				cur = nil
+			}
+			continue
+		}
+
+		// Offsets restarting means a new address or page
+		if cur != nil && len(cur.lines) > 0 {
+			prev, _ := strconv.ParseInt(cur.lines[len(cur.lines)-1].offset, 16, 64)
+			off, _ := strconv.ParseInt(hl.offset, 16, 64)
+			if off <= prev {
+				cur = nil
+			}
+		}
+
+		if cur == nil {
+			cur = &hexBlock{base: nextBase, upper: nextUpper}
+			blocks = append(blocks, cur)
+			nextBase, nextUpper = -1, false
+		}
+		cur.lines = append(cur.lines, hl)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(blocks) == 0 {
+		return nil, fmt.Errorf("no hex dump lines found")
+	}
+
+	image := make(map[int]byte)
+	size := 0
+	end := 0
+	for _, b := range blocks {
+		offsets := b.offsets()
+		base := b.base
+		if base < 0 {
+			base = (end + 255) / 256 * 256
+		}
+		for i, l := range b.lines {
+			off := offsets[i]
+			if b.upper && off >= 128 {
+				off -= 128
+			}
+			for j, v := range l.data {
+				pos := base + off + j
+				image[pos] = v
+				size = max(size, pos+1)
+			}
+		}
+		end = size
+	}
+
+	data := make([]byte, size)
+	for pos, v := range image {
+		data[pos] = v
+	}
+	return data, nil
+}
+
+// parseHexLine parses "[label] offset[:] xx xx xx ..." into its parts.
+func parseHexLine(line string) (hexLine, bool) {
+	fields := strings.Fields(line)
+
+	// Allow a single leading label such as "Address"
+	if len(fields) > 0 && !offsetRe.MatchString(fields[0]) {
+		fields = fields[1:]
+	}
+	if len(fields) < 2 {
+		return hexLine{}, false
+	}
+
+	m := offsetRe.FindStringSubmatch(fields[0])
+	if m == nil {
+		return hexLine{}, false
+	}
+
+	var data []byte
+	for _, f := range fields[1:] {
+		if len(data) == 16 || !byteRe.MatchString(f) {
+			break // Trailing ASCII column
+		}
+		if f == "xx" {
+			data = append(data, 0xff) // i2cdump read error
+			continue
+		}
+		v, _ := strconv.ParseUint(f, 16, 8)
+		data = append(data, byte(v))
+	}
+	if len(data) < 4 {
+		return hexLine{}, false
+	}
+
+	return hexLine{offset: m[2], isHex: m[1] != "", data: data}, true
+}
+
+// offsets resolves line offsets as hex or decimal. Without an explicit 0x
+// prefix, the interpretation whose steps match the row lengths wins (Cisco
+// prints decimal offsets with 10-byte rows, most other tools hex with 16).
+func (b *hexBlock) offsets() []int {
+	parse := func(base int) []int {
+		out := make([]int, len(b.lines))
+		for i, l := range b.lines {
+			v, err := strconv.ParseInt(l.offset, base, 64)
+			if err != nil {
+				return nil
+			}
+			out[i] = int(v)
+		}
+		return out
+	}
+	score := func(offs []int) int {
+		if offs == nil {
+			return -1
+		}
+		n := 0
+		for i := 1; i < len(offs); i++ {
+			if offs[i]-offs[i-1] == len(b.lines[i-1].data) {
+				n++
+			}
+		}
+		return n
+	}
+
+	hex := parse(16)
+	if len(b.lines) > 0 && b.lines[0].isHex {
+		return hex
+	}
+	if dec := parse(10); score(dec) > score(hex) {
+		return dec
+	}
+	return hex
+}
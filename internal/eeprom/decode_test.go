@@ -0,0 +1,81 @@
+package eeprom
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestDecodeTextFormats(t *testing.T) {
+	tests := []struct {
+		file   string
+		format string
+		want   string
+	}{
+		{"ethtool-hex.txt", FormatEthtoolHex, "sfp.bin"},
+		{"cisco-idprom.txt", FormatCisco, "sfp.bin"},
+		{"juniper-pic.txt", FormatJuniper, "sfp.bin"},
+		{"arista-idprom.txt", FormatArista, "qsfp.bin"},
+		{"i2cdump.txt", FormatI2CDump, "sfp.bin"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			data, format, err := Decode(readFixture(t, tt.file))
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if format != tt.format {
+				t.Errorf("format = %q, want %q", format, tt.format)
+			}
+			if want := readFixture(t, tt.want); !bytes.Equal(data, want) {
+				t.Errorf("decoded data differs from %s", tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeNonASCIIText(t *testing.T) {
+	input := append([]byte("# séance on sw-köln-01\n"), readFixture(t, "ethtool-hex.txt")...)
+	data, format, err := Decode(input)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if format != FormatEthtoolHex {
+		t.Errorf("format = %q, want %q", format, FormatEthtoolHex)
+	}
+	if !bytes.Equal(data, readFixture(t, "sfp.bin")) {
+		t.Error("decoded data differs from sfp.bin")
+	}
+}
+
+func TestDecodeBinarySizes(t *testing.T) {
+	sfp := readFixture(t, "sfp.bin")
+
+	data, format, err := Decode(sfp[:256])
+	if err != nil {
+		t.Fatalf("Decode 256 bytes: %v", err)
+	}
+	if format != FormatEthtoolRaw || len(data) != SFPSize || !bytes.Equal(data[:256], sfp[:256]) {
+		t.Errorf("256-byte dump decoded as %q, %d bytes", format, len(data))
+	}
+
+	if _, format, err := Decode(sfp); err != nil || format != FormatRaw {
+		t.Errorf("512-byte dump: format %q, err %v", format, err)
+	}
+
+	for _, n := range []int{100, 300, 500} {
+		if _, _, err := Decode(sfp[:n]); err == nil {
+			t.Errorf("%d-byte binary dump: expected an error", n)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package eeprom
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DocumentFormat identifies sfpw JSON/YAML profile documents.
+const DocumentFormat = "sfpw-profile/1"
+
+// Field is a decoded EEPROM field annotated with its location.
+type Field struct {
+	Name   string `json:"name" yaml:"name"`
+	Offset int    `json:"offset" yaml:"offset"`
+	Length int    `json:"length" yaml:"length"`
+	Value  string `json:"value" yaml:"value"`
+	Raw    string `json:"raw" yaml:"raw"` // Hex bytes
+}
+
+// Page is a memory page of an EEPROM image as rows of 16 hex bytes.
+// Pages carry the exact image bytes so documents round-trip losslessly.
+type Page struct {
+	Name   string   `json:"name" yaml:"name"`
+	Offset int      `json:"offset" yaml:"offset"`
+	Data   []string `json:"data" yaml:"data"`
+}
+
+// Annotate decodes the identity fields of an EEPROM image.
+func Annotate(data []byte) []Field {
+	var fields []Field
+	add := func(name string, offset, length int, value string) {
+		if offset+length > len(data) {
+			return
+		}
+		fields = append(fields, Field{
+			Name:   name,
+			Offset: offset,
+			Length: length,
+			Value:  value,
+			Raw:    hex.EncodeToString(data[offset : offset+length]),
+		})
+	}
+	ascii := func(offset, length int) string {
+		if offset+length > len(data) {
+			return ""
+		}
+		return strings.TrimSpace(string(data[offset : offset+length]))
+	}
+	u16 := func(offset int) int {
+		if offset+2 > len(data) {
+			return 0
+		}
+		return int(data[offset])<<8 | int(data[offset+1])
+	}
+
+	if len(data) >= 256 && isQSFPIdentifier(data[0]) {
+		add("identifier", 128, 1, GetIdentifierType(data[128]))
+		add("ext_identifier", 129, 1, fmt.Sprintf("0x%02X", data[129]))
+		add("connector", 130, 1, GetConnectorType(data[130]))
+		add("compliance", 131, 8, strings.Join(ComplianceNames(data), ", "))
+		add("encoding", 139, 1, GetEncodingType(data[139]))
+		add("bitrate_mbps", 140, 1, strconv.Itoa(int(data[140])*100))
+		add("length_smf_km", 142, 1, strconv.Itoa(int(data[142])))
+		add("length_om3_m", 143, 1, strconv.Itoa(int(data[143])*2))
+		add("length_om2_m", 144, 1, strconv.Itoa(int(data[144])))
+		add("length_om1_m", 145, 1, strconv.Itoa(int(data[145])))
+		add("length_copper_m", 146, 1, strconv.Itoa(int(data[146])))
+		add("vendor_name", 148, 16, ascii(148, 16))
+		add("vendor_oui", 165, 3, fmt.Sprintf("%02X:%02X:%02X", data[165], data[166], data[167]))
+		add("part_number", 168, 16, ascii(168, 16))
+		add("revision", 184, 2, ascii(184, 2))
+		add("wavelength_nm", 186, 2, fmt.Sprintf("%.2f", float64(u16(186))/20))
+		add("cc_base", 191, 1, fmt.Sprintf("0x%02X", data[191]))
+		add("ext_compliance", 192, 1, QSFPExtendedCompliance[data[192]])
+		add("serial_number", 196, 16, ascii(196, 16))
+		add("date_code", 212, 8, ascii(212, 8))
+		add("diag_type", 220, 1, fmt.Sprintf("0x%02X", data[220]))
+		add("cc_ext", 223, 1, fmt.Sprintf("0x%02X", data[223]))
+		return fields
+	}
+
+	if len(data) < 96 {
+		return nil
+	}
+	add("identifier", 0, 1, GetIdentifierType(data[0]))
+	add("ext_identifier", 1, 1, fmt.Sprintf("0x%02X", data[1]))
+	add("connector", 2, 1, GetConnectorType(data[2]))
+	add("compliance", 3, 8, strings.Join(ComplianceNames(data), ", "))
+	add("encoding", 11, 1, GetEncodingType(data[11]))
+	add("bitrate_mbps", 12, 1, strconv.Itoa(int(data[12])*100))
+	add("rate_identifier", 13, 1, fmt.Sprintf("0x%02X", data[13]))
+	add("length_smf_km", 14, 1, strconv.Itoa(int(data[14])))
+	add("length_smf_m", 15, 1, strconv.Itoa(int(data[15])*100))
+	add("length_om2_m", 16, 1, strconv.Itoa(int(data[16])*10))
+	add("length_om1_m", 17, 1, strconv.Itoa(int(data[17])*10))
+	add("length_om4_m", 18, 1, strconv.Itoa(int(data[18])*10))
+	add("length_om3_m", 19, 1, strconv.Itoa(int(data[19])*10))
+	add("vendor_name", 20, 16, ascii(20, 16))
+	add("vendor_oui", 37, 3, fmt.Sprintf("%02X:%02X:%02X", data[37], data[38], data[39]))
+	add("part_number", 40, 16, ascii(40, 16))
+	add("revision", 56, 4, ascii(56, 4))
+	add("wavelength_nm", 60, 2, strconv.Itoa(u16(60)))
+	add("cc_base", 63, 1, fmt.Sprintf("0x%02X", data[63]))
+	add("options", 64, 2, fmt.Sprintf("0x%04X", u16(64)))
+	add("serial_number", 68, 16, ascii(68, 16))
+	add("date_code", 84, 8, ascii(84, 8))
+	add("diag_type", 92, 1, fmt.Sprintf("0x%02X", data[92]))
+	add("enhanced_options", 93, 1, fmt.Sprintf("0x%02X", data[93]))
+	add("sff8472_compliance", 94, 1, fmt.Sprintf("0x%02X", data[94]))
+	add("cc_ext", 95, 1, fmt.Sprintf("0x%02X", data[95]))
+	return fields
+}
+
+// Pages splits an image into its memory pages.
+func Pages(data []byte) []Page {
+	type span struct {
+		name          string
+		offset, limit int
+	}
+	var spans []span
+	if len(data) == QSFPSize {
+		spans = []span{
+			{"lower", 0, 128},
+			{"upper00", 128, 256},
+			{"upper01", 256, 384},
+			{"upper02", 384, 512},
+			{"upper03", 512, 640},
+		}
+	} else {
+		spans = []span{{"A0h", 0, min(256, len(data))}}
+		if len(data) > 256 {
+			spans = append(spans, span{"A2h", 256, len(data)})
+		}
+	}
+
+	pages := make([]Page, 0, len(spans))
+	for _, s := range spans {
+		p := Page{Name: s.name, Offset: s.offset}
+		for i := s.offset; i < s.limit; i += 16 {
+			row := data[i:min(i+16, s.limit)]
+			p.Data = append(p.Data, strings.TrimSpace(fmt.Sprintf("% x", row)))
+		}
+		pages = append(pages, p)
+	}
+	return pages
+}
+
+// PagesToData reassembles an image from its pages.
+func PagesToData(pages []Page) ([]byte, error) {
+	var data []byte
+	for _, p := range pages {
+		pos := p.Offset
+		for i, row := range p.Data {
+			b, err := hex.DecodeString(strings.ReplaceAll(row, " ", ""))
+			if err != nil {
+				return nil, fmt.Errorf("page %s row %d: %w", p.Name, i, err)
+			}
+			if end := pos + len(b); end > len(data) {
+				data = append(data, make([]byte, end-len(data))...)
+			}
+			copy(data[pos:], b)
+			pos += len(b)
+		}
+	}
+	return data, nil
+}
+
+// documentPages is the subset of a profile document needed to rebuild the image.
+type documentPages struct {
+	Format string `json:"format" yaml:"format"`
+	Pages  []Page `json:"pages" yaml:"pages"`
+}
+
+// decodeDocument extracts the image from a JSON or YAML profile document.
+// Returns an empty format if the input is not a profile document.
+func decodeDocument(input []byte) ([]byte, string, error) {
+	trimmed := bytes.TrimSpace(input)
+	var doc documentPages
+	var format string
+
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		if err := json.Unmarshal(trimmed, &doc); err != nil {
+			return nil, "", nil
+		}
+		format = FormatJSON
+	case bytes.Contains(trimmed, []byte(DocumentFormat)):
+		if err := yaml.Unmarshal(trimmed, &doc); err != nil {
+			return nil, "", fmt.Errorf("invalid YAML profile: %w", err)
+		}
+		format = FormatYAML
+	}
+	if doc.Format != DocumentFormat {
+		return nil, "", nil
+	}
+
+	data, err := PagesToData(doc.Pages)
+	return data, format, err
+}
+
+// WriteIntelHex writes an image as Intel HEX with 16-byte data records.
+func WriteIntelHex(w io.Writer, data []byte) error {
+	bw := bufio.NewWriter(w)
+	for i := 0; i < len(data); i += 16 {
+		row := data[i:min(i+16, len(data))]
+		rec := append([]byte{byte(len(row)), byte(i >> 8), byte(i), 0x00}, row...)
+		fmt.Fprintf(bw, ":%s%02X\n", strings.ToUpper(hex.EncodeToString(rec)), intelHexChecksum(rec))
+	}
+	fmt.Fprintln(bw, ":00000001FF")
+	return bw.Flush()
+}
+
+func intelHexChecksum(rec []byte) byte {
+	var sum byte
+	for _, b := range rec {
+		sum += b
+	}
+	return -sum
+}
+
+// decodeIntelHex parses Intel HEX data records (types 00 and 01).
+func decodeIntelHex(input []byte) ([]byte, error) {
+	var data []byte
+	scanner := bufio.NewScanner(bytes.NewReader(input))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		rec, err := hex.DecodeString(strings.TrimPrefix(line, ":"))
+		if err != nil || len(rec) < 5 || int(rec[0])+5 != len(rec) {
+			return nil, fmt.Errorf("invalid Intel HEX record %q", line)
+		}
+		if intelHexChecksum(rec[:len(rec)-1]) != rec[len(rec)-1] {
+			return nil, fmt.Errorf("Intel HEX checksum mismatch in %q", line)
+		}
+		switch rec[3] {
+		case 0x00:
+			addr := int(rec[1])<<8 | int(rec[2])
+			payload := rec[4 : len(rec)-1]
+			if end := addr + len(payload); end > len(data) {
+				data = append(data, make([]byte, end-len(data))...)
+			}
+			copy(data[addr:], payload)
+		case 0x01:
+			return data, nil
+		}
+	}
+	return data, scanner.Err()
+}
+
+// WriteEthtoolHex writes an image in the style of `ethtool -m <if> hex on`.
+func WriteEthtoolHex(w io.Writer, data []byte) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprint(bw, "Offset\t\tValues\n------\t\t------\n")
+	for i := 0; i < len(data); i += 16 {
+		fmt.Fprintf(bw, "0x%04x:\t\t% x\n", i, data[i:min(i+16, len(data))])
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,367 @@
+package eeprom
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Severity indicates how serious a lint finding is.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Finding is a single spec-compliance problem found in an EEPROM image.
+type Finding struct {
+	Severity Severity `json:"severity"`
+	Offset   int      `json:"offset"` // Byte offset of the offending field in the image
+	Field    string   `json:"field"`
+	Message  string   `json:"message"`
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%-7s 0x%03X  %-16s %s", f.Severity, f.Offset, f.Field, f.Message)
+}
+
+// HasErrors returns true if any finding has error severity.
+func HasErrors(findings []Finding) bool {
+	for _, f := range findings {
+		if f.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Lint checks an EEPROM image against SFF-8472 (SFP) or SFF-8636 (QSFP).
+// The memory layout is inferred from the image size: 512 bytes is A0h+A2h,
+// 640 bytes is QSFP lower page + upper pages 00h-03h.
+func Lint(data []byte) []Finding {
+	l := &linter{data: data}
+
+	switch len(data) {
+	case 512:
+		l.lintSFP()
+	case 640:
+		l.lintQSFP()
+	default:
+		if len(data) < 96 {
+			l.errorf(0, "size", "image too short: %d bytes", len(data))
+			return l.findings
+		}
+		l.warnf(0, "size", "unexpected image size %d (expected 512 for SFP or 640 for QSFP)", len(data))
+		if isQSFPIdentifier(data[0]) && len(data) >= 256 {
+			l.lintQSFP()
+		} else {
+			l.lintSFP()
+		}
+	}
+
+	return l.findings
+}
+
+type linter struct {
+	data     []byte
+	findings []Finding
+}
+
+func (l *linter) errorf(offset int, field, format string, args ...any) {
+	l.findings = append(l.findings, Finding{SeverityError, offset, field, fmt.Sprintf(format, args...)})
+}
+
+func (l *linter) warnf(offset int, field, format string, args ...any) {
+	l.findings = append(l.findings, Finding{SeverityWarning, offset, field, fmt.Sprintf(format, args...)})
+}
+
+func isQSFPIdentifier(b byte) bool {
+	return b == 0x0c || b == 0x0d || b == 0x11
+}
+
+func (l *linter) lintSFP() {
+	d := l.data
+
+	// Byte 0: Identifier must describe an SFP-style A0h/A2h layout
+	if isQSFPIdentifier(d[0]) {
+		l.errorf(0, "identifier", "identifier 0x%02X is QSFP but image uses SFP (A0h/A2h) layout", d[0])
+	} else if d[0] != 0x03 && d[0] != 0x02 {
+		l.errorf(0, "identifier", "identifier 0x%02X is not SFP/SFP+ (0x03)", d[0])
+	}
+
+	// Byte 1: Extended identifier should be 0x04 (serial ID defined by two-wire interface)
+	if d[1] != 0x04 {
+		l.warnf(1, "ext_identifier", "extended identifier 0x%02X (expected 0x04)", d[1])
+	}
+
+	l.checksum(0, 63, "cc_base")
+	l.checksum(64, 95, "cc_ext")
+
+	l.asciiField(20, 16, "vendor_name")
+	l.asciiField(40, 16, "part_number")
+	l.asciiField(56, 4, "revision")
+	l.asciiField(68, 16, "serial_number")
+	l.dateCode(84)
+	l.vendorOUI(37, 20)
+
+	wavelength := int(d[60])<<8 | int(d[61])
+	l.sfpCompliance(wavelength, NominalBitrate(d, false))
+
+	// Byte 92 bit 6: Digital diagnostics implemented
+	if len(d) >= 512 {
+		a2 := d[256:512]
+		ddm := d[92]&0x40 != 0
+		if !ddm && !isBlank(a2) {
+			l.warnf(92, "diag_type", "DDM implemented bit not set but A2h contains data")
+		}
+		if ddm {
+			// A2h bytes 0-94 are covered by CC_DMI at byte 95
+			l.checksum(256, 256+95, "cc_dmi")
+		}
+	}
+}
+
+func (l *linter) lintQSFP() {
+	d := l.data
+
+	// Byte 0 and upper page 00h byte 128 both carry the identifier
+	if !isQSFPIdentifier(d[128]) {
+		l.errorf(128, "identifier", "identifier 0x%02X is not QSFP but image uses QSFP layout", d[128])
+	} else if d[0] != d[128] {
+		l.errorf(0, "identifier", "lower page identifier 0x%02X does not match upper page 0x%02X", d[0], d[128])
+	}
+
+	l.checksum(128, 191, "cc_base")
+	l.checksum(192, 223, "cc_ext")
+
+	l.asciiField(148, 16, "vendor_name")
+	l.asciiField(168, 16, "part_number")
+	l.asciiField(184, 2, "revision")
+	l.asciiField(196, 16, "serial_number")
+	l.dateCode(212)
+	l.vendorOUI(165, 148)
+
+	// Bytes 186-187: Wavelength in units of 0.05 nm, or copper cable
+	// attenuation for copper transmitters (byte 147 bits 7-4 >= 1010b)
+	wavelength := 0
+	if d[147]>>4 < 0x0a {
+		wavelength = (int(d[186])<<8 | int(d[187])) / 20
+	}
+	l.qsfpCompliance(wavelength, NominalBitrate(d, true))
+}
+
+// checksum verifies that the byte at end is the low 8 bits of the sum of [start, end).
+func (l *linter) checksum(start, end int, field string) {
+	if end >= len(l.data) {
+		return
+	}
+	var sum byte
+	for _, b := range l.data[start:end] {
+		sum += b
+	}
+	if sum != l.data[end] {
+		l.errorf(end, field, "checksum 0x%02X invalid (calculated 0x%02X)", l.data[end], sum)
+	}
+}
+
+// asciiField checks that a fixed-width ASCII field is printable and space-padded.
+func (l *linter) asciiField(offset, length int, field string) {
+	value := l.data[offset : offset+length]
+	trimmed := strings.TrimRight(string(value), " ")
+
+	if strings.Trim(string(value), " \x00") == "" {
+		l.warnf(offset, field, "field is empty")
+		return
+	}
+
+	nulPadded := false
+	for i, b := range value {
+		if b == 0x00 && strings.Trim(string(value[i:]), "\x00 ") == "" {
+			nulPadded = true
+			break
+		}
+		if b < 0x20 || b > 0x7e {
+			l.errorf(offset+i, field, "non-printable byte 0x%02X in %q", b, trimmed)
+			return
+		}
+	}
+	if nulPadded {
+		l.errorf(offset, field, "padded with NUL instead of spaces")
+	}
+	if strings.HasPrefix(trimmed, " ") {
+		l.warnf(offset, field, "leading spaces in %q", trimmed)
+	}
+}
+
+// dateCode validates an 8-byte YYMMDDLL date code.
+func (l *linter) dateCode(offset int) {
+	code := l.data[offset : offset+8]
+	for i := range 6 {
+		if code[i] < '0' || code[i] > '9' {
+			l.errorf(offset+i, "date_code", "non-digit in date code %q", strings.TrimRight(string(code), " \x00"))
+			return
+		}
+	}
+
+	month := int(code[2]-'0')*10 + int(code[3]-'0')
+	day := int(code[4]-'0')*10 + int(code[5]-'0')
+	if month < 1 || month > 12 {
+		l.errorf(offset+2, "date_code", "invalid month %02d in date code %q", month, string(code[:6]))
+	}
+	if day < 1 || day > 31 {
+		l.errorf(offset+4, "date_code", "invalid day %02d in date code %q", day, string(code[:6]))
+	}
+
+	// Bytes 6-7: lot code, printable ASCII or spaces
+	for i := 6; i < 8; i++ {
+		if code[i] < 0x20 || code[i] > 0x7e {
+			l.errorf(offset+i, "date_code", "non-printable lot code byte 0x%02X", code[i])
+			return
+		}
+	}
+}
+
+// vendorOUI warns when a registered OUI does not match the vendor name.
+func (l *linter) vendorOUI(ouiOffset, nameOffset int) {
+	oui := [3]byte{l.data[ouiOffset], l.data[ouiOffset+1], l.data[ouiOffset+2]}
+	if oui == [3]byte{} {
+		return
+	}
+	names, ok := knownOUIs[oui]
+	if !ok {
+		return
+	}
+
+	vendor := strings.ToUpper(strings.TrimSpace(string(l.data[nameOffset : nameOffset+16])))
+	for _, name := range names {
+		if strings.Contains(vendor, name) {
+			return
+		}
+	}
+	l.warnf(ouiOffset, "vendor_oui", "OUI %02X:%02X:%02X belongs to %s but vendor name is %q",
+		oui[0], oui[1], oui[2], names[0], vendor)
+}
+
+// knownOUIs maps IEEE OUIs commonly found in transceiver EEPROMs to the
+// vendor name tokens expected alongside them. Not exhaustive.
+var knownOUIs = map[[3]byte][]string{
+	{0x00, 0x90, 0x65}: {"FINISAR"},
+	{0x00, 0x17, 0x6A}: {"AVAGO", "BROADCOM"},
+	{0x00, 0x10, 0x18}: {"BROADCOM"},
+	{0x00, 0x1B, 0x21}: {"INTEL"},
+	{0x00, 0x02, 0xC9}: {"MELLANOX", "NVIDIA"},
+	{0x00, 0x01, 0x9C}: {"JDSU", "JDS UNIPHASE"},
+	{0x00, 0x00, 0x0C}: {"CISCO"},
+	{0x00, 0x90, 0x69}: {"JUNIPER"},
+	{0x00, 0x1C, 0x73}: {"ARISTA"},
+	{0x00, 0x09, 0x3A}: {"MOLEX"},
+	{0x78, 0xA7, 0x14}: {"AMPHENOL"},
+	{0x00, 0x00, 0x5F}: {"SUMITOMO"},
+	{0x64, 0x9D, 0x99}: {"FS", "FIBERSTORE"},
+	{0x24, 0x5A, 0x4C}: {"UBNT", "UBIQUITI"},
+}
+
+// sfpCompliance cross-checks SFF-8472 bytes 3-10 against bitrate and wavelength.
+func (l *linter) sfpCompliance(wavelength, bitrateMbps int) {
+	d := l.data
+
+	// Byte 8 bits 2-3: SFP+ passive/active cable; bytes 60-61 hold cable compliance instead of wavelength
+	if d[8]&0x0C != 0 {
+		return
+	}
+
+	type code struct {
+		offset     int
+		mask       byte
+		name       string
+		wavelength int // Nominal wavelength, 0 for copper
+		minRate    int // Minimum plausible nominal bitrate (Mbps)
+		maxRate    int // Maximum plausible nominal bitrate (Mbps), 0 for no limit
+	}
+	codes := []code{
+		{3, 0x80, "10GBASE-ER", 1550, 9900, 0},
+		{3, 0x40, "10GBASE-LRM", 1310, 9900, 0},
+		{3, 0x20, "10GBASE-LR", 1310, 9900, 0},
+		{3, 0x10, "10GBASE-SR", 850, 9900, 0},
+		{6, 0x08, "1000BASE-T", 0, 1000, 2500},
+		{6, 0x02, "1000BASE-LX", 1310, 1000, 2500},
+		{6, 0x01, "1000BASE-SX", 850, 1000, 2500},
+	}
+
+	// Dual-rate modules advertise both 1G and 10G codes with a 10G bitrate
+	dualRate := d[3]&0xF0 != 0
+
+	for _, c := range codes {
+		if d[c.offset]&c.mask == 0 {
+			continue
+		}
+		maxRate := c.maxRate
+		if dualRate {
+			maxRate = 0
+		}
+		l.checkCode(c.offset, c.name, c.wavelength, wavelength, c.minRate, maxRate, bitrateMbps)
+	}
+}
+
+// qsfpCompliance cross-checks SFF-8636 byte 131 against bitrate and wavelength.
+func (l *linter) qsfpCompliance(wavelength, bitrateMbps int) {
+	d := l.data
+
+	type code struct {
+		mask       byte
+		name       string
+		wavelength int
+	}
+	codes := []code{
+		{0x08, "40GBASE-CR4", 0},
+		{0x04, "40GBASE-SR4", 850},
+		{0x02, "40GBASE-LR4", 1310},
+	}
+
+	for _, c := range codes {
+		if d[131]&c.mask == 0 {
+			continue
+		}
+		l.checkCode(131, c.name, c.wavelength, wavelength, 0, 0, bitrateMbps)
+	}
+}
+
+func (l *linter) checkCode(offset int, name string, expected, wavelength, minRate, maxRate, bitrate int) {
+	switch {
+	case expected == 0 && wavelength != 0:
+		l.warnf(offset, "compliance", "%s is copper but wavelength is %d nm", name, wavelength)
+	case expected != 0 && wavelength != 0 && absDiff(expected, wavelength) > 100:
+		l.warnf(offset, "compliance", "%s expects ~%d nm but wavelength is %d nm", name, expected, wavelength)
+	}
+
+	if bitrate == 0 {
+		return
+	}
+	if minRate > 0 && bitrate < minRate {
+		l.warnf(offset, "compliance", "%s but nominal bitrate is only %d Mbps", name, bitrate)
+	}
+	if maxRate > 0 && bitrate > maxRate {
+		l.warnf(offset, "compliance", "%s but nominal bitrate is %d Mbps", name, bitrate)
+	}
+}
+
+func absDiff(a, b int) int {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+// isBlank returns true if data is all 0x00 or all 0xFF.
+func isBlank(data []byte) bool {
+	allZero, allFF := true, true
+	for _, b := range data {
+		if b != 0x00 {
+			allZero = false
+		}
+		if b != 0xff {
+			allFF = false
+		}
+	}
+	return allZero || allFF
+}
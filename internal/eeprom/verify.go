@@ -0,0 +1,102 @@
+package eeprom
+
+import "fmt"
+
+// Write verification outcomes
+const (
+	VerifyExact     = "exact"     // Module matches the intended image
+	VerifyPartial   = "partial"   // Module changed but differs from the intended image
+	VerifyUnchanged = "unchanged" // Module still holds its previous contents
+)
+
+// PageDiff summarises the differing bytes within one memory page.
+type PageDiff struct {
+	Page  string `json:"page"`
+	First int    `json:"first"` // Image offset of the first differing byte
+	Count int    `json:"count"`
+}
+
+// IsVolatile reports whether a byte holds live state rather than coding:
+// SFF-8472 A2h real-time diagnostics and status/control (A2h 96-127), or the
+// SFF-8636 lower page apart from the identifier (flags, monitors, controls).
+func IsVolatile(size, offset int) bool {
+	if size == QSFPSize {
+		return offset >= 1 && offset < 128
+	}
+	return offset >= 256+96 && offset < 256+128
+}
+
+// DiffPages compares two images page by page, ignoring volatile bytes.
+func DiffPages(want, got []byte) []PageDiff {
+	var diffs []PageDiff
+	for _, p := range Pages(want) {
+		d := PageDiff{Page: p.Name, First: -1}
+		end := min(p.Offset+16*len(p.Data), len(want))
+		for i := p.Offset; i < end; i++ {
+			if IsVolatile(len(want), i) {
+				continue
+			}
+			if i >= len(got) || want[i] != got[i] {
+				if d.First < 0 {
+					d.First = i
+				}
+				d.Count++
+			}
+		}
+		if d.Count > 0 {
+			diffs = append(diffs, d)
+		}
+	}
+	return diffs
+}
+
+// VerifyWrite classifies the module contents read back after a write.
+// before is the module as read prior to the write, and may be nil.
+func VerifyWrite(want, before, after []byte) (string, []PageDiff) {
+	diffs := DiffPages(want, after)
+	if len(diffs) == 0 {
+		return VerifyExact, nil
+	}
+	if before != nil && len(DiffPages(before, after)) == 0 {
+		return VerifyUnchanged, diffs
+	}
+	return VerifyPartial, diffs
+}
+
+// ByteRange is a half-open range of image offsets.
+type ByteRange struct {
+	Start, End int
+}
+
+// DiffBytes returns the ranges where two images differ, including volatile
+// bytes. Bytes past the end of the shorter image count as different.
+func DiffBytes(a, b []byte) []ByteRange {
+	var ranges []ByteRange
+	n := max(len(a), len(b))
+	for i := 0; i < n; i++ {
+		if i < len(a) && i < len(b) && a[i] == b[i] {
+			continue
+		}
+		if len(ranges) > 0 && ranges[len(ranges)-1].End == i {
+			ranges[len(ranges)-1].End++
+		} else {
+			ranges = append(ranges, ByteRange{i, i + 1})
+		}
+	}
+	return ranges
+}
+
+// PageName returns the name of the memory page holding an image offset,
+// as used by Pages.
+func PageName(size, offset int) string {
+	if size == QSFPSize {
+		if offset < 128 {
+			return "lower"
+		}
+		return fmt.Sprintf("upper%02d", offset/128-1)
+	}
+	if offset < 256 {
+		return "A0h"
+	}
+	return "A2h"
+}
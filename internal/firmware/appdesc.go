@@ -0,0 +1,117 @@
+package firmware
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// ESP32AppDescMagic starts the esp_app_desc_t structure.
+const ESP32AppDescMagic = 0xABCD5432
+
+// AppDescriptor is the ESP-IDF application description (esp_app_desc_t)
+// placed at the start of the first DROM segment.
+type AppDescriptor struct {
+	SecureVersion uint32
+	Version       string
+	ProjectName   string
+	Time          string // Build time, as __TIME__
+	Date          string // Build date, as __DATE__
+	IDFVersion    string
+	ELFSHA256     [32]byte
+}
+
+// esp_app_desc_t layout
+type rawAppDesc struct {
+	Magic         uint32
+	SecureVersion uint32
+	Reserved      [2]uint32
+	Version       [32]byte
+	ProjectName   [32]byte
+	Time          [16]byte
+	Date          [16]byte
+	IDFVersion    [32]byte
+	ELFSHA256     [32]byte
+}
+
+// AppDescriptor parses the application description of the image.
+func (img *ESP32Image) AppDescriptor() (*AppDescriptor, error) {
+	drom := img.GetDROMSegment()
+	if drom == nil {
+		return nil, fmt.Errorf("DROM segment not found")
+	}
+
+	var raw rawAppDesc
+	if err := binary.Read(bytes.NewReader(drom.Data), binary.LittleEndian, &raw); err != nil {
+		return nil, fmt.Errorf("DROM segment too short for app descriptor")
+	}
+	if raw.Magic != ESP32AppDescMagic {
+		return nil, fmt.Errorf("invalid app descriptor magic: 0x%08x (expected 0x%08x)", raw.Magic, uint32(ESP32AppDescMagic))
+	}
+
+	return &AppDescriptor{
+		SecureVersion: raw.SecureVersion,
+		Version:       cString(raw.Version[:]),
+		ProjectName:   cString(raw.ProjectName[:]),
+		Time:          cString(raw.Time[:]),
+		Date:          cString(raw.Date[:]),
+		IDFVersion:    cString(raw.IDFVersion[:]),
+		ELFSHA256:     raw.ELFSHA256,
+	}, nil
+}
+
+// BuildTime parses the build date and time. The compiler macros carry no
+// time zone, so the result is nominally UTC.
+func (d *AppDescriptor) BuildTime() (time.Time, bool) {
+	t, err := time.Parse("Jan _2 2006 15:04:05", d.Date+" "+d.Time)
+	return t, err == nil
+}
+
+// ELFSHA256Hex returns the ELF file digest as hex.
+func (d *AppDescriptor) ELFSHA256Hex() string {
+	return hex.EncodeToString(d.ELFSHA256[:])
+}
+
+// SFPWizardProjectName is the esp_app_desc_t project name of the SFP Wizard
+// app. It is the product name the firmware manifest uses (see
+// SFPWizardFilter); if `fw inspect` of a release shows a different project
+// name, this constant is what to change.
+const SFPWizardProjectName = "SFP-Wizard"
+
+// IsSFPWizard reports whether the project name is the SFP Wizard app.
+func (d *AppDescriptor) IsSFPWizard() bool {
+	return d.ProjectName == SFPWizardProjectName
+}
+
+// FlashProblems lists reasons not to flash the image to an SFP Wizard
+// running currentVersion: another project, another chip, or a downgrade.
+// The downgrade check is skipped if currentVersion is empty. Integrity is
+// checked separately by Verify.
+func (img *ESP32Image) FlashProblems(currentVersion string) []string {
+	var problems []string
+	if img.Header.ChipID != ESP32S3ChipID {
+		problems = append(problems, fmt.Sprintf("image is for %s, not ESP32-S3", img.ChipName()))
+	}
+
+	desc, err := img.AppDescriptor()
+	if err != nil {
+		return append(problems, err.Error())
+	}
+	if !desc.IsSFPWizard() {
+		problems = append(problems, fmt.Sprintf("image is project %q, not the SFP Wizard app", desc.ProjectName))
+	}
+	if currentVersion != "" && CompareVersions(desc.Version, currentVersion) < 0 {
+		problems = append(problems, fmt.Sprintf("image version %s is older than installed %s", desc.Version, currentVersion))
+	}
+	return problems
+}
+
+// cString returns a NUL-terminated string from a fixed-size field.
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
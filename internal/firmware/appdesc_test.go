@@ -0,0 +1,52 @@
+package firmware
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+// testImage returns an image with an app descriptor for project and version.
+func testImage(chipID uint16, project, version string) *ESP32Image {
+	raw := rawAppDesc{Magic: ESP32AppDescMagic}
+	copy(raw.Version[:], version)
+	copy(raw.ProjectName[:], project)
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, &raw)
+	return &ESP32Image{
+		Header:   ESP32ImageHeader{Magic: ESP32ImageMagic, ChipID: chipID},
+		Segments: []ESP32Segment{{LoadAddr: 0x3C000020, Data: buf.Bytes()}},
+	}
+}
+
+func TestFlashProblems(t *testing.T) {
+	tests := []struct {
+		name    string
+		img     *ESP32Image
+		current string
+		want    []string // Substrings of the expected problems, in order
+	}{
+		{"ok", testImage(ESP32S3ChipID, SFPWizardProjectName, "1.1.3"), "1.1.1", nil},
+		{"same version", testImage(ESP32S3ChipID, SFPWizardProjectName, "v1.1.3"), "1.1.3", nil},
+		{"no current version", testImage(ESP32S3ChipID, SFPWizardProjectName, "1.0.5"), "", nil},
+		{"chip", testImage(0, SFPWizardProjectName, "1.1.3"), "1.1.1", []string{"not ESP32-S3"}},
+		{"project", testImage(ESP32S3ChipID, "hello_world", "1.1.3"), "1.1.1", []string{`project "hello_world"`}},
+		{"downgrade", testImage(ESP32S3ChipID, SFPWizardProjectName, "1.0.5"), "1.1.3", []string{"older than installed 1.1.3"}},
+		{"all", testImage(0, "hello_world", "1.0.5"), "1.1.3", []string{"not ESP32-S3", "hello_world", "older"}},
+		{"no descriptor", &ESP32Image{Header: ESP32ImageHeader{ChipID: ESP32S3ChipID}}, "", []string{"DROM segment not found"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.img.FlashProblems(tt.current)
+			if len(got) != len(tt.want) {
+				t.Fatalf("FlashProblems(%q) = %q, want %d problem(s)", tt.current, got, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if !strings.Contains(got[i], want) {
+					t.Errorf("problem %d = %q, want it to mention %q", i, got[i], want)
+				}
+			}
+		})
+	}
+}
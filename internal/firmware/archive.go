@@ -0,0 +1,224 @@
+package firmware
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ExportCache writes every cached firmware file, its recorded checksum and
+// the saved manifests to w as a tar archive, gzipped if compress is set.
+func (s *FirmwareStore) ExportCache(w io.Writer, compress bool) (int, error) {
+	entries, err := s.List()
+	if err != nil {
+		return 0, err
+	}
+
+	out := w
+	var gz *gzip.Writer
+	if compress {
+		gz = gzip.NewWriter(w)
+		out = gz
+	}
+	tw := tar.NewWriter(out)
+
+	add := func(path string) error {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		hdr := &tar.Header{Name: filepath.Base(path), Mode: 0644, Size: int64(len(data)), ModTime: info.ModTime()}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err = tw.Write(data)
+		return err
+	}
+
+	for _, e := range entries {
+		// Record a checksum for files from before checksums were kept
+		if _, err := os.Stat(checksumPath(e.Path)); os.IsNotExist(err) {
+			sum, err := s.computeSHA256(e.Path)
+			if err != nil {
+				return 0, err
+			}
+			if err := writeChecksum(e.Path, sum); err != nil {
+				return 0, err
+			}
+		}
+		if err := add(e.Path); err != nil {
+			return 0, err
+		}
+		if err := add(checksumPath(e.Path)); err != nil {
+			return 0, err
+		}
+	}
+	for _, channel := range Channels {
+		if _, err := os.Stat(s.manifestPath(channel)); err == nil {
+			if err := add(s.manifestPath(channel)); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return 0, err
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return 0, err
+		}
+	}
+	return len(entries), nil
+}
+
+// CacheImportResult summarises an imported cache archive.
+type CacheImportResult struct {
+	Added    []string // Versions added
+	Skipped  []string // Versions already cached with the same contents
+	Replaced []string // Versions whose cached copy differed
+	Manifest bool     // A saved manifest was newer and replaced the local one
+}
+
+// ImportCache merges a cache archive written by ExportCache. Before anything
+// is written, every firmware file is checked against the checksum shipped
+// with it and, if a saved manifest lists its version, against the
+// manifest's checksum. A cached file that differs from the archived one is
+// only replaced with force. Each archived manifest replaces the local one
+// for its channel if it is newer.
+func (s *FirmwareStore) ImportCache(r io.Reader, force bool) (*CacheImportResult, error) {
+	br := bufio.NewReader(r)
+	var in io.Reader = br
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		in = gz
+	}
+
+	files := make(map[string][]byte)
+	modTimes := make(map[string]time.Time)
+	tr := tar.NewReader(in)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid cache archive: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := hdr.Name
+		if name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+			return nil, fmt.Errorf("invalid cache archive: unexpected path %q", name)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cache archive: %w", err)
+		}
+		files[name] = data
+		modTimes[name] = hdr.ModTime
+	}
+
+	// Verify everything first
+	for name, data := range files {
+		if !strings.HasSuffix(name, ".bin") {
+			continue
+		}
+		sumFile, ok := files[name+".sha256"]
+		if !ok {
+			return nil, fmt.Errorf("%s: no checksum in archive", name)
+		}
+		expected, _, _ := strings.Cut(string(sumFile), " ")
+		sum := sha256.Sum256(data)
+		actual := hex.EncodeToString(sum[:])
+		if actual != expected {
+			return nil, fmt.Errorf("%s: checksum mismatch", name)
+		}
+
+		// The archive's own checksum only proves it is intact
+		version := strings.TrimPrefix(strings.TrimSuffix(name, ".bin"), "sfpw_")
+		if known := s.manifestChecksum(version); known != "" && !strings.EqualFold(known, actual) {
+			return nil, fmt.Errorf("%s: does not match the SHA-256 in the saved manifest", name)
+		}
+		existing, err := os.ReadFile(filepath.Join(s.baseDir, name))
+		if err == nil && !bytes.Equal(existing, data) && !force {
+			return nil, fmt.Errorf("%s: differs from the cached copy (use --force to replace it)", name)
+		}
+	}
+
+	result := &CacheImportResult{}
+	for name, data := range files {
+		if !strings.HasSuffix(name, ".bin") {
+			continue
+		}
+		version := strings.TrimPrefix(strings.TrimSuffix(name, ".bin"), "sfpw_")
+		path := filepath.Join(s.baseDir, name)
+		existing, err := os.ReadFile(path)
+		switch {
+		case err == nil && bytes.Equal(existing, data):
+			result.Skipped = append(result.Skipped, version)
+			continue
+		case err == nil:
+			result.Replaced = append(result.Replaced, version)
+		default:
+			result.Added = append(result.Added, version)
+		}
+
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, data, 0644); err != nil {
+			return result, err
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			os.Remove(tmp)
+			return result, err
+		}
+		os.Chtimes(path, modTimes[name], modTimes[name])
+		if err := os.WriteFile(checksumPath(path), files[name+".sha256"], 0644); err != nil {
+			return result, err
+		}
+	}
+
+	sort.Strings(result.Added)
+	sort.Strings(result.Replaced)
+	sort.Strings(result.Skipped)
+
+	for _, channel := range Channels {
+		data, ok := files[filepath.Base(s.manifestPath(channel))]
+		if !ok {
+			continue
+		}
+		local, _ := s.LoadManifest(channel)
+		archived := &SavedManifest{}
+		if err := json.Unmarshal(data, archived); err != nil {
+			return result, fmt.Errorf("invalid manifest in archive: %w", err)
+		}
+		archived.Filter.Channel = channel
+		if local == nil || archived.Fetched.After(local.Fetched) {
+			if err := s.SaveManifest(archived); err != nil {
+				return result, err
+			}
+			result.Manifest = true
+		}
+	}
+	return result, nil
+}
@@ -3,11 +3,13 @@ package firmware
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 )
@@ -178,6 +180,10 @@ func (s *FirmwareStore) Download(v FirmwareVersion, progress ProgressCallback) (
 		os.Chtimes(destPath, v.Created, v.Created)
 	}
 
+	if err := writeChecksum(destPath, actualSHA256); err != nil {
+		return "", fmt.Errorf("failed to record checksum: %w", err)
+	}
+
 	return destPath, nil
 }
 
@@ -233,6 +239,7 @@ func (s *FirmwareStore) List() ([]FirmwareEntry, error) {
 // Remove removes a specific downloaded version.
 func (s *FirmwareStore) Remove(version string) error {
 	path := s.GetPath(version)
+	os.Remove(checksumPath(path))
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		return nil // Already gone
 	}
@@ -283,5 +290,164 @@ func (s *FirmwareStore) ImportFile(srcPath string) (storagePath string, sha256su
 		return "", "", 0, fmt.Errorf("failed to finalize import: %w", err)
 	}
 
+	if err := writeChecksum(destPath, sha256sum); err != nil {
+		return "", "", 0, fmt.Errorf("failed to record checksum: %w", err)
+	}
+
 	return destPath, sha256sum, size, nil
 }
+
+// checksumPath returns the sha256sum-style file recording a firmware
+// file's checksum.
+func checksumPath(path string) string {
+	return path + ".sha256"
+}
+
+func writeChecksum(path, sum string) error {
+	line := fmt.Sprintf("%s  %s\n", sum, filepath.Base(path))
+	return os.WriteFile(checksumPath(path), []byte(line), 0644)
+}
+
+// StoredChecksum returns the SHA-256 recorded for a firmware file when it
+// was downloaded or imported, falling back to the saved manifest for files
+// downloaded before checksums were recorded. It returns "" if none is known.
+func (s *FirmwareStore) StoredChecksum(entry FirmwareEntry) string {
+	if data, err := os.ReadFile(checksumPath(entry.Path)); err == nil {
+		if sum, _, ok := strings.Cut(string(data), " "); ok {
+			return sum
+		}
+	}
+	return s.manifestChecksum(entry.Version)
+}
+
+// manifestChecksum returns the SHA-256 of a version from the saved
+// manifests, or "" if none lists it.
+func (s *FirmwareStore) manifestChecksum(version string) string {
+	for _, channel := range Channels {
+		m, err := s.LoadManifest(channel)
+		if err != nil || m == nil {
+			continue
+		}
+		for _, v := range m.Versions {
+			if SameVersion(v.Version, version) && v.SHA256 != "" {
+				return v.SHA256
+			}
+		}
+	}
+	return ""
+}
+
+// CacheStatus is the result of verifying one cached firmware file.
+type CacheStatus struct {
+	Entry    FirmwareEntry
+	Expected string // Recorded SHA-256, empty if unknown
+	Actual   string
+	Err      error
+}
+
+// OK reports whether the file matches its recorded checksum.
+func (c CacheStatus) OK() bool {
+	return c.Err == nil && c.Expected != "" && c.Expected == c.Actual
+}
+
+// Verify re-hashes every cached firmware file and compares it with its
+// recorded checksum.
+func (s *FirmwareStore) Verify() ([]CacheStatus, error) {
+	entries, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	var result []CacheStatus
+	for _, e := range entries {
+		status := CacheStatus{Entry: e, Expected: s.StoredChecksum(e)}
+		status.Actual, status.Err = s.computeSHA256(e.Path)
+		result = append(result, status)
+	}
+	return result, nil
+}
+
+// Prune removes all but the newest keep versions and returns the removed
+// entries. Versions are ordered semantically; files whose names are not
+// versions (imported files) are ordered by modification time after them.
+// With dryRun nothing is removed.
+func (s *FirmwareStore) Prune(keep int, dryRun bool) ([]FirmwareEntry, error) {
+	entries, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	SortEntriesNewestFirst(entries)
+	if keep >= len(entries) {
+		return nil, nil
+	}
+
+	removed := entries[keep:]
+	if dryRun {
+		return removed, nil
+	}
+	for _, e := range removed {
+		if err := s.Remove(e.Version); err != nil {
+			return nil, fmt.Errorf("failed to remove %s: %w", e.Version, err)
+		}
+	}
+	return removed, nil
+}
+
+// SortEntriesNewestFirst sorts entries by descending version, then by
+// modification time for names that are not versions.
+func SortEntriesNewestFirst(entries []FirmwareEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		if c := compareForSort(entries[i].Version, entries[j].Version); c != 0 {
+			return c > 0
+		}
+		return entries[i].Downloaded.After(entries[j].Downloaded)
+	})
+}
+
+// SavedManifest is a manifest fetched earlier, kept for offline use.
+type SavedManifest struct {
+	Fetched  time.Time         `json:"fetched"`
+	URL      string            `json:"url"`
+	Filter   ManifestFilter    `json:"filter"`
+	Versions []FirmwareVersion `json:"versions"`
+}
+
+// manifestPath returns the saved manifest file for a channel. The release
+// manifest keeps the name it had before channels were supported.
+func (s *FirmwareStore) manifestPath(channel string) string {
+	if channel == "" || channel == ChannelRelease {
+		return filepath.Join(s.baseDir, "manifest.json")
+	}
+	return filepath.Join(s.baseDir, "manifest-"+channel+".json")
+}
+
+// SaveManifest stores the result of a manifest query, replacing the saved
+// manifest for the same channel.
+func (s *FirmwareStore) SaveManifest(m *SavedManifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := s.manifestPath(m.Filter.Channel)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// LoadManifest returns the saved manifest for a channel, or nil if none has
+// been saved.
+func (s *FirmwareStore) LoadManifest(channel string) (*SavedManifest, error) {
+	data, err := os.ReadFile(s.manifestPath(channel))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var m SavedManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid saved manifest: %w", err)
+	}
+	return &m, nil
+}
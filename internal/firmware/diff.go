@@ -0,0 +1,318 @@
+package firmware
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// MinStringLength is the shortest printable run reported by Strings.
+const MinStringLength = 6
+
+// SegmentInfo describes one segment of an image.
+type SegmentInfo struct {
+	LoadAddr uint32 `json:"load_addr"`
+	Size     uint32 `json:"size"`
+	Region   string `json:"region"`
+}
+
+// SegmentChange pairs the segments at one index of two images. A or B is
+// nil if only one image has that many segments.
+type SegmentChange struct {
+	Index    int          `json:"index"`
+	A        *SegmentInfo `json:"a,omitempty"`
+	B        *SegmentInfo `json:"b,omitempty"`
+	Changed  bool         `json:"changed"`   // Address, size or region differ
+	SameData bool         `json:"same_data"` // Contents are identical
+}
+
+// FieldChange is a header or app descriptor field that differs.
+type FieldChange struct {
+	Field string `json:"field"`
+	A     string `json:"a"`
+	B     string `json:"b"`
+}
+
+// PassdbChange is a part number whose password database entries differ.
+// Old is empty for added part numbers and New for removed ones.
+type PassdbChange struct {
+	PartNumber string          `json:"part_number"`
+	Old        []PasswordEntry `json:"old,omitempty"`
+	New        []PasswordEntry `json:"new,omitempty"`
+}
+
+// PassdbDiff compares two password databases.
+type PassdbDiff struct {
+	EntrySizeA int            `json:"entry_size_a"`
+	EntrySizeB int            `json:"entry_size_b"`
+	Added      []PassdbChange `json:"added,omitempty"`
+	Removed    []PassdbChange `json:"removed,omitempty"`
+	Changed    []PassdbChange `json:"changed,omitempty"`
+	Default    *PassdbChange  `json:"default,omitempty"` // Fallback entry, if it changed
+}
+
+// ImageDiff lists what changed between two firmware images.
+type ImageDiff struct {
+	Segments       []SegmentChange `json:"segments"`
+	Fields         []FieldChange   `json:"fields,omitempty"`
+	StringsAdded   []string        `json:"strings_added,omitempty"`
+	StringsRemoved []string        `json:"strings_removed,omitempty"`
+	RoutesAdded    []string        `json:"routes_added,omitempty"`
+	RoutesRemoved  []string        `json:"routes_removed,omitempty"`
+	Passdb         *PassdbDiff     `json:"passdb,omitempty"`
+	PassdbError    string          `json:"passdb_error,omitempty"`
+	GPIO           *GPIODiff       `json:"gpio,omitempty"`
+	GPIOError      string          `json:"gpio_error,omitempty"`
+}
+
+// DiffImages compares two firmware images.
+func DiffImages(a, b *ESP32Image) *ImageDiff {
+	d := &ImageDiff{
+		Segments: diffSegments(a, b),
+		Fields:   diffFields(imageFields(a), imageFields(b)),
+	}
+
+	d.StringsAdded, d.StringsRemoved = diffSets(a.Strings(MinStringLength), b.Strings(MinStringLength))
+	// Routes such as "/bt" are shorter than MinStringLength
+	d.RoutesAdded, d.RoutesRemoved = diffSets(APIRoutes(a.Strings(3)), APIRoutes(b.Strings(3)))
+
+	dbA, errA := ExtractPasswordDatabase(a)
+	dbB, errB := ExtractPasswordDatabase(b)
+	switch {
+	case errA != nil:
+		d.PassdbError = fmt.Sprintf("first image: %v", errA)
+	case errB != nil:
+		d.PassdbError = fmt.Sprintf("second image: %v", errB)
+	default:
+		d.Passdb = DiffPasswordDatabases(dbA, dbB)
+	}
+
+	gpioA, errA := ExtractGPIOTable(a)
+	gpioB, errB := ExtractGPIOTable(b)
+	switch {
+	case errA != nil:
+		d.GPIOError = fmt.Sprintf("first image: %v", errA)
+	case errB != nil:
+		d.GPIOError = fmt.Sprintf("second image: %v", errB)
+	default:
+		d.GPIO = DiffGPIOTables(gpioA, gpioB)
+	}
+	return d
+}
+
+func diffSegments(a, b *ESP32Image) []SegmentChange {
+	var changes []SegmentChange
+	for i := range max(len(a.Segments), len(b.Segments)) {
+		c := SegmentChange{Index: i}
+		var segA, segB *ESP32Segment
+		if i < len(a.Segments) {
+			segA = &a.Segments[i]
+			c.A = &SegmentInfo{segA.LoadAddr, segA.DataLen, segA.Region()}
+		}
+		if i < len(b.Segments) {
+			segB = &b.Segments[i]
+			c.B = &SegmentInfo{segB.LoadAddr, segB.DataLen, segB.Region()}
+		}
+		c.Changed = c.A == nil || c.B == nil || *c.A != *c.B
+		c.SameData = segA != nil && segB != nil && bytes.Equal(segA.Data, segB.Data)
+		changes = append(changes, c)
+	}
+	return changes
+}
+
+// imageFields returns the header and app descriptor fields worth comparing,
+// in display order.
+func imageFields(img *ESP32Image) [][2]string {
+	fields := [][2]string{
+		{"chip", img.ChipName()},
+		{"entry_addr", fmt.Sprintf("0x%08x", img.Header.EntryAddr)},
+		{"hash_appended", fmt.Sprint(img.Header.HashAppended == 1)},
+	}
+	desc, err := img.AppDescriptor()
+	if err != nil {
+		return append(fields, [2]string{"app_descriptor", err.Error()})
+	}
+	return append(fields,
+		[2]string{"project_name", desc.ProjectName},
+		[2]string{"version", desc.Version},
+		[2]string{"idf_version", desc.IDFVersion},
+		[2]string{"build_date", desc.Date},
+		[2]string{"build_time", desc.Time},
+		[2]string{"elf_sha256", desc.ELFSHA256Hex()},
+		[2]string{"secure_version", fmt.Sprint(desc.SecureVersion)},
+	)
+}
+
+func diffFields(a, b [][2]string) []FieldChange {
+	values := make(map[string]string)
+	for _, f := range b {
+		values[f[0]] = f[1]
+	}
+	var changes []FieldChange
+	seen := make(map[string]bool)
+	for _, f := range a {
+		seen[f[0]] = true
+		if values[f[0]] != f[1] {
+			changes = append(changes, FieldChange{f[0], f[1], values[f[0]]})
+		}
+	}
+	for _, f := range b {
+		if !seen[f[0]] {
+			changes = append(changes, FieldChange{f[0], "", f[1]})
+		}
+	}
+	return changes
+}
+
+// diffSets returns the sorted elements only in b (added) and only in a
+// (removed). Both inputs must be free of duplicates.
+func diffSets(a, b []string) (added, removed []string) {
+	inA := make(map[string]bool, len(a))
+	for _, s := range a {
+		inA[s] = true
+	}
+	inB := make(map[string]bool, len(b))
+	for _, s := range b {
+		inB[s] = true
+		if !inA[s] {
+			added = append(added, s)
+		}
+	}
+	for _, s := range a {
+		if !inB[s] {
+			removed = append(removed, s)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
+
+// Strings returns the unique printable ASCII runs of at least minLen bytes
+// in the DROM segment, like strings(1).
+func (img *ESP32Image) Strings(minLen int) []string {
+	drom := img.GetDROMSegment()
+	if drom == nil {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	var result []string
+	start := -1
+	for i := 0; i <= len(drom.Data); i++ {
+		if i < len(drom.Data) && (drom.Data[i] >= 0x20 && drom.Data[i] < 0x7f || drom.Data[i] == '\t') {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 && i-start >= minLen {
+			s := string(drom.Data[start:i])
+			if !seen[s] {
+				seen[s] = true
+				result = append(result, s)
+			}
+		}
+		start = -1
+	}
+	return result
+}
+
+// routePattern matches URL paths whose first component is one the device
+// API uses (see API.md).
+var routePattern = regexp.MustCompile(`^/(api|fw|xsfp|ddm|sif|stats|settings|bt|reboot|name|version)(/[A-Za-z0-9_.%{}-]*)*/?$`)
+
+// APIRoutes returns the strings that look like device API routes.
+func APIRoutes(strs []string) []string {
+	var routes []string
+	for _, s := range strs {
+		if routePattern.MatchString(s) {
+			routes = append(routes, s)
+		}
+	}
+	sort.Strings(routes)
+	return routes
+}
+
+// DiffPasswordDatabases compares databases by part number. A part number
+// changes if its entries (password, flags, locked and read-only bits,
+// cable length) differ in content or order. Cable length is only compared
+// if both databases have it.
+func DiffPasswordDatabases(a, b *PasswordDatabase) *PassdbDiff {
+	d := &PassdbDiff{EntrySizeA: a.EntrySize, EntrySizeB: b.EntrySize}
+	equal := func(x, y PasswordEntry) bool {
+		if a.EntrySize != b.EntrySize {
+			x.CableLength, y.CableLength = 0, 0
+		}
+		return x == y
+	}
+	byPNA, orderA := groupByPartNumber(a.Entries)
+	byPNB, orderB := groupByPartNumber(b.Entries)
+
+	for _, pn := range orderA {
+		newEntries, ok := byPNB[pn]
+		switch {
+		case !ok:
+			d.Removed = append(d.Removed, PassdbChange{PartNumber: pn, Old: byPNA[pn]})
+		case !slices.EqualFunc(byPNA[pn], newEntries, equal):
+			d.Changed = append(d.Changed, PassdbChange{PartNumber: pn, Old: byPNA[pn], New: newEntries})
+		}
+	}
+	for _, pn := range orderB {
+		if _, ok := byPNA[pn]; !ok {
+			d.Added = append(d.Added, PassdbChange{PartNumber: pn, New: byPNB[pn]})
+		}
+	}
+
+	if (a.DefaultEntry == nil) != (b.DefaultEntry == nil) ||
+		a.DefaultEntry != nil && !equal(*a.DefaultEntry, *b.DefaultEntry) {
+		d.Default = &PassdbChange{}
+		if a.DefaultEntry != nil {
+			d.Default.Old = []PasswordEntry{*a.DefaultEntry}
+		}
+		if b.DefaultEntry != nil {
+			d.Default.New = []PasswordEntry{*b.DefaultEntry}
+		}
+	}
+	return d
+}
+
+// Empty reports whether the databases are the same.
+func (d *PassdbDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0 && d.Default == nil
+}
+
+func groupByPartNumber(entries []PasswordEntry) (map[string][]PasswordEntry, []string) {
+	groups := make(map[string][]PasswordEntry)
+	var order []string
+	for _, e := range entries {
+		if _, ok := groups[e.PartNumber]; !ok {
+			order = append(order, e.PartNumber)
+		}
+		groups[e.PartNumber] = append(groups[e.PartNumber], e)
+	}
+	return groups, order
+}
+
+// String formats an entry as password, ASCII form, flags and lock bits.
+func (e *PasswordEntry) String() string {
+	var parts []string
+	parts = append(parts, e.FormatPassword())
+	if ascii := e.FormatPasswordASCII(); ascii != "" {
+		parts = append(parts, fmt.Sprintf("%q", ascii))
+	}
+	parts = append(parts, "pages="+e.InterpretFlags())
+	if e.Locked {
+		parts = append(parts, "locked")
+	}
+	if e.ReadOnly {
+		parts = append(parts, "read-only")
+	}
+	if e.CableLength != 0 {
+		parts = append(parts, fmt.Sprintf("cable=%d", e.CableLength))
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,233 @@
+package firmware
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// ELFSymbol is a symbol to add to an ELF file written by WriteELF.
+type ELFSymbol struct {
+	Name string
+	Addr uint32
+	Size uint32
+	Func bool // Code rather than data
+}
+
+// elfSectionNames maps memory regions to the ESP-IDF output section names,
+// so disassemblers show what they are used to.
+var elfSectionNames = map[string]string{
+	"DROM":     ".flash.rodata",
+	"IROM":     ".flash.text",
+	"DRAM":     ".dram0.data",
+	"IRAM":     ".iram0.text",
+	"RTC_FAST": ".rtc.text",
+	"RTC_SLOW": ".rtc.data",
+}
+
+// espAppDescSize is sizeof(esp_app_desc_t), including the reserved tail
+// that rawAppDesc leaves out.
+const espAppDescSize = 256
+
+// elfSection is a section of the ELF file being written.
+type elfSection struct {
+	hdr  elf.Section32
+	name string
+	data []byte
+}
+
+// KnownSymbols returns symbols for what the tool can locate in the image:
+// the entry point, the app descriptor, the password database and the GPIO
+// and I/O expander config arrays. Structures that cannot be found are left
+// out.
+func (img *ESP32Image) KnownSymbols() []ELFSymbol {
+	syms := []ELFSymbol{{Name: "call_start_cpu0", Addr: img.Header.EntryAddr, Func: true}}
+	if _, err := img.AppDescriptor(); err == nil {
+		drom := img.GetDROMSegment()
+		syms = append(syms, ELFSymbol{Name: "esp_app_desc", Addr: drom.LoadAddr, Size: espAppDescSize})
+	}
+	if db, err := ExtractPasswordDatabase(img); err == nil {
+		// The table ends with the default entry
+		size := uint32((len(db.Entries) + 1) * db.EntrySize)
+		syms = append(syms, ELFSymbol{Name: "sfp_password_db", Addr: db.Addr, Size: size})
+	}
+	if table, err := ExtractGPIOTable(img); err == nil {
+		size := uint32(len(table.Pins) * GPIOEntrySize)
+		syms = append(syms, ELFSymbol{Name: "gpio_pin_config", Addr: table.Addr, Size: size})
+		if table.Expander != nil {
+			size := uint32(len(table.Expander) * GPIOEntrySize)
+			syms = append(syms, ELFSymbol{Name: "ioe_pin_config", Addr: table.ExpanderAddr, Size: size})
+		}
+	}
+	return syms
+}
+
+// WriteELF writes the image as an ELF32 Xtensa executable for loading into
+// a disassembler. Each segment becomes a loadable section named after the
+// ESP32-S3 memory region it loads into, and the entry point comes from the
+// image header.
+func (img *ESP32Image) WriteELF(w io.Writer, symbols []ELFSymbol) error {
+	const (
+		ehdrSize = 52
+		phdrSize = 32
+		shdrSize = 40
+		symSize  = 16
+	)
+
+	shstrtab := []byte{0}
+	addName := func(table *[]byte, name string) uint32 {
+		off := uint32(len(*table))
+		*table = append(append(*table, name...), 0)
+		return off
+	}
+
+	// Loadable sections, one per segment
+	var sections []elfSection
+	used := make(map[string]int)
+	for i := range img.Segments {
+		seg := &img.Segments[i]
+		region := seg.Region()
+		name, ok := elfSectionNames[region]
+		if !ok {
+			name = fmt.Sprintf(".segment%d", i)
+		}
+		if n := used[name]; n > 0 {
+			name = fmt.Sprintf("%s.%d", name, n)
+		}
+		used[name]++
+
+		flags := elf.SHF_ALLOC
+		switch region {
+		case "IROM", "IRAM", "RTC_FAST":
+			flags |= elf.SHF_EXECINSTR
+		case "DRAM", "RTC_SLOW":
+			flags |= elf.SHF_WRITE
+		}
+		sections = append(sections, elfSection{
+			name: name,
+			data: seg.Data,
+			hdr: elf.Section32{
+				Type:      uint32(elf.SHT_PROGBITS),
+				Flags:     uint32(flags),
+				Addr:      seg.LoadAddr,
+				Size:      seg.DataLen,
+				Addralign: 4,
+			},
+		})
+	}
+	loadable := len(sections)
+
+	// Symbols, placed in the section that contains them
+	strtab := []byte{0}
+	var symtab bytes.Buffer
+	binary.Write(&symtab, binary.LittleEndian, elf.Sym32{})
+	sorted := append([]ELFSymbol(nil), symbols...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Addr < sorted[j].Addr })
+	for _, s := range sorted {
+		shndx := uint16(elf.SHN_ABS)
+		for i := range loadable {
+			h := sections[i].hdr
+			if s.Addr >= h.Addr && s.Addr < h.Addr+h.Size {
+				shndx = uint16(i + 1)
+				break
+			}
+		}
+		typ := elf.STT_OBJECT
+		if s.Func {
+			typ = elf.STT_FUNC
+		}
+		binary.Write(&symtab, binary.LittleEndian, elf.Sym32{
+			Name:  addName(&strtab, s.Name),
+			Value: s.Addr,
+			Size:  s.Size,
+			Info:  elf.ST_INFO(elf.STB_GLOBAL, typ),
+			Shndx: shndx,
+		})
+	}
+	symtabIndex := uint32(len(sections) + 1)
+	sections = append(sections,
+		elfSection{name: ".symtab", data: symtab.Bytes(), hdr: elf.Section32{
+			Type:      uint32(elf.SHT_SYMTAB),
+			Link:      symtabIndex + 1, // .strtab
+			Info:      1,               // All symbols are global
+			Addralign: 4,
+			Entsize:   symSize,
+		}},
+		elfSection{name: ".strtab", data: strtab, hdr: elf.Section32{Type: uint32(elf.SHT_STRTAB), Addralign: 1}},
+	)
+	sections = append(sections, elfSection{name: ".shstrtab", hdr: elf.Section32{Type: uint32(elf.SHT_STRTAB), Addralign: 1}})
+	for i := range sections {
+		sections[i].hdr.Name = addName(&shstrtab, sections[i].name)
+	}
+	sections[len(sections)-1].data = shstrtab
+
+	// Lay out the file: headers, program headers, section data, section headers
+	align := func(off uint32) uint32 { return (off + 3) &^ 3 }
+	off := uint32(ehdrSize + phdrSize*loadable)
+	for i := range sections {
+		if sections[i].hdr.Addralign > 1 {
+			off = align(off)
+		}
+		sections[i].hdr.Off = off
+		sections[i].hdr.Size = uint32(len(sections[i].data))
+		off += uint32(len(sections[i].data))
+	}
+	shoff := align(off)
+
+	var buf bytes.Buffer
+	ehdr := elf.Header32{
+		Type:      uint16(elf.ET_EXEC),
+		Machine:   uint16(elf.EM_XTENSA),
+		Version:   uint32(elf.EV_CURRENT),
+		Entry:     img.Header.EntryAddr,
+		Phoff:     ehdrSize,
+		Shoff:     shoff,
+		Ehsize:    ehdrSize,
+		Phentsize: phdrSize,
+		Phnum:     uint16(loadable),
+		Shentsize: shdrSize,
+		Shnum:     uint16(len(sections) + 1),
+		Shstrndx:  uint16(len(sections)),
+	}
+	copy(ehdr.Ident[:], elf.ELFMAG)
+	ehdr.Ident[elf.EI_CLASS] = byte(elf.ELFCLASS32)
+	ehdr.Ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	ehdr.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+	binary.Write(&buf, binary.LittleEndian, ehdr)
+
+	for _, s := range sections[:loadable] {
+		flags := elf.PF_R
+		if s.hdr.Flags&uint32(elf.SHF_EXECINSTR) != 0 {
+			flags |= elf.PF_X
+		}
+		if s.hdr.Flags&uint32(elf.SHF_WRITE) != 0 {
+			flags |= elf.PF_W
+		}
+		binary.Write(&buf, binary.LittleEndian, elf.Prog32{
+			Type:   uint32(elf.PT_LOAD),
+			Off:    s.hdr.Off,
+			Vaddr:  s.hdr.Addr,
+			Paddr:  s.hdr.Addr,
+			Filesz: s.hdr.Size,
+			Memsz:  s.hdr.Size,
+			Flags:  uint32(flags),
+			Align:  4,
+		})
+	}
+
+	for _, s := range sections {
+		buf.Write(make([]byte, int(s.hdr.Off)-buf.Len()))
+		buf.Write(s.data)
+	}
+	buf.Write(make([]byte, int(shoff)-buf.Len()))
+	binary.Write(&buf, binary.LittleEndian, elf.Section32{})
+	for _, s := range sections {
+		binary.Write(&buf, binary.LittleEndian, s.hdr)
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
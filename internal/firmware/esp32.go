@@ -2,6 +2,7 @@ package firmware
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/binary"
 	"fmt"
 	"io"
@@ -13,8 +14,12 @@ const (
 	ESP32ImageMagic     = 0xE9
 	ESP32HeaderSize     = 24 // Main image header size
 	ESP32SegmentHdrSize = 8  // Segment header size (load_addr + data_len)
+	ESP32ChecksumSeed   = 0xEF
 )
 
+// ESP32-S3 chip ID in the image header
+const ESP32S3ChipID = 9
+
 // ESP32ImageHeader represents the main header of an ESP32 app image.
 type ESP32ImageHeader struct {
 	Magic        uint8
@@ -44,6 +49,14 @@ type ESP32Segment struct {
 type ESP32Image struct {
 	Header   ESP32ImageHeader
 	Segments []ESP32Segment
+
+	Checksum     uint8  // Checksum byte stored after the segments
+	CalcChecksum uint8  // Checksum computed over the segment data
+	Digest       []byte // SHA-256 appended to the image, if Header.HashAppended
+	CalcDigest   []byte // SHA-256 computed over the image up to the digest
+	Size         int64  // Image length including checksum and digest
+
+	trailerErr error // Set if the image ends before its checksum or digest
 }
 
 // ParseESP32Image parses an ESP32 app image from a file.
@@ -61,6 +74,11 @@ func ParseESP32Image(path string) (*ESP32Image, error) {
 func ParseESP32ImageReader(r io.ReadSeeker) (*ESP32Image, error) {
 	img := &ESP32Image{}
 
+	start, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get position: %w", err)
+	}
+
 	// Read main header
 	if err := binary.Read(r, binary.LittleEndian, &img.Header); err != nil {
 		return nil, fmt.Errorf("failed to read image header: %w", err)
@@ -99,9 +117,91 @@ func ParseESP32ImageReader(r io.ReadSeeker) (*ESP32Image, error) {
 		img.Segments = append(img.Segments, seg)
 	}
 
+	img.trailerErr = img.readTrailer(r, start)
 	return img, nil
 }
 
+// readTrailer reads the checksum byte, which is padded so it ends on a
+// 16-byte boundary, and the appended SHA-256 if the header announces one.
+func (img *ESP32Image) readTrailer(r io.ReadSeeker, start int64) error {
+	img.CalcChecksum = ESP32ChecksumSeed
+	for _, seg := range img.Segments {
+		for _, b := range seg.Data {
+			img.CalcChecksum ^= b
+		}
+	}
+
+	pos, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	pad := 15 - (pos-start)%16
+	if _, err := r.Seek(pad, io.SeekCurrent); err != nil {
+		return err
+	}
+	var sum [1]byte
+	if _, err := io.ReadFull(r, sum[:]); err != nil {
+		return fmt.Errorf("image ends before its checksum")
+	}
+	img.Checksum = sum[0]
+	img.Size = pos - start + pad + 1
+
+	if img.Header.HashAppended != 1 {
+		return nil
+	}
+	if _, err := r.Seek(start, io.SeekStart); err != nil {
+		return err
+	}
+	h := sha256.New()
+	if _, err := io.CopyN(h, r, img.Size); err != nil {
+		return err
+	}
+	img.CalcDigest = h.Sum(nil)
+	img.Digest = make([]byte, sha256.Size)
+	if _, err := io.ReadFull(r, img.Digest); err != nil {
+		img.Digest = nil
+		return fmt.Errorf("image ends before its SHA-256 digest")
+	}
+	img.Size += sha256.Size
+	return nil
+}
+
+// Verify checks the image checksum and, if present, the appended SHA-256.
+func (img *ESP32Image) Verify() error {
+	if img.trailerErr != nil {
+		return img.trailerErr
+	}
+	if img.Checksum != img.CalcChecksum {
+		return fmt.Errorf("checksum mismatch: image has 0x%02x, computed 0x%02x", img.Checksum, img.CalcChecksum)
+	}
+	if img.Digest != nil && !bytes.Equal(img.Digest, img.CalcDigest) {
+		return fmt.Errorf("SHA-256 mismatch: image has %x, computed %x", img.Digest, img.CalcDigest)
+	}
+	return nil
+}
+
+// ChipName returns the name of the chip an image is built for.
+func (img *ESP32Image) ChipName() string {
+	switch img.Header.ChipID {
+	case 0:
+		return "ESP32"
+	case 2:
+		return "ESP32-S2"
+	case 5:
+		return "ESP32-C3"
+	case ESP32S3ChipID:
+		return "ESP32-S3"
+	case 12:
+		return "ESP32-C2"
+	case 13:
+		return "ESP32-C6"
+	case 16:
+		return "ESP32-H2"
+	default:
+		return fmt.Sprintf("unknown (%d)", img.Header.ChipID)
+	}
+}
+
 // GetDROMSegment returns the DROM segment (typically segment 0).
 // DROM segments have load addresses starting with 0x3c (ESP32-S3).
 func (img *ESP32Image) GetDROMSegment() *ESP32Segment {
@@ -125,6 +225,27 @@ func (img *ESP32Image) GetIROMSegment() *ESP32Segment {
 	return nil
 }
 
+// Region names the ESP32-S3 memory region a segment loads into.
+func (seg *ESP32Segment) Region() string {
+	addr := seg.LoadAddr
+	switch {
+	case addr >= 0x3C000000 && addr < 0x3E000000:
+		return "DROM"
+	case addr >= 0x42000000 && addr < 0x44000000:
+		return "IROM"
+	case addr >= 0x3FC88000 && addr < 0x3FD00000:
+		return "DRAM"
+	case addr >= 0x40370000 && addr < 0x403E0000:
+		return "IRAM"
+	case addr >= 0x600FE000 && addr < 0x60100000:
+		return "RTC_FAST"
+	case addr >= 0x50000000 && addr < 0x50002000:
+		return "RTC_SLOW"
+	default:
+		return "unknown"
+	}
+}
+
 // FileOffsetToVAddr converts a file offset within a segment to a virtual address.
 func (seg *ESP32Segment) FileOffsetToVAddr(fileOffset int64) uint32 {
 	relOffset := fileOffset - seg.FileOffset
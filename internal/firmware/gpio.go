@@ -0,0 +1,311 @@
+package firmware
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// GPIOEntrySize is the size of a gpio_pin_config entry (see GPIO.md).
+const GPIOEntrySize = 0x1c
+
+// GPIOEntryMarker is the signal name of an entry in the GPIO config array.
+// Like FirstEntryMarker for the password database, it anchors the search:
+// the table is found through a pointer to this string.
+const GPIOEntryMarker = "chg_pgood"
+
+// ExpanderEntryMarker anchors the CA9554 I/O expander config array the same
+// way. qsfp_pwr_en is only ever on the expander.
+const ExpanderEntryMarker = "qsfp_pwr_en"
+
+// Highest pin numbers: GPIO48 on the ESP32-S3, P7 on the CA9554
+const (
+	maxGPIONum     = 48
+	maxExpanderPin = 7
+)
+
+// GPIOPin is an entry of the GPIO config array:
+//
+//	struct gpio_pin_config {
+//	    char *name;           // +0x00
+//	    uint32_t reserved[4]; // +0x04
+//	    void *vtable;         // +0x14
+//	    uint32_t gpio_num;    // +0x18
+//	};
+//
+// The expander array is assumed to use the same layout, with gpio_num
+// holding the expander pin. Only Name, Pin, Handler, Class and Reserved are
+// decoded from the firmware.
+type GPIOPin struct {
+	Name       string         `json:"name"`
+	Pin        uint32         `json:"pin"`
+	Handler    uint32         `json:"handler"` // vtable pointer
+	Class      int            `json:"class"`   // Pins with the same handler share a class, numbered by first use
+	Reserved   [4]uint32      `json:"reserved"`
+	Annotation GPIOAnnotation `json:"annotation"`
+}
+
+// GPIOAnnotation is what GPIO.md says about a signal. It is looked up by
+// name, not decoded from the firmware, except that a signal GPIO.md does
+// not know takes the direction of the known signals in its class.
+type GPIOAnnotation struct {
+	Direction string `json:"direction,omitempty"` // "input", "output" or empty if unknown
+	Function  string `json:"function,omitempty"`
+	Inferred  bool   `json:"inferred,omitempty"` // Direction taken from another pin of the same class
+}
+
+// GPIOTable holds the GPIO config array of a firmware image and, if found,
+// the CA9554 expander config array.
+type GPIOTable struct {
+	Addr          uint32    `json:"addr"`
+	Pins          []GPIOPin `json:"pins"`
+	ExpanderAddr  uint32    `json:"expander_addr,omitempty"`
+	Expander      []GPIOPin `json:"expander,omitempty"`
+	ExpanderError string    `json:"expander_error,omitempty"` // Why the expander array is missing
+}
+
+// knownGPIOSignals are the signals identified in GPIO.md, including those on
+// the CA9554 I/O expander in case a version moves one to a direct GPIO.
+var knownGPIOSignals = map[string]GPIOAnnotation{
+	"button":      {Direction: "input", Function: "User button"},
+	"chg_stat":    {Direction: "input", Function: "Charger status"},
+	"chg_pgood":   {Direction: "input", Function: "Charger power good"},
+	"gauge_int":   {Direction: "input", Function: "Battery gauge interrupt"},
+	"tp_int":      {Direction: "input", Function: "Touch panel interrupt"},
+	"display_dc":  {Direction: "output", Function: "Display D/C"},
+	"qsfp_abs":    {Direction: "input", Function: "QSFP module absent"},
+	"backlight":   {Direction: "output", Function: "Display backlight"},
+	"sfp_pwr_en":  {Direction: "output", Function: "SFP power enable"},
+	"ioe_int":     {Direction: "input", Function: "I/O expander interrupt"},
+	"display_rst": {Direction: "output", Function: "Display reset"},
+	"tp_rst":      {Direction: "output", Function: "Touch panel reset"},
+	"qsfp_pwr_en": {Direction: "output", Function: "QSFP power enable"},
+	"sw_pwr_off":  {Direction: "output", Function: "Software power off"},
+	"chg_disable": {Direction: "output", Function: "Charger disable"},
+	"xsfp_laser":  {Direction: "output", Function: "SFP/QSFP laser enable"},
+	"sfp_abs":     {Direction: "input", Function: "SFP module absent"},
+}
+
+var signalNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{1,31}$`)
+
+// ExtractGPIOTable extracts the GPIO config array from an ESP32 firmware
+// image, and the CA9554 expander config array if it can be found. The
+// arrays live in initialized DRAM; each is located through a pointer to its
+// marker signal name and extended in both directions while the entries stay
+// valid.
+func ExtractGPIOTable(img *ESP32Image) (*GPIOTable, error) {
+	drom := img.GetDROMSegment()
+	if drom == nil {
+		return nil, fmt.Errorf("DROM segment not found")
+	}
+
+	addr, pins, err := findPinArray(img, drom, GPIOEntryMarker, maxGPIONum)
+	if err != nil {
+		return nil, err
+	}
+	table := &GPIOTable{Addr: addr, Pins: pins}
+
+	table.ExpanderAddr, table.Expander, err = findPinArray(img, drom, ExpanderEntryMarker, maxExpanderPin)
+	end := table.Addr + uint32(len(table.Pins)*GPIOEntrySize)
+	switch {
+	case err != nil:
+		table.ExpanderError = err.Error()
+	case table.ExpanderAddr >= table.Addr && table.ExpanderAddr < end:
+		// Not a separate array after all; keep the entries where they are
+		table.ExpanderAddr, table.Expander = 0, nil
+		table.ExpanderError = fmt.Sprintf("%q is in the GPIO config array, not a separate expander array", ExpanderEntryMarker)
+	}
+
+	annotateGPIOPins(table)
+	return table, nil
+}
+
+// findPinArray finds the array containing an entry named marker, accepting
+// pin numbers up to maxPin.
+func findPinArray(img *ESP32Image, drom *ESP32Segment, marker string, maxPin uint32) (uint32, []GPIOPin, error) {
+	// Step 1: Find the marker string
+	markerOffsets := drom.FindBytes(append([]byte(marker), 0))
+	if len(markerOffsets) == 0 {
+		return 0, nil, fmt.Errorf("marker string %q not found in DROM", marker)
+	}
+
+	// Step 2: Find a pointer to it in a data segment that starts a valid entry
+	for _, markerOffset := range markerOffsets {
+		markerVAddr := drom.LoadAddr + uint32(markerOffset)
+		ptrBytes := []byte{
+			byte(markerVAddr),
+			byte(markerVAddr >> 8),
+			byte(markerVAddr >> 16),
+			byte(markerVAddr >> 24),
+		}
+		for i := range img.Segments {
+			seg := &img.Segments[i]
+			if seg.Region() != "DRAM" {
+				continue
+			}
+			for _, off := range seg.FindBytes(ptrBytes) {
+				if off%4 != 0 || !validGPIOEntry(drom, seg, off, maxPin) {
+					continue
+				}
+				addr, pins := parsePinArray(drom, seg, off, maxPin)
+				return addr, pins, nil
+			}
+		}
+	}
+	return 0, nil, fmt.Errorf("no pin config entry points to %q", marker)
+}
+
+// parsePinArray walks back from a known entry to the start of the array,
+// then parses entries until one is invalid.
+func parsePinArray(drom, seg *ESP32Segment, offset int64, maxPin uint32) (uint32, []GPIOPin) {
+	for validGPIOEntry(drom, seg, offset-GPIOEntrySize, maxPin) {
+		offset -= GPIOEntrySize
+	}
+
+	addr := seg.LoadAddr + uint32(offset)
+	var pins []GPIOPin
+	for ; validGPIOEntry(drom, seg, offset, maxPin); offset += GPIOEntrySize {
+		pin := GPIOPin{}
+		namePtr, _ := seg.ReadUint32At(offset)
+		strOffset, _ := drom.VAddrToDataOffset(namePtr)
+		pin.Name = drom.ReadStringAt(strOffset)
+		for i := range pin.Reserved {
+			pin.Reserved[i], _ = seg.ReadUint32At(offset + 4 + int64(i)*4)
+		}
+		pin.Handler, _ = seg.ReadUint32At(offset + 0x14)
+		pin.Pin, _ = seg.ReadUint32At(offset + 0x18)
+		pins = append(pins, pin)
+	}
+	return addr, pins
+}
+
+// annotateGPIOPins numbers the handler classes across both arrays and adds
+// the GPIO.md annotations.
+func annotateGPIOPins(table *GPIOTable) {
+	classes := make(map[uint32]int)
+	var all []*GPIOPin
+	for _, pins := range [][]GPIOPin{table.Pins, table.Expander} {
+		for i := range pins {
+			p := &pins[i]
+			if _, ok := classes[p.Handler]; !ok {
+				classes[p.Handler] = len(classes) + 1
+			}
+			p.Class = classes[p.Handler]
+			p.Annotation = knownGPIOSignals[p.Name]
+			all = append(all, p)
+		}
+	}
+	inferGPIODirections(all)
+}
+
+// inferGPIODirections gives pins of unknown signals the direction of known
+// pins in their class, if those agree. This is the one part of the
+// annotation that comes from the firmware.
+func inferGPIODirections(pins []*GPIOPin) {
+	byClass := make(map[int]string)
+	for _, p := range pins {
+		if p.Annotation.Direction == "" {
+			continue
+		}
+		if d, ok := byClass[p.Class]; ok && d != p.Annotation.Direction {
+			byClass[p.Class] = "ambiguous"
+		} else {
+			byClass[p.Class] = p.Annotation.Direction
+		}
+	}
+	for _, p := range pins {
+		d := byClass[p.Class]
+		if p.Annotation.Direction == "" && d != "" && d != "ambiguous" {
+			p.Annotation.Direction = d
+			p.Annotation.Inferred = true
+		}
+	}
+}
+
+// validGPIOEntry checks for a signal name pointer into DROM and a pin
+// number up to maxPin.
+func validGPIOEntry(drom, seg *ESP32Segment, offset int64, maxPin uint32) bool {
+	namePtr, ok := seg.ReadUint32At(offset)
+	if !ok {
+		return false
+	}
+	gpioNum, ok := seg.ReadUint32At(offset + 0x18)
+	if !ok || gpioNum > maxPin {
+		return false
+	}
+	strOffset, ok := drom.VAddrToDataOffset(namePtr)
+	if !ok {
+		return false
+	}
+	return signalNamePattern.MatchString(drom.ReadStringAt(strOffset))
+}
+
+// GPIOChange is a signal whose pin or annotated direction differs between
+// tables. Old is nil for added signals and New for removed ones. Expander
+// is set for signals of the CA9554 array.
+type GPIOChange struct {
+	Name     string   `json:"name"`
+	Expander bool     `json:"expander,omitempty"`
+	Old      *GPIOPin `json:"old,omitempty"`
+	New      *GPIOPin `json:"new,omitempty"`
+}
+
+// GPIODiff compares two GPIO config tables.
+type GPIODiff struct {
+	Added   []GPIOChange `json:"added,omitempty"`
+	Removed []GPIOChange `json:"removed,omitempty"`
+	Changed []GPIOChange `json:"changed,omitempty"`
+}
+
+// Empty reports whether the tables assign the same pins.
+func (d *GPIODiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// DiffGPIOTables compares tables by signal name, the GPIO array and the
+// expander array separately. Names used more than once (sfp_pwr_en) are
+// matched by occurrence. Handler addresses and class numbers move between
+// builds and are not compared, but an inferred direction changes if a pin
+// moves to another class.
+func DiffGPIOTables(a, b *GPIOTable) *GPIODiff {
+	d := &GPIODiff{}
+	d.diffPins(a.Pins, b.Pins, false)
+	d.diffPins(a.Expander, b.Expander, true)
+	return d
+}
+
+func (d *GPIODiff) diffPins(a, b []GPIOPin, expander bool) {
+	pinsA, orderA := gpioByName(a)
+	pinsB, orderB := gpioByName(b)
+	for _, key := range orderA {
+		old := pinsA[key]
+		newPin, ok := pinsB[key]
+		switch {
+		case !ok:
+			d.Removed = append(d.Removed, GPIOChange{Name: key, Expander: expander, Old: old})
+		case old.Pin != newPin.Pin || old.Annotation.Direction != newPin.Annotation.Direction:
+			d.Changed = append(d.Changed, GPIOChange{Name: key, Expander: expander, Old: old, New: newPin})
+		}
+	}
+	for _, key := range orderB {
+		if _, ok := pinsA[key]; !ok {
+			d.Added = append(d.Added, GPIOChange{Name: key, Expander: expander, New: pinsB[key]})
+		}
+	}
+}
+
+// gpioByName keys pins by name, adding "#2", "#3"... to repeated names.
+func gpioByName(pins []GPIOPin) (map[string]*GPIOPin, []string) {
+	byName := make(map[string]*GPIOPin)
+	count := make(map[string]int)
+	var order []string
+	for i := range pins {
+		key := pins[i].Name
+		count[key]++
+		if n := count[key]; n > 1 {
+			key = fmt.Sprintf("%s#%d", key, n)
+		}
+		byName[key] = &pins[i]
+		order = append(order, key)
+	}
+	return byName, order
+}
@@ -0,0 +1,151 @@
+package firmware
+
+import "fmt"
+
+// DefaultPartNumber names the fallback entry in a PassdbHistory.
+const DefaultPartNumber = "(default)"
+
+// PassdbSummary describes the password database of one firmware version.
+type PassdbSummary struct {
+	Version         string `json:"version"`
+	Entries         int    `json:"entries"`
+	EntrySize       int    `json:"entry_size"`
+	UniquePasswords int    `json:"unique_passwords"`
+	Error           string `json:"error,omitempty"` // Set if extraction failed
+}
+
+// PassdbEvent is a change to the entries of a part number in a version.
+type PassdbEvent struct {
+	Version string          `json:"version"`
+	Change  string          `json:"change"` // "added", "removed" or "changed"
+	Details []string        `json:"details,omitempty"`
+	Entries []PasswordEntry `json:"entries,omitempty"` // Entries from this version on, empty if removed
+}
+
+// PartNumberHistory is the history of one part number across versions.
+type PartNumberHistory struct {
+	PartNumber string          `json:"part_number"`
+	FirstSeen  string          `json:"first_seen"`
+	LastSeen   string          `json:"last_seen"`
+	Current    bool            `json:"current"` // Present in the newest version
+	Entries    []PasswordEntry `json:"entries"` // As of LastSeen
+	Events     []PassdbEvent   `json:"events"`
+
+	HasCableLength bool `json:"has_cable_length"` // LastSeen stores cable length (20-byte entries)
+}
+
+// PassdbHistory merges the password databases of several versions.
+type PassdbHistory struct {
+	Versions    []PassdbSummary     `json:"versions"`
+	PartNumbers []PartNumberHistory `json:"part_numbers"`
+}
+
+// BuildPassdbHistory merges password databases, given oldest version first.
+// A nil database (failed extraction) is listed in Versions with errs but
+// otherwise skipped. The default entry is tracked as DefaultPartNumber.
+func BuildPassdbHistory(versions []string, dbs []*PasswordDatabase, errs []error) *PassdbHistory {
+	h := &PassdbHistory{}
+	byPN := make(map[string]*PartNumberHistory)
+	var order []string
+	var prev *PasswordDatabase
+	var newest string
+
+	for i, db := range dbs {
+		summary := PassdbSummary{Version: versions[i]}
+		if db == nil {
+			if errs[i] != nil {
+				summary.Error = errs[i].Error()
+			}
+			h.Versions = append(h.Versions, summary)
+			continue
+		}
+		summary.Entries = len(db.Entries)
+		summary.EntrySize = db.EntrySize
+		summary.UniquePasswords = len(db.UniquePasswords())
+		h.Versions = append(h.Versions, summary)
+		newest = versions[i]
+
+		groups, pns := groupByPartNumber(db.Entries)
+		if db.DefaultEntry != nil {
+			groups[DefaultPartNumber] = []PasswordEntry{*db.DefaultEntry}
+			pns = append(pns, DefaultPartNumber)
+		}
+		var prevGroups map[string][]PasswordEntry
+		if prev != nil {
+			prevGroups, _ = groupByPartNumber(prev.Entries)
+			if prev.DefaultEntry != nil {
+				prevGroups[DefaultPartNumber] = []PasswordEntry{*prev.DefaultEntry}
+			}
+		}
+		// Cable length is only comparable if both versions store it
+		compareCable := prev != nil && prev.EntrySize == db.EntrySize
+
+		for _, pn := range pns {
+			entries := groups[pn]
+			ph, ok := byPN[pn]
+			if !ok {
+				ph = &PartNumberHistory{PartNumber: pn, FirstSeen: versions[i]}
+				byPN[pn] = ph
+				order = append(order, pn)
+			}
+			old, wasPresent := prevGroups[pn]
+			switch {
+			case !wasPresent:
+				ph.Events = append(ph.Events, PassdbEvent{Version: versions[i], Change: "added", Entries: entries})
+			default:
+				if details := entryChanges(old, entries, compareCable); len(details) > 0 {
+					ph.Events = append(ph.Events, PassdbEvent{Version: versions[i], Change: "changed", Details: details, Entries: entries})
+				}
+			}
+			ph.LastSeen = versions[i]
+			ph.Entries = entries
+			ph.HasCableLength = db.EntrySize == 20
+		}
+		for pn := range prevGroups {
+			if _, ok := groups[pn]; !ok {
+				byPN[pn].Events = append(byPN[pn].Events, PassdbEvent{Version: versions[i], Change: "removed"})
+			}
+		}
+		prev = db
+	}
+
+	for _, pn := range order {
+		ph := byPN[pn]
+		ph.Current = ph.LastSeen == newest
+		h.PartNumbers = append(h.PartNumbers, *ph)
+	}
+	return h
+}
+
+// entryChanges describes how a part number's entries changed, field by
+// field.
+func entryChanges(before, after []PasswordEntry, compareCable bool) []string {
+	var details []string
+	if len(before) != len(after) {
+		details = append(details, fmt.Sprintf("entries %d -> %d", len(before), len(after)))
+	}
+	// Entries beyond the shorter list are covered by the count change
+	for i := range min(len(before), len(after)) {
+		a, b := before[i], after[i]
+		prefix := ""
+		if len(before) > 1 || len(after) > 1 {
+			prefix = fmt.Sprintf("#%d ", i+1)
+		}
+		if a.Password != b.Password {
+			details = append(details, fmt.Sprintf("%spassword %s -> %s", prefix, a.FormatPassword(), b.FormatPassword()))
+		}
+		if a.Flags != b.Flags {
+			details = append(details, fmt.Sprintf("%spages %s -> %s", prefix, a.InterpretFlags(), b.InterpretFlags()))
+		}
+		if a.Locked != b.Locked {
+			details = append(details, fmt.Sprintf("%slocked %t -> %t", prefix, a.Locked, b.Locked))
+		}
+		if a.ReadOnly != b.ReadOnly {
+			details = append(details, fmt.Sprintf("%sread-only %t -> %t", prefix, a.ReadOnly, b.ReadOnly))
+		}
+		if compareCable && a.CableLength != b.CableLength {
+			details = append(details, fmt.Sprintf("%scable length %d -> %d", prefix, a.CableLength, b.CableLength))
+		}
+	}
+	return details
+}
@@ -20,16 +20,27 @@ type ManifestClient struct {
 
 // NewManifestClient creates a new manifest API client.
 func NewManifestClient() *ManifestClient {
+	return NewManifestClientAt(ManifestBaseURL)
+}
+
+// NewManifestClientAt creates a manifest client for another server with the
+// same API, such as a local mirror or a test server.
+func NewManifestClientAt(baseURL string) *ManifestClient {
 	return &ManifestClient{
-		baseURL: ManifestBaseURL,
+		baseURL: baseURL,
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
 	}
 }
 
+// BaseURL returns the manifest API URL the client queries.
+func (c *ManifestClient) BaseURL() string {
+	return c.baseURL
+}
+
 // GetAvailable fetches available firmware versions matching the filter.
-// Results are sorted by Created date, newest first.
+// Results are sorted newest version first.
 func (c *ManifestClient) GetAvailable(filter ManifestFilter) ([]FirmwareVersion, error) {
 	u, err := url.Parse(c.baseURL)
 	if err != nil {
@@ -84,15 +95,11 @@ func (c *ManifestClient) GetAvailable(filter ManifestFilter) ([]FirmwareVersion,
 		versions = append(versions, v)
 	}
 
-	// Sort by created date, newest first
-	sort.Slice(versions, func(i, j int) bool {
-		return versions[i].Created.After(versions[j].Created)
-	})
-
+	SortVersionsNewestFirst(versions)
 	return versions, nil
 }
 
-// GetLatest returns the most recently created firmware version.
+// GetLatest returns the highest firmware version.
 func (c *ManifestClient) GetLatest(filter ManifestFilter) (*FirmwareVersion, error) {
 	versions, err := c.GetAvailable(filter)
 	if err != nil {
@@ -111,13 +118,25 @@ func (c *ManifestClient) FindVersion(filter ManifestFilter, version string) (*Fi
 		return nil, err
 	}
 	for _, v := range versions {
-		if v.Version == version {
+		if SameVersion(v.Version, version) {
 			return &v, nil
 		}
 	}
 	return nil, fmt.Errorf("version %s not found", version)
 }
 
+// SortVersionsNewestFirst sorts versions by descending semantic version.
+// Versions that cannot be parsed sort after all valid ones; those and
+// versions that compare equal are ordered by Created date.
+func SortVersionsNewestFirst(versions []FirmwareVersion) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		if c := compareForSort(versions[i].Version, versions[j].Version); c != 0 {
+			return c > 0
+		}
+		return versions[i].Created.After(versions[j].Created)
+	})
+}
+
 // manifestResponse matches the Ubiquiti API response structure.
 type manifestResponse struct {
 	Embedded struct {
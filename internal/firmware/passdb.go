@@ -24,6 +24,7 @@ type PasswordDatabase struct {
 	DefaultEntry *PasswordEntry // Entry with NULL part_number (fallback)
 	EntrySize    int            // 16 or 20 bytes
 	Version      string
+	Addr         uint32 // Virtual address of the first entry
 }
 
 // FirstEntryMarker is the part number of the first entry in the database.
@@ -77,6 +78,7 @@ func ExtractPasswordDatabase(img *ESP32Image) (*PasswordDatabase, error) {
 	// Step 4: Parse all entries
 	db := &PasswordDatabase{
 		EntrySize: entrySize,
+		Addr:      drom.LoadAddr + uint32(dbStartOffset),
 	}
 
 	offset := dbStartOffset
@@ -197,18 +199,15 @@ func parseEntry(seg *ESP32Segment, offset int64, entrySize int) (*PasswordEntry,
 	if !ok {
 		return nil, fmt.Errorf("failed to read part_number ptr at offset %d", offset)
 	}
-	if partNumPtr == 0 {
-		// End of database
-		entry.PartNumber = ""
-		return entry, nil
-	}
-
-	// Resolve part_number string
-	strOffset, ok := seg.VAddrToDataOffset(partNumPtr)
-	if !ok {
-		return nil, fmt.Errorf("part_number pointer 0x%08x out of range", partNumPtr)
+	// A NULL part_number ends the database; the rest of that entry is the
+	// default password
+	if partNumPtr != 0 {
+		strOffset, ok := seg.VAddrToDataOffset(partNumPtr)
+		if !ok {
+			return nil, fmt.Errorf("part_number pointer 0x%08x out of range", partNumPtr)
+		}
+		entry.PartNumber = seg.ReadStringAt(strOffset)
 	}
-	entry.PartNumber = seg.ReadStringAt(strOffset)
 
 	// Read locked (1 byte at offset +8)
 	locked, ok := seg.ReadByteAt(offset + 8)
@@ -329,6 +328,120 @@ func (db *PasswordDatabase) GetPasswordsToTry(partNum string) []PasswordEntry {
 	return result
 }
 
+// Password lookup algorithms (see API.md, Password Lookup Algorithm)
+const (
+	// LookupFirstMatch tries the first entry for the part number, or every
+	// unique password in the database if there is none (1.0.10).
+	LookupFirstMatch = "first-match"
+	// LookupCollect tries every entry for the part number, deduplicated by
+	// password (1.1.3).
+	LookupCollect = "collect"
+)
+
+// collectLookupVersion is the first version assumed to use LookupCollect.
+// Alternate passwords for a part number ("csww" for OM modules) appear in
+// 1.1.1 and are only reachable if every match is tried.
+const collectLookupVersion = "1.1.1"
+
+// LookupAlgorithm returns the lookup algorithm of a firmware version. If
+// the version is not known, 20-byte entries (1.0.10, 1.1.0) imply
+// LookupFirstMatch.
+func (db *PasswordDatabase) LookupAlgorithm(version string) string {
+	if ValidVersion(version) {
+		if CompareVersions(version, collectLookupVersion) < 0 {
+			return LookupFirstMatch
+		}
+		return LookupCollect
+	}
+	if db.EntrySize == 20 {
+		return LookupFirstMatch
+	}
+	return LookupCollect
+}
+
+// Sources of a PasswordAttempt
+const (
+	AttemptMatch    = "match"    // Entry for the part number
+	AttemptFallback = "fallback" // Any entry, tried because none matched
+	AttemptDefault  = "default"  // The default entry
+)
+
+// PasswordAttempt is a password the firmware would try, with the entry it
+// came from.
+type PasswordAttempt struct {
+	Entry  PasswordEntry `json:"entry"`
+	Source string        `json:"source"`
+}
+
+// Lookup emulates the firmware's password lookup with an algorithm and
+// returns the passwords in the order they would be tried, ending with the
+// default entry. A password is only tried once.
+func (db *PasswordDatabase) Lookup(partNum, algorithm string) []PasswordAttempt {
+	var attempts []PasswordAttempt
+	seen := make(map[[4]byte]bool)
+	add := func(entry PasswordEntry, source string) {
+		if !seen[entry.Password] {
+			seen[entry.Password] = true
+			attempts = append(attempts, PasswordAttempt{Entry: entry, Source: source})
+		}
+	}
+
+	if algorithm == LookupFirstMatch {
+		for _, entry := range db.FindByPartNumber(partNum) {
+			if !entry.ReadOnly {
+				add(entry, AttemptMatch)
+				break
+			}
+		}
+		if len(attempts) == 0 {
+			// Like UniquePasswords, but keeping the entry for its flags
+			for _, entry := range db.Entries {
+				if !entry.ReadOnly && entry.Password != [4]byte{0xff, 0xff, 0xff, 0xff} {
+					add(entry, AttemptFallback)
+				}
+			}
+		}
+	} else {
+		for _, entry := range db.GetPasswordsToTry(partNum) {
+			add(entry, AttemptMatch)
+		}
+	}
+
+	if db.DefaultEntry != nil {
+		add(*db.DefaultEntry, AttemptDefault)
+	}
+	return attempts
+}
+
+// WritablePages returns the pages flags[0] allows writing after unlock,
+// named as by eeprom.Pages. On QSFP modules the lower page bit also covers
+// upper page 00h, which holds the identity fields.
+func (e *PasswordEntry) WritablePages(qsfp bool) []string {
+	var pages []string
+	if qsfp {
+		if e.Flags[0]&0x01 != 0 {
+			pages = append(pages, "lower", "upper00")
+		}
+		if e.Flags[0]&0x02 != 0 {
+			pages = append(pages, "upper01")
+		}
+		if e.Flags[0]&0x04 != 0 {
+			pages = append(pages, "upper02")
+		}
+		if e.Flags[0]&0x08 != 0 {
+			pages = append(pages, "upper03")
+		}
+		return pages
+	}
+	if e.Flags[0]&0x01 != 0 {
+		pages = append(pages, "A0h")
+	}
+	if e.Flags[0]&0x02 != 0 {
+		pages = append(pages, "A2h")
+	}
+	return pages
+}
+
 // FormatFlags returns a human-readable representation of the flags.
 func (e *PasswordEntry) FormatFlags() string {
 	// Check if all zeros
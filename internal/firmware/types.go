@@ -11,7 +11,7 @@ type FirmwareVersion struct {
 	FileSize    int64     `json:"file_size"`
 	MD5         string    `json:"md5"`
 	SHA256      string    `json:"sha256_checksum"`
-	DownloadURL string    `json:"-"` // Extracted from _links
+	DownloadURL string    `json:"download_url,omitempty"` // Extracted from _links
 	Channel     string    `json:"channel"`
 	Product     string    `json:"product"`
 	Platform    string    `json:"platform"`
@@ -40,16 +40,33 @@ func (p TransferProgress) Percent() float64 {
 
 // ManifestFilter defines filters for firmware queries.
 type ManifestFilter struct {
-	Product  string
-	Platform string
-	Channel  string
+	Product  string `json:"product"`
+	Platform string `json:"platform"`
+	Channel  string `json:"channel"`
 }
 
-// DefaultSFPWizardFilter returns the filter for SFP Wizard firmware on ESP32.
+// Firmware release channels.
+const (
+	ChannelRelease = "release"
+	ChannelBeta    = "beta"
+	ChannelRC      = "rc"
+)
+
+// Channels lists the release channels, most stable first.
+var Channels = []string{ChannelRelease, ChannelBeta, ChannelRC}
+
+// DefaultSFPWizardFilter returns the filter for release SFP Wizard firmware
+// on ESP32.
 func DefaultSFPWizardFilter() ManifestFilter {
+	return SFPWizardFilter(ChannelRelease)
+}
+
+// SFPWizardFilter returns the filter for SFP Wizard firmware on ESP32 in a
+// release channel.
+func SFPWizardFilter(channel string) ManifestFilter {
 	return ManifestFilter{
 		Product:  "SFP-Wizard",
 		Platform: "ESP32",
-		Channel:  "release",
+		Channel:  channel,
 	}
 }
@@ -0,0 +1,119 @@
+package firmware
+
+import (
+	"cmp"
+	"strconv"
+	"strings"
+)
+
+// semver is a parsed major.minor.patch[-prerelease] version.
+type semver struct {
+	parts      [3]int
+	prerelease string
+}
+
+// parseVersion parses versions like "1.1.3", "v1.1.3" or "1.2.0-beta.1".
+// Missing minor or patch numbers count as zero and build metadata after
+// "+" is ignored.
+func parseVersion(s string) (semver, bool) {
+	var v semver
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	s, _, _ = strings.Cut(s, "+")
+	s, v.prerelease, _ = strings.Cut(s, "-")
+
+	fields := strings.Split(s, ".")
+	if len(fields) == 0 || len(fields) > 3 {
+		return v, false
+	}
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 0 {
+			return v, false
+		}
+		v.parts[i] = n
+	}
+	return v, true
+}
+
+// ValidVersion reports whether s can be compared with CompareVersions.
+func ValidVersion(s string) bool {
+	_, ok := parseVersion(s)
+	return ok
+}
+
+// CompareVersions compares two firmware versions semantically, returning
+// -1, 0 or 1. A "v" prefix is ignored and a prerelease sorts before its
+// release. Versions that cannot be parsed compare equal.
+func CompareVersions(a, b string) int {
+	va, okA := parseVersion(a)
+	vb, okB := parseVersion(b)
+	if !okA || !okB {
+		return 0
+	}
+	for i := range va.parts {
+		if va.parts[i] != vb.parts[i] {
+			return cmp.Compare(va.parts[i], vb.parts[i])
+		}
+	}
+	return comparePrerelease(va.prerelease, vb.prerelease)
+}
+
+// compareForSort is CompareVersions extended to a total order for sorting:
+// versions that cannot be parsed sort below all valid versions and compare
+// equal to each other, so callers can break the tie on another field.
+func compareForSort(a, b string) int {
+	validA, validB := ValidVersion(a), ValidVersion(b)
+	switch {
+	case validA && validB:
+		return CompareVersions(a, b)
+	case validA:
+		return 1
+	case validB:
+		return -1
+	}
+	return 0
+}
+
+// comparePrerelease orders prerelease strings as semver does: no prerelease
+// sorts last, and dot-separated identifiers compare numerically when both
+// are numbers.
+func comparePrerelease(a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return 1
+	case b == "":
+		return -1
+	}
+
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		na, errA := strconv.Atoi(as[i])
+		nb, errB := strconv.Atoi(bs[i])
+		switch {
+		case errA == nil && errB == nil:
+			if na != nb {
+				return cmp.Compare(na, nb)
+			}
+		case errA == nil:
+			return -1
+		case errB == nil:
+			return 1
+		default:
+			if c := strings.Compare(as[i], bs[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return cmp.Compare(len(as), len(bs))
+}
+
+// SameVersion reports whether a and b name the same version, so that
+// "v1.1.3" and "1.1.3" match.
+func SameVersion(a, b string) bool {
+	if ValidVersion(a) && ValidVersion(b) {
+		return CompareVersions(a, b) == 0
+	}
+	return strings.TrimPrefix(a, "v") == strings.TrimPrefix(b, "v")
+}
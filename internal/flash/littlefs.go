@@ -0,0 +1,424 @@
+package flash
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math/bits"
+	"path"
+	"strings"
+)
+
+// DefaultLittleFSBlockSize is the block size esp_littlefs uses: one flash
+// sector. The superblock records the real one.
+const DefaultLittleFSBlockSize = 4096
+
+// LittleFS tag types (type3, 11 bits)
+const (
+	lfsTypeReg        = 0x001
+	lfsTypeDir        = 0x002
+	lfsTypeSuperblock = 0x0ff
+	lfsTypeDirStruct  = 0x200
+	lfsTypeInline     = 0x201
+	lfsTypeCTZ        = 0x202
+	lfsTypeCreate     = 0x401
+	lfsTypeDelete     = 0x4ff
+	lfsTypeSoftTail   = 0x600
+	lfsTypeHardTail   = 0x601
+)
+
+const lfsBlockNull = 0xFFFFFFFF
+
+// LittleFS is a read-only view of a LittleFS v2 filesystem.
+type LittleFS struct {
+	r    io.ReaderAt
+	size int64
+
+	Version    uint32 // On-disk version, major in the upper 16 bits
+	BlockSize  uint32
+	BlockCount uint32
+	NameMax    uint32
+	FileMax    uint32
+}
+
+// FileInfo describes a file or directory in a LittleFS filesystem.
+type FileInfo struct {
+	Path  string `json:"path"`
+	Name  string `json:"name"`
+	IsDir bool   `json:"is_dir"`
+	Size  int64  `json:"size"`
+
+	inline []byte    // Contents of inline files
+	head   uint32    // First block of the CTZ skip list
+	pair   [2]uint32 // Metadata pair of directories
+}
+
+// lfsEntry is an id of a metadata pair with its name and struct tags.
+type lfsEntry struct {
+	nameType   uint16
+	name       string
+	structType uint16
+	structData []byte
+}
+
+// lfsDir is a fetched metadata pair.
+type lfsDir struct {
+	entries []*lfsEntry
+	tail    [2]uint32
+	split   bool // Tail continues this directory (hard tail)
+}
+
+// OpenLittleFS reads the superblock of a LittleFS filesystem, such as the
+// littlefs data partition of a flash dump.
+func OpenLittleFS(r io.ReaderAt, size int64) (*LittleFS, error) {
+	fs := &LittleFS{r: r, size: size, BlockSize: DefaultLittleFSBlockSize}
+	fs.BlockCount = uint32(size / int64(fs.BlockSize))
+
+	// The block size is needed to read the superblock that records it, so
+	// read with the default and retry if the superblock disagrees.
+	for range 2 {
+		dir, err := fs.fetch([2]uint32{0, 1})
+		if err != nil {
+			return nil, fmt.Errorf("not a LittleFS filesystem: %w", err)
+		}
+		if len(dir.entries) == 0 || dir.entries[0].nameType != lfsTypeSuperblock ||
+			dir.entries[0].name != "littlefs" || len(dir.entries[0].structData) < 24 {
+			return nil, fmt.Errorf("not a LittleFS filesystem: no superblock")
+		}
+		sb := dir.entries[0].structData
+		fs.Version = binary.LittleEndian.Uint32(sb[0:])
+		blockSize := binary.LittleEndian.Uint32(sb[4:])
+		fs.BlockCount = binary.LittleEndian.Uint32(sb[8:])
+		fs.NameMax = binary.LittleEndian.Uint32(sb[12:])
+		fs.FileMax = binary.LittleEndian.Uint32(sb[16:])
+		if fs.Version>>16 != 2 {
+			return nil, fmt.Errorf("unsupported LittleFS version %d.%d", fs.Version>>16, fs.Version&0xffff)
+		}
+		if blockSize == fs.BlockSize {
+			break
+		}
+		if blockSize < 128 || int64(blockSize) > size {
+			return nil, fmt.Errorf("invalid LittleFS block size %d", blockSize)
+		}
+		fs.BlockSize = blockSize
+	}
+	if int64(fs.BlockCount)*int64(fs.BlockSize) > size {
+		return nil, fmt.Errorf("LittleFS has %d blocks of %d bytes, more than the %d bytes available",
+			fs.BlockCount, fs.BlockSize, size)
+	}
+	return fs, nil
+}
+
+// lfsCRC is the CRC-32 LittleFS uses: the IEEE polynomial without the
+// final inversion.
+func lfsCRC(crc uint32, data []byte) uint32 {
+	return ^crc32.Update(^crc, crc32.IEEETable, data)
+}
+
+func (fs *LittleFS) readBlock(block uint32) ([]byte, error) {
+	if block >= fs.BlockCount {
+		return nil, fmt.Errorf("block %d out of range", block)
+	}
+	data := make([]byte, fs.BlockSize)
+	if _, err := fs.r.ReadAt(data, int64(block)*int64(fs.BlockSize)); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return data, nil
+}
+
+// fetch reads a metadata pair, using whichever block has the newer
+// revision and at least one valid commit.
+func (fs *LittleFS) fetch(pair [2]uint32) (*lfsDir, error) {
+	var blocks [2][]byte
+	for i, b := range pair {
+		data, err := fs.readBlock(b)
+		if err != nil {
+			return nil, err
+		}
+		blocks[i] = data
+	}
+	revA := binary.LittleEndian.Uint32(blocks[0])
+	revB := binary.LittleEndian.Uint32(blocks[1])
+	order := []int{0, 1}
+	if int32(revB-revA) > 0 {
+		order = []int{1, 0}
+	}
+	for _, i := range order {
+		if dir, ok := parseMetadataBlock(blocks[i]); ok {
+			return dir, nil
+		}
+	}
+	return nil, fmt.Errorf("metadata pair {%d, %d} has no valid commit", pair[0], pair[1])
+}
+
+// parseMetadataBlock replays the valid commits of a metadata block. Tags
+// are big-endian and XORed with the previous tag; each commit ends with a
+// CRC tag covering everything since the previous one.
+func parseMetadataBlock(data []byte) (*lfsDir, bool) {
+	dir := &lfsDir{tail: [2]uint32{lfsBlockNull, lfsBlockNull}}
+	type attr struct {
+		tag  uint32
+		data []byte
+	}
+	var pending []attr
+	committed := false
+
+	crc := lfsCRC(0xFFFFFFFF, data[:4])
+	ptag := uint32(0xFFFFFFFF)
+	off := 4
+	for off+4 <= len(data) {
+		raw := data[off : off+4]
+		tag := binary.BigEndian.Uint32(raw) ^ ptag
+		if tag&0x80000000 != 0 {
+			break // Not yet programmed
+		}
+		size := int(tag & 0x3ff)
+		if size == 0x3ff {
+			size = 0 // Deleted
+		}
+		if off+4+size > len(data) {
+			break
+		}
+		crc = lfsCRC(crc, raw)
+		ptag = tag
+		typ := uint16(tag>>20) & 0x7ff
+
+		if typ&0x780 == 0x500 { // Commit CRC
+			if size < 4 || binary.LittleEndian.Uint32(data[off+4:]) != crc {
+				break
+			}
+			for _, a := range pending {
+				dir.apply(a.tag, a.data)
+			}
+			pending = pending[:0]
+			committed = true
+			ptag ^= (tag >> 20 & 1) << 31 // Valid bit of the next commit
+			crc = 0xFFFFFFFF
+			off += 4 + size
+			continue
+		}
+
+		body := data[off+4 : off+4+size]
+		crc = lfsCRC(crc, body)
+		pending = append(pending, attr{tag, body})
+		off += 4 + size
+	}
+	return dir, committed
+}
+
+// apply applies one tag of a valid commit.
+func (dir *lfsDir) apply(tag uint32, data []byte) {
+	typ := uint16(tag>>20) & 0x7ff
+	id := int(tag>>10) & 0x3ff
+	deleted := tag&0x3ff == 0x3ff
+
+	switch {
+	case typ == lfsTypeCreate:
+		if id <= len(dir.entries) {
+			dir.entries = append(dir.entries, nil)
+			copy(dir.entries[id+1:], dir.entries[id:])
+			dir.entries[id] = &lfsEntry{}
+		}
+		return
+	case typ == lfsTypeDelete:
+		if id < len(dir.entries) {
+			dir.entries = append(dir.entries[:id], dir.entries[id+1:]...)
+		}
+		return
+	case typ&0x700 == 0x600: // Tail
+		if len(data) >= 8 {
+			dir.tail = [2]uint32{binary.LittleEndian.Uint32(data), binary.LittleEndian.Uint32(data[4:])}
+			dir.split = typ == lfsTypeHardTail
+		}
+		return
+	}
+
+	if typ&0x700 != 0x000 && typ&0x700 != 0x200 {
+		return // User attributes and global state
+	}
+	for id >= len(dir.entries) {
+		dir.entries = append(dir.entries, &lfsEntry{})
+	}
+	e := dir.entries[id]
+	if typ&0x700 == 0x000 {
+		e.nameType, e.name = typ, string(data)
+	} else if deleted {
+		e.structType, e.structData = 0, nil
+	} else {
+		e.structType, e.structData = typ, data
+	}
+}
+
+// readDirPair lists a directory, following hard tails to the metadata pairs
+// it continues in.
+func (fs *LittleFS) readDirPair(pair [2]uint32, dirPath string) ([]FileInfo, error) {
+	var result []FileInfo
+	seen := make(map[[2]uint32]bool)
+	for {
+		if seen[pair] {
+			return nil, fmt.Errorf("metadata pair {%d, %d} loops", pair[0], pair[1])
+		}
+		seen[pair] = true
+
+		dir, err := fs.fetch(pair)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range dir.entries {
+			if info, ok := fileInfo(e, dirPath); ok {
+				result = append(result, info)
+			}
+		}
+		if !dir.split || dir.tail[0] == lfsBlockNull {
+			return result, nil
+		}
+		pair = dir.tail
+	}
+}
+
+func fileInfo(e *lfsEntry, dirPath string) (FileInfo, bool) {
+	info := FileInfo{Name: e.name, Path: path.Join(dirPath, e.name)}
+	switch {
+	case e.nameType == lfsTypeDir && e.structType == lfsTypeDirStruct && len(e.structData) >= 8:
+		info.IsDir = true
+		info.pair = [2]uint32{binary.LittleEndian.Uint32(e.structData), binary.LittleEndian.Uint32(e.structData[4:])}
+	case e.nameType == lfsTypeReg && e.structType == lfsTypeInline:
+		info.inline = e.structData
+		info.Size = int64(len(e.structData))
+	case e.nameType == lfsTypeReg && e.structType == lfsTypeCTZ && len(e.structData) >= 8:
+		info.head = binary.LittleEndian.Uint32(e.structData)
+		info.Size = int64(binary.LittleEndian.Uint32(e.structData[4:]))
+	case e.nameType == lfsTypeReg && e.structType == 0:
+		// Created but not yet written
+	default:
+		return info, false
+	}
+	return info, true
+}
+
+// ReadDir lists the directory at a path ("/" for the root).
+func (fs *LittleFS) ReadDir(dirPath string) ([]FileInfo, error) {
+	info, err := fs.Stat(dirPath)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir {
+		return nil, fmt.Errorf("%s: not a directory", dirPath)
+	}
+	return fs.readDirPair(info.pair, info.Path)
+}
+
+// Stat looks up a file or directory by path.
+func (fs *LittleFS) Stat(p string) (*FileInfo, error) {
+	cur := &FileInfo{Path: "/", Name: "/", IsDir: true, pair: [2]uint32{0, 1}}
+	for _, name := range strings.Split(strings.Trim(path.Clean("/"+p), "/"), "/") {
+		if name == "" {
+			continue
+		}
+		if !cur.IsDir {
+			return nil, fmt.Errorf("%s: not a directory", cur.Path)
+		}
+		entries, err := fs.readDirPair(cur.pair, cur.Path)
+		if err != nil {
+			return nil, err
+		}
+		var next *FileInfo
+		for i := range entries {
+			if entries[i].Name == name {
+				next = &entries[i]
+				break
+			}
+		}
+		if next == nil {
+			return nil, fmt.Errorf("%s: no such file or directory", path.Join(cur.Path, name))
+		}
+		cur = next
+	}
+	return cur, nil
+}
+
+// Walk calls fn for every file and directory below root, depth first in
+// directory order.
+func (fs *LittleFS) Walk(root string, fn func(info FileInfo) error) error {
+	entries, err := fs.ReadDir(root)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := fn(e); err != nil {
+			return err
+		}
+		if e.IsDir {
+			if err := fs.Walk(e.Path, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ReadFile returns the contents of a file.
+func (fs *LittleFS) ReadFile(p string) ([]byte, error) {
+	info, err := fs.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	return fs.Read(info)
+}
+
+// Read returns the contents of a file found by Stat, ReadDir or Walk.
+func (fs *LittleFS) Read(info *FileInfo) ([]byte, error) {
+	if info.IsDir {
+		return nil, fmt.Errorf("%s: is a directory", info.Path)
+	}
+	if info.inline != nil || info.Size == 0 {
+		return info.inline, nil
+	}
+	if info.Size > int64(fs.BlockCount)*int64(fs.BlockSize) {
+		return nil, fmt.Errorf("%s: size %d larger than the filesystem", info.Path, info.Size)
+	}
+
+	// Files are stored as a CTZ skip list: block n starts with ctz(n)+1
+	// pointers, the first to block n-1. Walk back from the head to find
+	// every block, then read the data after the pointers.
+	last := fs.ctzIndex(info.Size - 1)
+	blocks := make([]uint32, last+1)
+	blocks[last] = info.head
+	for n := last; n > 0; n-- {
+		data, err := fs.readBlock(blocks[n])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", info.Path, err)
+		}
+		blocks[n-1] = binary.LittleEndian.Uint32(data)
+	}
+
+	out := make([]byte, 0, info.Size)
+	for n, b := range blocks {
+		data, err := fs.readBlock(b)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", info.Path, err)
+		}
+		start := 0
+		if n > 0 {
+			start = 4 * (bits.TrailingZeros32(uint32(n)) + 1)
+		}
+		data = data[start:]
+		if remaining := info.Size - int64(len(out)); int64(len(data)) > remaining {
+			data = data[:remaining]
+		}
+		out = append(out, data...)
+	}
+	return out, nil
+}
+
+// ctzIndex returns the index of the skip list block holding a file offset.
+func (fs *LittleFS) ctzIndex(off int64) int {
+	n := 0
+	capacity := int64(fs.BlockSize)
+	for off >= capacity {
+		n++
+		capacity += int64(fs.BlockSize) - 4*int64(bits.TrailingZeros32(uint32(n))+1)
+	}
+	return n
+}
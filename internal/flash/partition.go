@@ -0,0 +1,409 @@
+// Package flash reads raw ESP32 flash dumps: the ESP-IDF partition table,
+// the OTA selection data, the app partitions and the LittleFS data
+// partition.
+package flash
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+
+	"github.com/vitaminmoo/sfpw-tool/internal/firmware"
+)
+
+// Partition table constants
+const (
+	PartitionTableOffset = 0x8000
+	PartitionTableSize   = 0xC00
+	PartitionEntrySize   = 32
+	PartitionMagic       = 0x50AA
+	partitionMD5Magic    = 0xEBEB
+)
+
+// Partition types
+const (
+	TypeApp  = 0x00
+	TypeData = 0x01
+)
+
+// App partition subtypes
+const (
+	SubtypeFactory = 0x00
+	SubtypeOTA0    = 0x10 // ota_0 to ota_15 are 0x10 to 0x1f
+	SubtypeTest    = 0x20
+)
+
+// Data partition subtypes
+const (
+	SubtypeOTAData  = 0x00
+	SubtypePHY      = 0x01
+	SubtypeNVS      = 0x02
+	SubtypeCoredump = 0x03
+	SubtypeNVSKeys  = 0x04
+	SubtypeEFuse    = 0x05
+	SubtypeFAT      = 0x81
+	SubtypeSPIFFS   = 0x82
+	SubtypeLittleFS = 0x83
+)
+
+// Partition flags
+const (
+	FlagEncrypted = 1 << 0
+	FlagReadOnly  = 1 << 1
+)
+
+// Partition is an entry of the ESP-IDF partition table.
+type Partition struct {
+	Label   string `json:"label"`
+	Type    uint8  `json:"type"`
+	Subtype uint8  `json:"subtype"`
+	Offset  uint32 `json:"offset"`
+	Size    uint32 `json:"size"`
+	Flags   uint32 `json:"flags"`
+}
+
+// esp_partition_info_t layout
+type rawPartition struct {
+	Magic   uint16
+	Type    uint8
+	Subtype uint8
+	Offset  uint32
+	Size    uint32
+	Label   [16]byte
+	Flags   uint32
+}
+
+// TypeName returns the partition type as in partitions.csv.
+func (p *Partition) TypeName() string {
+	switch p.Type {
+	case TypeApp:
+		return "app"
+	case TypeData:
+		return "data"
+	default:
+		return fmt.Sprintf("0x%02x", p.Type)
+	}
+}
+
+// SubtypeName returns the partition subtype as in partitions.csv.
+func (p *Partition) SubtypeName() string {
+	if p.Type == TypeApp {
+		switch {
+		case p.Subtype == SubtypeFactory:
+			return "factory"
+		case p.IsOTA():
+			return fmt.Sprintf("ota_%d", p.Subtype-SubtypeOTA0)
+		case p.Subtype == SubtypeTest:
+			return "test"
+		}
+	}
+	if p.Type == TypeData {
+		switch p.Subtype {
+		case SubtypeOTAData:
+			return "ota"
+		case SubtypePHY:
+			return "phy"
+		case SubtypeNVS:
+			return "nvs"
+		case SubtypeCoredump:
+			return "coredump"
+		case SubtypeNVSKeys:
+			return "nvs_keys"
+		case SubtypeEFuse:
+			return "efuse"
+		case SubtypeFAT:
+			return "fat"
+		case SubtypeSPIFFS:
+			return "spiffs"
+		case SubtypeLittleFS:
+			return "littlefs"
+		}
+	}
+	return fmt.Sprintf("0x%02x", p.Subtype)
+}
+
+// IsApp reports whether the partition holds an app image.
+func (p *Partition) IsApp() bool {
+	return p.Type == TypeApp
+}
+
+// IsOTA reports whether the partition is an OTA app slot.
+func (p *Partition) IsOTA() bool {
+	return p.Type == TypeApp && p.Subtype >= SubtypeOTA0 && p.Subtype < SubtypeOTA0+16
+}
+
+// Image is a raw flash dump.
+type Image struct {
+	r    io.ReaderAt
+	size int64
+
+	Partitions []Partition
+	MD5Valid   bool // Table has an MD5 entry and it matches
+	HasMD5     bool
+
+	closer io.Closer
+}
+
+// Open opens a flash dump file and parses its partition table.
+func Open(path string) (*Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	img, err := New(f, info.Size())
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	img.closer = f
+	return img, nil
+}
+
+// New parses the partition table of a flash dump.
+func New(r io.ReaderAt, size int64) (*Image, error) {
+	img := &Image{r: r, size: size}
+
+	table := make([]byte, PartitionTableSize)
+	if _, err := r.ReadAt(table, PartitionTableOffset); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read partition table: %w", err)
+	}
+
+	for off := 0; off+PartitionEntrySize <= len(table); off += PartitionEntrySize {
+		entry := table[off : off+PartitionEntrySize]
+		magic := binary.LittleEndian.Uint16(entry)
+		if magic == partitionMD5Magic {
+			img.HasMD5 = true
+			sum := md5.Sum(table[:off])
+			img.MD5Valid = bytes.Equal(sum[:], entry[16:32])
+			break
+		}
+		if magic != PartitionMagic {
+			break
+		}
+
+		var raw rawPartition
+		binary.Read(bytes.NewReader(entry), binary.LittleEndian, &raw)
+		label := raw.Label[:]
+		if i := bytes.IndexByte(label, 0); i >= 0 {
+			label = label[:i]
+		}
+		img.Partitions = append(img.Partitions, Partition{
+			Label:   string(label),
+			Type:    raw.Type,
+			Subtype: raw.Subtype,
+			Offset:  raw.Offset,
+			Size:    raw.Size,
+			Flags:   raw.Flags,
+		})
+	}
+
+	if len(img.Partitions) == 0 {
+		return nil, fmt.Errorf("no partition table at 0x%x (not a full flash dump?)", PartitionTableOffset)
+	}
+	return img, nil
+}
+
+// Close closes the file opened by Open.
+func (img *Image) Close() error {
+	if img.closer != nil {
+		return img.closer.Close()
+	}
+	return nil
+}
+
+// Size returns the length of the dump.
+func (img *Image) Size() int64 {
+	return img.size
+}
+
+// Find returns the partition with a label, or nil.
+func (img *Image) Find(label string) *Partition {
+	for i := range img.Partitions {
+		if img.Partitions[i].Label == label {
+			return &img.Partitions[i]
+		}
+	}
+	return nil
+}
+
+// FindType returns the first partition of a type and subtype, or nil.
+func (img *Image) FindType(typ, subtype uint8) *Partition {
+	for i := range img.Partitions {
+		if img.Partitions[i].Type == typ && img.Partitions[i].Subtype == subtype {
+			return &img.Partitions[i]
+		}
+	}
+	return nil
+}
+
+// Reader returns the contents of a partition. It fails if the partition
+// extends past the end of the dump.
+func (img *Image) Reader(p *Partition) (*io.SectionReader, error) {
+	if int64(p.Offset)+int64(p.Size) > img.size {
+		return nil, fmt.Errorf("partition %s (0x%x+0x%x) extends past the end of the dump (0x%x)",
+			p.Label, p.Offset, p.Size, img.size)
+	}
+	return io.NewSectionReader(img.r, int64(p.Offset), int64(p.Size)), nil
+}
+
+// AppImage parses the app image in an app partition. Erased slots fail with
+// an invalid magic error.
+func (img *Image) AppImage(p *Partition) (*firmware.ESP32Image, error) {
+	if !p.IsApp() {
+		return nil, fmt.Errorf("partition %s is not an app partition", p.Label)
+	}
+	r, err := img.Reader(p)
+	if err != nil {
+		return nil, err
+	}
+	return firmware.ParseESP32ImageReader(r)
+}
+
+// OTA image states (esp_ota_img_states_t)
+const (
+	OTAStateNew           = 0x0
+	OTAStatePendingVerify = 0x1
+	OTAStateValid         = 0x2
+	OTAStateInvalid       = 0x3
+	OTAStateAborted       = 0x4
+	OTAStateUndefined     = 0xFFFFFFFF
+)
+
+// OTASelect is one of the two entries of the otadata partition
+// (esp_ota_select_entry_t).
+type OTASelect struct {
+	Seq   uint32
+	Label [20]byte
+	State uint32
+	CRC   uint32
+}
+
+// Valid reports whether the entry is written and its CRC matches.
+func (s *OTASelect) Valid() bool {
+	if s.Seq == 0xFFFFFFFF {
+		return false
+	}
+	var seq [4]byte
+	binary.LittleEndian.PutUint32(seq[:], s.Seq)
+	// esp_rom_crc32_le(UINT32_MAX, ...) has the same pre- and post-inversion
+	// as crc32.Update
+	return crc32.Update(0xFFFFFFFF, crc32.IEEETable, seq[:]) == s.CRC
+}
+
+// StateName returns the OTA image state.
+func (s *OTASelect) StateName() string {
+	switch s.State {
+	case OTAStateNew:
+		return "new"
+	case OTAStatePendingVerify:
+		return "pending-verify"
+	case OTAStateValid:
+		return "valid"
+	case OTAStateInvalid:
+		return "invalid"
+	case OTAStateAborted:
+		return "aborted"
+	case OTAStateUndefined:
+		return "undefined"
+	default:
+		return fmt.Sprintf("0x%x", s.State)
+	}
+}
+
+// BootPartition returns the app partition the bootloader would select:
+// the OTA slot named by the newest valid otadata entry, or the factory app
+// (or first OTA slot) if otadata is missing or blank. The otadata entry is
+// nil in the latter case.
+func (img *Image) BootPartition() (*Partition, *OTASelect, error) {
+	var slots []*Partition
+	for i := range img.Partitions {
+		if img.Partitions[i].IsOTA() {
+			slots = append(slots, &img.Partitions[i])
+		}
+	}
+	fallback := img.FindType(TypeApp, SubtypeFactory)
+	if fallback == nil && len(slots) > 0 {
+		fallback = slots[0]
+	}
+
+	otadata := img.FindType(TypeData, SubtypeOTAData)
+	if otadata == nil || len(slots) == 0 {
+		return fallback, nil, nil
+	}
+	r, err := img.Reader(otadata)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The two entries are at the start of the partition's two sectors
+	var best *OTASelect
+	for _, off := range []int64{0, 0x1000} {
+		var s OTASelect
+		if err := binary.Read(io.NewSectionReader(r, off, 32), binary.LittleEndian, &s); err != nil {
+			return nil, nil, fmt.Errorf("failed to read otadata: %w", err)
+		}
+		if !s.Valid() || s.State == OTAStateInvalid || s.State == OTAStateAborted {
+			continue
+		}
+		if best == nil || s.Seq > best.Seq {
+			best = &s
+		}
+	}
+	if best == nil {
+		return fallback, nil, nil
+	}
+	slot := uint8((best.Seq - 1) % uint32(len(slots)))
+	if p := img.FindType(TypeApp, SubtypeOTA0+slot); p != nil {
+		return p, best, nil
+	}
+	return nil, best, fmt.Errorf("otadata selects ota_%d, which is not in the partition table", slot)
+}
+
+// LittleFS opens the LittleFS filesystem in the partition with a label, or
+// if label is empty, in the first littlefs partition. esp_littlefs also
+// accepts spiffs partitions, so those are tried next.
+func (img *Image) LittleFS(label string) (*LittleFS, *Partition, error) {
+	var candidates []*Partition
+	if label != "" {
+		p := img.Find(label)
+		if p == nil {
+			return nil, nil, fmt.Errorf("no partition labelled %q", label)
+		}
+		candidates = append(candidates, p)
+	} else {
+		for _, subtype := range []uint8{SubtypeLittleFS, SubtypeSPIFFS} {
+			for i := range img.Partitions {
+				if img.Partitions[i].Type == TypeData && img.Partitions[i].Subtype == subtype {
+					candidates = append(candidates, &img.Partitions[i])
+				}
+			}
+		}
+		if len(candidates) == 0 {
+			return nil, nil, fmt.Errorf("no littlefs or spiffs partition in the partition table")
+		}
+	}
+
+	var firstErr error
+	for _, p := range candidates {
+		r, err := img.Reader(p)
+		if err == nil {
+			var fs *LittleFS
+			if fs, err = OpenLittleFS(r, int64(p.Size)); err == nil {
+				return fs, p, nil
+			}
+		}
+		if firstErr == nil {
+			firstErr = fmt.Errorf("partition %s: %w", p.Label, err)
+		}
+	}
+	return nil, nil, firstErr
+}
@@ -0,0 +1,372 @@
+package store
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/vitaminmoo/sfpw-tool/internal/eeprom"
+)
+
+// BundleFormat identifies store bundle manifests.
+const BundleFormat = "sfpw-bundle/1"
+
+// Bundle compression
+const (
+	BundleZstd = "zstd"
+	BundleGzip = "gzip"
+	BundleTar  = "none"
+)
+
+// BundleCompression picks the compression for a bundle file by extension,
+// defaulting to zstd.
+func BundleCompression(filename string) string {
+	switch {
+	case strings.HasSuffix(filename, ".gz"), strings.HasSuffix(filename, ".tgz"):
+		return BundleGzip
+	case strings.HasSuffix(filename, ".tar"):
+		return BundleTar
+	default:
+		return BundleZstd
+	}
+}
+
+// BundleManifest lists the profiles in a bundle with the hashes that
+// unpacking verifies.
+type BundleManifest struct {
+	Format   string        `json:"format"`
+	Created  time.Time     `json:"created"`
+	Stripped bool          `json:"stripped,omitempty"` // Serial numbers and device MACs removed
+	Profiles []BundleEntry `json:"profiles"`
+}
+
+// BundleEntry is one profile in a bundle.
+type BundleEntry struct {
+	Hash  string   `json:"hash"`  // ContentHash of the profile data
+	Blobs []string `json:"blobs"` // BlobHash of each variant
+}
+
+// bundleProfile is a profile with all of its data, as packed or unpacked.
+type bundleProfile struct {
+	meta    *Metadata
+	profile []byte
+	blobs   map[string][]byte
+}
+
+// Pack writes the given profiles to w as a compressed tar bundle holding a
+// manifest, metadata, primary dumps and variant blobs. With strip, serial
+// numbers are blanked in every dump and device MACs and filenames are
+// dropped from sources; profiles that become identical are merged.
+func (s *Store) Pack(w io.Writer, hashes []string, compression string, strip bool) (*BundleManifest, error) {
+	profiles := make(map[string]*bundleProfile)
+	for _, hash := range hashes {
+		p, err := s.loadBundleProfile(hash)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", ShortHash(hash), err)
+		}
+		if strip {
+			if p, err = stripProfile(p); err != nil {
+				return nil, fmt.Errorf("%s: %w", ShortHash(hash), err)
+			}
+		}
+		if existing, ok := profiles[p.meta.ContentHash]; ok {
+			existing.meta.Merge(p.meta)
+			for h, data := range p.blobs {
+				existing.blobs[h] = data
+			}
+			continue
+		}
+		profiles[p.meta.ContentHash] = p
+	}
+
+	manifest := &BundleManifest{Format: BundleFormat, Created: time.Now(), Stripped: strip}
+	keys := make([]string, 0, len(profiles))
+	for hash := range profiles {
+		keys = append(keys, hash)
+	}
+	sort.Strings(keys)
+	for _, hash := range keys {
+		entry := BundleEntry{Hash: hash}
+		for _, v := range profiles[hash].meta.Variants {
+			entry.Blobs = append(entry.Blobs, v.Hash)
+		}
+		manifest.Profiles = append(manifest.Profiles, entry)
+	}
+
+	cw, err := compressWriter(w, compression)
+	if err != nil {
+		return nil, err
+	}
+	tw := tar.NewWriter(cw)
+	add := func(name string, data []byte) error {
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), ModTime: manifest.Created}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := add("manifest.json", manifestJSON); err != nil {
+		return nil, err
+	}
+	for _, hash := range keys {
+		p := profiles[hash]
+		name := hashToFilename(hash)
+		metaJSON, err := json.MarshalIndent(p.meta, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		if err := add("metadata/"+name+".json", metaJSON); err != nil {
+			return nil, err
+		}
+		if err := add("profiles/"+name+".bin", p.profile); err != nil {
+			return nil, err
+		}
+		for _, v := range p.meta.Variants {
+			if err := add("blobs/"+name+"/"+hashToFilename(v.Hash)+".bin", p.blobs[v.Hash]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := cw.Close(); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// loadBundleProfile reads a profile with all its variant blobs. Profiles
+// from before variants were tracked get their primary dump as a variant.
+func (s *Store) loadBundleProfile(hash string) (*bundleProfile, error) {
+	meta, err := s.GetMetadata(hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metadata: %w", err)
+	}
+	profile, err := s.Get(hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read profile: %w", err)
+	}
+
+	p := &bundleProfile{meta: meta, profile: profile, blobs: make(map[string][]byte)}
+	if len(meta.Variants) == 0 {
+		addVariant(meta, profile, meta.CreatedAt)
+	}
+	for _, v := range meta.Variants {
+		data, _, err := s.GetVariant(hash, v.Hash)
+		if err != nil {
+			return nil, err
+		}
+		p.blobs[v.Hash] = data
+	}
+	return p, nil
+}
+
+// stripProfile blanks the serial number in every dump of a profile and
+// removes device MACs and filenames from its sources. The result is keyed
+// by the new content hash.
+func stripProfile(p *bundleProfile) (*bundleProfile, error) {
+	profile := eeprom.StripSerial(p.profile)
+	hash, err := ContentHash(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := ExtractMetadata(profile, hash)
+	if meta == nil {
+		return nil, fmt.Errorf("cannot parse profile")
+	}
+	meta.CreatedAt = p.meta.CreatedAt
+	meta.UpdatedAt = p.meta.UpdatedAt
+	meta.Tags = p.meta.Tags
+	meta.Notes = p.meta.Notes
+
+	out := &bundleProfile{meta: meta, profile: profile, blobs: make(map[string][]byte)}
+	renamed := make(map[string]string)
+	for _, v := range p.meta.Variants {
+		data := eeprom.StripSerial(p.blobs[v.Hash])
+		blob := BlobHash(data)
+		renamed[v.Hash] = blob
+		if addVariant(meta, data, v.FirstSeen) {
+			out.blobs[blob] = data
+		}
+	}
+	for _, src := range p.meta.Sources {
+		src.DeviceMAC = ""
+		src.Filename = ""
+		src.Blob = renamed[src.Blob]
+		if !meta.hasSource(src) {
+			meta.Sources = append(meta.Sources, src)
+		}
+	}
+	return out, nil
+}
+
+// UnpackResult summarises an unpacked bundle.
+type UnpackResult struct {
+	Manifest  *BundleManifest
+	New       []string            // Hashes of profiles added
+	Merged    []string            // Hashes of existing profiles that gained data
+	Unchanged []string            // Hashes of existing profiles already up to date
+	Conflicts map[string][]string // Conflicts per hash
+}
+
+// Unpack merges a bundle into the store. Every profile and blob is verified
+// against its hash and the manifest before anything is written.
+func (s *Store) Unpack(r io.Reader) (*UnpackResult, error) {
+	manifest, profiles, err := readBundle(r)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &UnpackResult{Manifest: manifest, Conflicts: make(map[string][]string)}
+	for _, entry := range manifest.Profiles {
+		p := profiles[entry.Hash]
+		blobs := make([][]byte, 0, len(p.blobs))
+		for _, h := range entry.Blobs {
+			blobs = append(blobs, p.blobs[h])
+		}
+
+		isNew, changed, conflicts, err := s.MergeProfile(p.meta, p.profile, blobs)
+		if err != nil {
+			return result, fmt.Errorf("%s: %w", ShortHash(entry.Hash), err)
+		}
+		switch {
+		case isNew:
+			result.New = append(result.New, entry.Hash)
+		case changed:
+			result.Merged = append(result.Merged, entry.Hash)
+		default:
+			result.Unchanged = append(result.Unchanged, entry.Hash)
+		}
+		if len(conflicts) > 0 {
+			result.Conflicts[entry.Hash] = conflicts
+		}
+	}
+	return result, nil
+}
+
+// readBundle reads and verifies a bundle.
+func readBundle(r io.Reader) (*BundleManifest, map[string]*bundleProfile, error) {
+	dr, err := decompressReader(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer dr.Close()
+
+	files := make(map[string][]byte)
+	tr := tar.NewReader(dr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid bundle: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid bundle: %w", err)
+		}
+		files[path.Clean(hdr.Name)] = data
+	}
+
+	var manifest BundleManifest
+	if err := json.Unmarshal(files["manifest.json"], &manifest); err != nil {
+		return nil, nil, fmt.Errorf("invalid bundle manifest: %w", err)
+	}
+	if manifest.Format != BundleFormat {
+		return nil, nil, fmt.Errorf("unsupported bundle format %q", manifest.Format)
+	}
+
+	profiles := make(map[string]*bundleProfile)
+	for _, entry := range manifest.Profiles {
+		name := hashToFilename(entry.Hash)
+		short := ShortHash(entry.Hash)
+		p := &bundleProfile{profile: files["profiles/"+name+".bin"], blobs: make(map[string][]byte)}
+
+		if hash, err := ContentHash(p.profile); err != nil || hash != entry.Hash {
+			return nil, nil, fmt.Errorf("%s: profile data does not match its hash", short)
+		}
+		p.meta = &Metadata{}
+		if err := json.Unmarshal(files["metadata/"+name+".json"], p.meta); err != nil {
+			return nil, nil, fmt.Errorf("%s: invalid metadata: %w", short, err)
+		}
+		if p.meta.ContentHash != entry.Hash {
+			return nil, nil, fmt.Errorf("%s: metadata is for %s", short, ShortHash(p.meta.ContentHash))
+		}
+
+		for _, blob := range entry.Blobs {
+			data, ok := files["blobs/"+name+"/"+hashToFilename(blob)+".bin"]
+			if !ok || BlobHash(data) != blob {
+				return nil, nil, fmt.Errorf("%s: variant %s does not match its hash", short, ShortHash(blob))
+			}
+			if hash, err := ContentHash(data); err != nil || hash != entry.Hash {
+				return nil, nil, fmt.Errorf("%s: variant %s belongs to another profile", short, ShortHash(blob))
+			}
+			p.blobs[blob] = data
+		}
+		for _, v := range p.meta.Variants {
+			if _, ok := p.blobs[v.Hash]; !ok {
+				return nil, nil, fmt.Errorf("%s: variant %s missing from bundle", short, ShortHash(v.Hash))
+			}
+		}
+		profiles[entry.Hash] = p
+	}
+	return &manifest, profiles, nil
+}
+
+func compressWriter(w io.Writer, compression string) (io.WriteCloser, error) {
+	switch compression {
+	case BundleZstd:
+		return zstd.NewWriter(w)
+	case BundleGzip:
+		return gzip.NewWriter(w), nil
+	case BundleTar:
+		return nopWriteCloser{w}, nil
+	default:
+		return nil, fmt.Errorf("unknown bundle compression: %s", compression)
+	}
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+// decompressReader detects zstd, gzip or plain tar by magic number.
+func decompressReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(4)
+	switch {
+	case bytes.Equal(magic, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		return zr.IOReadCloser(), nil
+	case len(magic) >= 2 && magic[0] == 0x1f && magic[1] == 0x8b:
+		return gzip.NewReader(br)
+	default:
+		return io.NopCloser(br), nil
+	}
+}
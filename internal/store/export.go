@@ -0,0 +1,203 @@
+package store
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vitaminmoo/sfpw-tool/internal/eeprom"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Export formats supported by WriteProfile.
+const (
+	ExportRaw      = "raw"
+	ExportIntelHex = "intel-hex"
+	ExportEthtool  = "ethtool"
+	ExportJSON     = "json"
+	ExportYAML     = "yaml"
+	ExportCSV      = "csv"
+)
+
+// Document is the annotated JSON/YAML representation of a profile.
+// Pages hold the exact image bytes; importing a document rebuilds them
+// byte for byte (see eeprom.Decode).
+type Document struct {
+	Format   string         `json:"format"`
+	Metadata *Metadata      `json:"metadata,omitempty"`
+	Fields   []eeprom.Field `json:"fields"`
+	Pages    []eeprom.Page  `json:"pages"`
+}
+
+// NewDocument builds a profile document from EEPROM data and its metadata.
+func NewDocument(data []byte, meta *Metadata) *Document {
+	return &Document{
+		Format:   eeprom.DocumentFormat,
+		Metadata: meta,
+		Fields:   eeprom.Annotate(data),
+		Pages:    eeprom.Pages(data),
+	}
+}
+
+// WriteProfile writes a single profile in the given export format.
+func WriteProfile(w io.Writer, format string, data []byte, meta *Metadata) error {
+	switch format {
+	case ExportRaw:
+		_, err := w.Write(data)
+		return err
+	case ExportIntelHex:
+		return eeprom.WriteIntelHex(w, data)
+	case ExportEthtool:
+		return eeprom.WriteEthtoolHex(w, data)
+	case ExportJSON:
+		out, err := json.MarshalIndent(NewDocument(data, meta), "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(out))
+		return err
+	case ExportYAML:
+		out, err := json.Marshal(NewDocument(data, meta))
+		if err != nil {
+			return err
+		}
+		out, err = jsonToYAML(out)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(out)
+		return err
+	case ExportCSV:
+		return WriteCSV(w, []*Metadata{meta})
+	default:
+		return fmt.Errorf("unknown export format: %s", format)
+	}
+}
+
+// jsonToYAML re-encodes JSON as block-style YAML, keeping key order and
+// leaving string values quoted so values like part numbers stay strings.
+func jsonToYAML(data []byte) ([]byte, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(escapeNonPrintable(data), &node); err != nil {
+		return nil, err
+	}
+	var unflow func(n *yaml.Node)
+	unflow = func(n *yaml.Node) {
+		switch n.Kind {
+		case yaml.MappingNode:
+			n.Style = 0
+			for i := 0; i < len(n.Content); i += 2 {
+				n.Content[i].Style = 0 // Keys
+			}
+		case yaml.SequenceNode:
+			n.Style = 0
+		}
+		for _, c := range n.Content {
+			unflow(c)
+		}
+	}
+	unflow(&node)
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&node); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// escapeNonPrintable replaces characters that YAML does not allow in a
+// stream, such as DEL and C1 controls from malformed EEPROM fields, with
+// JSON \u escapes. JSON escapes C0 controls itself and the characters can
+// only occur inside strings, so the result is equivalent JSON.
+func escapeNonPrintable(data []byte) []byte {
+	var buf bytes.Buffer
+	for _, r := range string(data) {
+		if r == 0x7f || r >= 0x80 && r <= 0x9f && r != 0x85 || r == 0xfeff || r == 0xfffe || r == 0xffff {
+			fmt.Fprintf(&buf, "\\u%04x", r)
+			continue
+		}
+		buf.WriteRune(r)
+	}
+	return buf.Bytes()
+}
+
+var csvHeader = []string{
+	"content_hash", "module_type", "size",
+	"vendor_name", "vendor_oui", "part_number", "revision", "serial_number", "date_code",
+	"connector_type", "wavelength_nm", "bitrate_mbps", "encoding", "link_length_m",
+	"compliance", "checksum_valid", "sources", "created_at", "updated_at",
+	"tags", "notes",
+}
+
+// WriteCSV writes one flat row per profile.
+func WriteCSV(w io.Writer, metas []*Metadata) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, m := range metas {
+		if m == nil {
+			continue
+		}
+		row := []string{
+			m.ContentHash, m.ModuleType, strconv.Itoa(m.Size),
+			m.Identity.VendorName, m.Identity.VendorOUI, m.Identity.PartNumber,
+			m.Identity.Revision, m.Identity.SerialNumber, m.Identity.DateCode,
+			m.Specs.ConnectorType, strconv.Itoa(m.Specs.WavelengthNM), strconv.Itoa(m.Specs.BitrateMbps),
+			m.Specs.Encoding, strconv.Itoa(m.Specs.LinkLengthM),
+			strings.Join(m.Compliance, ";"), strconv.FormatBool(m.Checksums.Valid),
+			strconv.Itoa(len(m.Sources)), m.CreatedAt.Format(time.RFC3339), m.UpdatedAt.Format(time.RFC3339),
+			strings.Join(m.Tags, ";"), strings.Join(noteTexts(m.Notes), "\n"),
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func noteTexts(notes []Note) []string {
+	texts := make([]string, len(notes))
+	for i, n := range notes {
+		texts[i] = n.Text
+	}
+	return texts
+}
+
+// DocumentMetadata returns the metadata carried in a JSON or YAML profile
+// document, or nil if input is not a document or has none.
+func DocumentMetadata(input []byte) *Metadata {
+	trimmed := bytes.TrimSpace(input)
+	if !bytes.HasPrefix(trimmed, []byte("{")) {
+		if !bytes.Contains(trimmed, []byte(eeprom.DocumentFormat)) {
+			return nil
+		}
+		// Round-trip YAML through JSON so the json field tags apply
+		var v any
+		if err := yaml.Unmarshal(trimmed, &v); err != nil {
+			return nil
+		}
+		var err error
+		if trimmed, err = json.Marshal(v); err != nil {
+			return nil
+		}
+	}
+
+	var doc Document
+	if err := json.Unmarshal(trimmed, &doc); err != nil || doc.Format != eeprom.DocumentFormat {
+		return nil
+	}
+	return doc.Metadata
+}
@@ -0,0 +1,46 @@
+package store
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/vitaminmoo/sfpw-tool/internal/eeprom"
+)
+
+func TestExportRoundTrip(t *testing.T) {
+	// Malformed ASCII fields: DEL, C1 controls and 0xFF padding
+	data := testProfile("SN\x80\x85\xff\xff")
+	copy(data[20:36], "ACME\x7f          ")
+	copy(data[256:], []byte{0x55, 0x00, 0xf6, 0x00})
+	hash, err := ContentHash(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta := ExtractMetadata(data, hash)
+	meta.Notes = []Note{{Text: "pulled from sw-köln-01"}}
+
+	formats := map[string]string{
+		ExportJSON:     eeprom.FormatJSON,
+		ExportYAML:     eeprom.FormatYAML,
+		ExportIntelHex: eeprom.FormatIntelHex,
+		ExportEthtool:  eeprom.FormatEthtoolHex,
+	}
+	for export, format := range formats {
+		t.Run(export, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteProfile(&buf, export, data, meta); err != nil {
+				t.Fatalf("WriteProfile: %v", err)
+			}
+			got, gotFormat, err := eeprom.Decode(buf.Bytes())
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if gotFormat != format {
+				t.Errorf("format = %q, want %q", gotFormat, format)
+			}
+			if !bytes.Equal(got, data) {
+				t.Error("decoded data differs from the exported profile")
+			}
+		})
+	}
+}
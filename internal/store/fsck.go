@@ -0,0 +1,419 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Fsck issue kinds
+const (
+	IssueUnreadable      = "unreadable"       // Profile file cannot be read
+	IssueHashMismatch    = "hash-mismatch"    // Profile data does not hash to its filename
+	IssueMissingMetadata = "missing-metadata" // Profile without metadata
+	IssueMissingProfile  = "missing-profile"  // Metadata without profile
+	IssueBadMetadata     = "bad-metadata"     // Metadata cannot be parsed or names another hash
+	IssueStaleMetadata   = "stale-metadata"   // Parsed fields differ from the current parser
+	IssueMissingBlob     = "missing-blob"     // Variant blob missing or corrupt
+	IssueOrphanBlob      = "orphan-blob"      // Blob not referenced by any variant
+	IssueStaleIndex      = "stale-index"      // index.json does not match the metadata
+)
+
+// FsckIssue is one problem found by Fsck.
+type FsckIssue struct {
+	Kind   string
+	Hash   string
+	Detail string
+	Fixed  bool
+}
+
+// Fsck checks the store for consistency. Profiles must hash to their
+// filenames and pair up with their metadata, metadata must match what the
+// current parser derives, variant blobs must be intact and the index must
+// match the metadata. With repair, derived metadata is rewritten, metadata
+// is recreated for orphaned profiles and the index is rebuilt; other issues
+// are only reported (see Remove and GC).
+func (s *Store) Fsck(repair bool) ([]FsckIssue, error) {
+	unlock, err := s.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var issues []FsckIssue
+	add := func(kind, hash, detail string, fixed bool) {
+		issues = append(issues, FsckIssue{Kind: kind, Hash: hash, Detail: detail, Fixed: fixed})
+	}
+
+	profiles, err := s.profileHashes()
+	if err != nil {
+		return nil, err
+	}
+	metas, err := s.metadataHashes()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, hash := range profiles {
+		data, err := s.Get(hash)
+		if err != nil {
+			add(IssueUnreadable, hash, err.Error(), false)
+			continue
+		}
+		if actual, err := ContentHash(data); err != nil || actual != hash {
+			add(IssueHashMismatch, hash, fmt.Sprintf("data hashes to %s", ShortHash(actual)), false)
+			continue
+		}
+
+		if !metas[hash] {
+			fixed := false
+			if repair {
+				fixed = s.adoptProfile(hash, data) == nil
+			}
+			add(IssueMissingMetadata, hash, "profile has no metadata", fixed)
+			continue
+		}
+
+		meta, err := s.GetMetadata(hash)
+		if err != nil {
+			add(IssueBadMetadata, hash, err.Error(), false)
+			continue
+		}
+		if meta.ContentHash != hash {
+			add(IssueBadMetadata, hash, fmt.Sprintf("metadata names %s", ShortHash(meta.ContentHash)), false)
+			continue
+		}
+
+		if diffs := rederive(meta, data); len(diffs) > 0 {
+			fixed := false
+			if repair {
+				meta.UpdatedAt = time.Now()
+				fixed = s.commit(&journal{Hash: hash, Metadata: meta}) == nil
+			}
+			add(IssueStaleMetadata, hash, strings.Join(diffs, ", ")+" changed", fixed)
+		}
+
+		issues = append(issues, s.checkBlobs(meta, data, repair)...)
+	}
+
+	for hash := range metas {
+		if _, err := os.Stat(s.profilePath(hash)); os.IsNotExist(err) {
+			add(IssueMissingProfile, hash, "metadata has no profile", false)
+		}
+	}
+
+	if stale, err := s.indexStale(); err != nil {
+		return nil, err
+	} else if stale {
+		fixed := false
+		if repair {
+			_, err := s.rebuildIndex()
+			fixed = err == nil
+		}
+		add(IssueStaleIndex, "", "index.json does not match metadata", fixed)
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool { return issues[i].Hash < issues[j].Hash })
+	return issues, nil
+}
+
+// rederive updates the parsed fields of meta from data with the current
+// parser, returning the names of the fields that changed. User annotations,
+// sources and variants are kept.
+func rederive(meta *Metadata, data []byte) []string {
+	fresh := ExtractMetadata(data, meta.ContentHash)
+	if fresh == nil {
+		return nil
+	}
+
+	var changed []string
+	check := func(name string, cur, want any, set func()) {
+		// Compare as stored, so nil and empty slices are alike
+		a, _ := json.Marshal(cur)
+		b, _ := json.Marshal(want)
+		if string(a) != string(b) {
+			changed = append(changed, name)
+			set()
+		}
+	}
+	check("module_type", meta.ModuleType, fresh.ModuleType, func() { meta.ModuleType = fresh.ModuleType })
+	check("size", meta.Size, fresh.Size, func() { meta.Size = fresh.Size })
+	check("identity", meta.Identity, fresh.Identity, func() { meta.Identity = fresh.Identity })
+	check("specs", meta.Specs, fresh.Specs, func() { meta.Specs = fresh.Specs })
+	check("compliance", meta.Compliance, fresh.Compliance, func() { meta.Compliance = fresh.Compliance })
+	check("checksums", meta.Checksums, fresh.Checksums, func() { meta.Checksums = fresh.Checksums })
+	return changed
+}
+
+// adoptProfile recreates metadata for a profile whose metadata was lost.
+func (s *Store) adoptProfile(hash string, data []byte) error {
+	meta := ExtractMetadata(data, hash)
+	if meta == nil {
+		return fmt.Errorf("cannot parse profile")
+	}
+	seen := time.Now()
+	if info, err := os.Stat(s.profilePath(hash)); err == nil {
+		seen = info.ModTime()
+	}
+	meta.CreatedAt = seen
+	meta.Sources = []Source{{Timestamp: seen, Method: "fsck", Blob: BlobHash(data)}}
+	addVariant(meta, data, seen)
+	return s.commit(&journal{Hash: hash, Blobs: [][]byte{data}, Metadata: meta})
+}
+
+// checkBlobs verifies that every variant of a profile has an intact blob.
+// A missing primary blob can be restored from the profile data.
+func (s *Store) checkBlobs(meta *Metadata, primary []byte, repair bool) []FsckIssue {
+	var issues []FsckIssue
+	for _, v := range meta.Variants {
+		data, err := os.ReadFile(s.blobPath(meta.ContentHash, v.Hash))
+		if err == nil && BlobHash(data) == v.Hash {
+			continue
+		}
+		detail := "variant " + ShortHash(v.Hash) + " blob is missing"
+		if err == nil {
+			detail = "variant " + ShortHash(v.Hash) + " blob is corrupt"
+		}
+		fixed := false
+		if repair && BlobHash(primary) == v.Hash {
+			fixed = s.commit(&journal{Hash: meta.ContentHash, Blobs: [][]byte{primary}, Metadata: meta}) == nil
+		}
+		issues = append(issues, FsckIssue{Kind: IssueMissingBlob, Hash: meta.ContentHash, Detail: detail, Fixed: fixed})
+	}
+
+	orphans, _ := s.orphanBlobs(meta.ContentHash, meta)
+	for _, path := range orphans {
+		issues = append(issues, FsckIssue{Kind: IssueOrphanBlob, Hash: meta.ContentHash,
+			Detail: filepath.Base(path) + " is not a variant (run store gc)"})
+	}
+	return issues
+}
+
+// indexStale reports whether index.json differs from the entries derived
+// from the metadata files.
+func (s *Store) indexStale() (bool, error) {
+	data, err := os.ReadFile(s.indexPath)
+	if os.IsNotExist(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	var index Index
+	if err := json.Unmarshal(data, &index); err != nil || index.Version != indexVersion {
+		return true, nil
+	}
+
+	metas, err := s.metadataHashes()
+	if err != nil {
+		return false, err
+	}
+	if len(index.Profiles) != len(metas) {
+		return true, nil
+	}
+	for hash := range metas {
+		meta, err := s.GetMetadata(hash)
+		if err != nil {
+			continue
+		}
+		entry, ok := index.Profiles[hash]
+		if !ok {
+			return true, nil
+		}
+		// Round-trip through JSON so time and nil/empty slices compare alike
+		want, _ := json.Marshal(newIndexEntry(meta))
+		got, _ := json.Marshal(entry)
+		if string(want) != string(got) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Reindex regenerates index.json from the metadata files and returns the
+// number of profiles indexed.
+func (s *Store) Reindex() (int, error) {
+	unlock, err := s.lock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	index, err := s.rebuildIndex()
+	if err != nil {
+		return 0, err
+	}
+	return len(index.Profiles), nil
+}
+
+// Remove deletes a profile with its metadata, variant blobs and index entry.
+// Metadata goes first, so an interrupted removal leaves only profile data
+// without metadata, which GC removes with orphanProfiles.
+func (s *Store) Remove(hash string) error {
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	metaPath := filepath.Join(s.metadataDir, hashToFilename(hash)+".json")
+	if err := os.Remove(metaPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove metadata: %w", err)
+	}
+
+	index, err := s.loadIndex()
+	if err != nil {
+		return err
+	}
+	delete(index.Profiles, hash)
+	if err := s.saveIndex(index); err != nil {
+		return fmt.Errorf("failed to update index: %w", err)
+	}
+
+	if err := os.Remove(s.profilePath(hash)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove profile: %w", err)
+	}
+	if err := os.RemoveAll(filepath.Join(s.blobsDir, hashToFilename(hash))); err != nil {
+		return fmt.Errorf("failed to remove blobs: %w", err)
+	}
+	return nil
+}
+
+// GC removes files no profile refers to: blobs that are not a variant of
+// their profile, and leftover temporary files. Profile data without
+// metadata can still be adopted by Fsck with repair, so it is only removed,
+// together with its blobs, if orphanProfiles is set; otherwise its hashes
+// are returned as kept. With dryRun nothing is removed. Returns the paths
+// (relative to the store) that were, or would be, removed.
+func (s *Store) GC(dryRun, orphanProfiles bool) (removed, kept []string, err error) {
+	unlock, err := s.lock()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer unlock()
+
+	var garbage []string
+
+	metas, err := s.metadataHashes()
+	if err != nil {
+		return nil, nil, err
+	}
+	profiles, err := s.profileHashes()
+	if err != nil {
+		return nil, nil, err
+	}
+	orphans := make(map[string]bool)
+	for _, hash := range profiles {
+		if metas[hash] {
+			continue
+		}
+		if orphanProfiles {
+			garbage = append(garbage, s.profilePath(hash))
+		} else {
+			kept = append(kept, hash)
+			orphans[hash] = true
+		}
+	}
+
+	dirs, err := os.ReadDir(s.blobsDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, d := range dirs {
+		hash := "sha256:" + d.Name()
+		if orphans[hash] {
+			continue // Kept with its profile
+		}
+		if !metas[hash] {
+			garbage = append(garbage, filepath.Join(s.blobsDir, d.Name()))
+			continue
+		}
+		meta, err := s.GetMetadata(hash)
+		if err != nil {
+			continue
+		}
+		blobs, err := s.orphanBlobs(hash, meta)
+		if err != nil {
+			return nil, nil, err
+		}
+		garbage = append(garbage, blobs...)
+	}
+
+	for _, dir := range []string{s.baseDir, s.profilesDir, s.metadataDir} {
+		matches, _ := filepath.Glob(filepath.Join(dir, tempPrefix+"*"))
+		garbage = append(garbage, matches...)
+	}
+
+	removed = make([]string, 0, len(garbage))
+	for _, path := range garbage {
+		if !dryRun {
+			if err := os.RemoveAll(path); err != nil {
+				return removed, kept, err
+			}
+		}
+		rel, err := filepath.Rel(s.baseDir, path)
+		if err != nil {
+			rel = path
+		}
+		removed = append(removed, rel)
+	}
+	return removed, kept, nil
+}
+
+// orphanBlobs returns the blob files under a profile that are not variants.
+// Blobs of profiles from before variants were tracked are all orphans.
+func (s *Store) orphanBlobs(hash string, meta *Metadata) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(s.blobsDir, hashToFilename(hash), "*.bin"))
+	if err != nil {
+		return nil, err
+	}
+	var orphans []string
+	for _, f := range files {
+		blob := "sha256:" + strings.TrimSuffix(filepath.Base(f), ".bin")
+		known := false
+		for _, v := range meta.Variants {
+			if v.Hash == blob {
+				known = true
+				break
+			}
+		}
+		if !known {
+			orphans = append(orphans, f)
+		}
+	}
+	return orphans, nil
+}
+
+func (s *Store) profilePath(hash string) string {
+	return filepath.Join(s.profilesDir, hashToFilename(hash)+".bin")
+}
+
+// profileHashes lists the hashes named by the profile data files.
+func (s *Store) profileHashes() ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(s.profilesDir, "*.bin"))
+	if err != nil {
+		return nil, err
+	}
+	hashes := make([]string, len(files))
+	for i, f := range files {
+		hashes[i] = "sha256:" + strings.TrimSuffix(filepath.Base(f), ".bin")
+	}
+	return hashes, nil
+}
+
+// metadataHashes returns the set of hashes named by the metadata files.
+func (s *Store) metadataHashes() (map[string]bool, error) {
+	files, err := filepath.Glob(filepath.Join(s.metadataDir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	hashes := make(map[string]bool, len(files))
+	for _, f := range files {
+		hashes["sha256:"+strings.TrimSuffix(filepath.Base(f), ".json")] = true
+	}
+	return hashes, nil
+}
@@ -0,0 +1,36 @@
+package store
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestFsckUnreadableProfile(t *testing.T) {
+	s := openTestStore(t)
+	var hashes []string
+	for _, serial := range []string{"SN0001", "SN0002"} {
+		hash, _, err := s.Import(testProfile(serial), Source{Method: "import", Timestamp: time.Now()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		hashes = append(hashes, hash)
+	}
+
+	// A directory in place of the profile file cannot be read
+	path := s.profilePath(hashes[0])
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(path, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	issues, err := s.Fsck(false)
+	if err != nil {
+		t.Fatalf("Fsck: %v", err)
+	}
+	if len(issues) != 1 || issues[0].Kind != IssueUnreadable || issues[0].Hash != hashes[0] {
+		t.Fatalf("issues = %+v, want one %s issue for %s", issues, IssueUnreadable, ShortHash(hashes[0]))
+	}
+}
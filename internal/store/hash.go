@@ -45,6 +45,14 @@ func ContentHash(data []byte) (string, error) {
 	return "sha256:" + hex.EncodeToString(hash[:]), nil
 }
 
+// BlobHash computes the hash of the complete EEPROM data. It identifies an
+// exact dump (variant) of a profile, including thresholds, calibration and
+// vendor-specific bytes that ContentHash ignores.
+func BlobHash(data []byte) string {
+	hash := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(hash[:])
+}
+
 // ShortHash returns a shortened version of the hash for display purposes.
 func ShortHash(fullHash string) string {
 	// Remove "sha256:" prefix and take first 12 chars
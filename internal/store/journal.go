@@ -0,0 +1,188 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// tempPrefix marks in-progress atomic writes; recoverJournal removes
+// leftovers.
+const tempPrefix = ".tmp-"
+
+// journal records a store update before any of its files are written, so an
+// update interrupted by a crash can be completed on the next Open. Every step
+// of applying a journal is idempotent.
+type journal struct {
+	Hash     string    `json:"hash"`
+	Profile  []byte    `json:"profile,omitempty"` // Primary dump of a new profile
+	Blobs    [][]byte  `json:"blobs,omitempty"`   // New variant blobs
+	Metadata *Metadata `json:"metadata"`
+	Started  time.Time `json:"started"`
+}
+
+func (s *Store) journalPath() string {
+	return filepath.Join(s.baseDir, "journal.json")
+}
+
+// commit journals an update, applies it and clears the journal. The caller
+// must hold the store lock.
+func (s *Store) commit(j *journal) error {
+	j.Started = time.Now()
+	data, err := json.Marshal(j)
+	if err != nil {
+		return fmt.Errorf("failed to marshal journal: %w", err)
+	}
+	if err := writeFileAtomic(s.journalPath(), data, 0644); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+
+	if err := s.apply(j); err != nil {
+		return err
+	}
+	return os.Remove(s.journalPath())
+}
+
+// apply writes the profile, blobs, metadata and index entry of a journal.
+func (s *Store) apply(j *journal) error {
+	if j.Profile != nil {
+		profilePath := filepath.Join(s.profilesDir, hashToFilename(j.Hash)+".bin")
+		if err := writeFileAtomic(profilePath, j.Profile, 0644); err != nil {
+			return fmt.Errorf("failed to write profile: %w", err)
+		}
+	}
+
+	for _, data := range j.Blobs {
+		path := s.blobPath(j.Hash, BlobHash(data))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("failed to create blob dir: %w", err)
+		}
+		if err := writeFileAtomic(path, data, 0644); err != nil {
+			return fmt.Errorf("failed to write blob: %w", err)
+		}
+	}
+
+	metaPath := filepath.Join(s.metadataDir, hashToFilename(j.Hash)+".json")
+	metaJSON, err := json.MarshalIndent(j.Metadata, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	if err := writeFileAtomic(metaPath, metaJSON, 0644); err != nil {
+		return fmt.Errorf("failed to write metadata: %w", err)
+	}
+
+	if err := s.updateIndex(j.Hash, j.Metadata); err != nil {
+		return fmt.Errorf("failed to update index: %w", err)
+	}
+	return nil
+}
+
+// valid reports whether a journal is complete enough to be applied.
+func (j *journal) valid() bool {
+	if j.Metadata == nil || j.Metadata.ContentHash != j.Hash {
+		return false
+	}
+	if j.Profile != nil {
+		if hash, err := ContentHash(j.Profile); err != nil || hash != j.Hash {
+			return false
+		}
+	}
+	return true
+}
+
+// recoverJournal completes an interrupted update, or rolls it back if its
+// journal cannot be applied, and removes leftover temporary files.
+func (s *Store) recoverJournal() error {
+	if _, err := os.Stat(s.journalPath()); os.IsNotExist(err) {
+		return nil
+	}
+
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Another process may have recovered while we waited for the lock
+	data, err := os.ReadFile(s.journalPath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var j journal
+	if err := json.Unmarshal(data, &j); err == nil && j.valid() {
+		fmt.Fprintf(os.Stderr, "Store: completing interrupted update of %s\n", ShortHash(j.Hash))
+		if err := s.apply(&j); err != nil {
+			return err
+		}
+	} else {
+		fmt.Fprintf(os.Stderr, "Store: rolling back incomplete update\n")
+		s.rollback(&j)
+	}
+
+	s.removeTempFiles()
+	return os.Remove(s.journalPath())
+}
+
+// rollback removes what a partially applied new-profile import may have
+// written. Existing profiles are left alone: their metadata is only ever
+// replaced atomically.
+func (s *Store) rollback(j *journal) {
+	if j.Hash == "" {
+		return
+	}
+	metaPath := filepath.Join(s.metadataDir, hashToFilename(j.Hash)+".json")
+	if _, err := os.Stat(metaPath); os.IsNotExist(err) {
+		os.Remove(filepath.Join(s.profilesDir, hashToFilename(j.Hash)+".bin"))
+		os.RemoveAll(filepath.Join(s.blobsDir, hashToFilename(j.Hash)))
+	}
+}
+
+func (s *Store) removeTempFiles() {
+	for _, pattern := range []string{
+		filepath.Join(s.baseDir, tempPrefix+"*"),
+		filepath.Join(s.profilesDir, tempPrefix+"*"),
+		filepath.Join(s.metadataDir, tempPrefix+"*"),
+		filepath.Join(s.blobsDir, "*", tempPrefix+"*"),
+	} {
+		matches, _ := filepath.Glob(pattern)
+		for _, m := range matches {
+			os.Remove(m)
+		}
+	}
+}
+
+// writeFileAtomic writes data to a temporary file in the same directory and
+// renames it over path, so readers never see a partially written file.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir, name := filepath.Split(path)
+	f, err := os.CreateTemp(dir, tempPrefix+strings.TrimPrefix(name, ".")+"-*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp, perm)
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
@@ -0,0 +1,25 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// lock takes an exclusive advisory lock on the store directory, blocking
+// until it is available. Every writer holds it for the duration of an
+// update; readers rely on atomic renames instead.
+func (s *Store) lock() (func(), error) {
+	f, err := os.OpenFile(filepath.Join(s.baseDir, ".lock"), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open store lock: %w", err)
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to lock store: %w", err)
+	}
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
@@ -1,8 +1,11 @@
 package store
 
 import (
+	"fmt"
 	"strings"
 	"time"
+
+	"github.com/vitaminmoo/sfpw-tool/internal/eeprom"
 )
 
 // Metadata contains parsed information about a module profile.
@@ -13,6 +16,9 @@ type Metadata struct {
 	Identity    Identity   `json:"identity"`
 	Specs       Specs      `json:"specs,omitempty"`
 	Compliance  []string   `json:"compliance,omitempty"`
+	Tags        []string   `json:"tags,omitempty"`
+	Notes       []Note     `json:"notes,omitempty"`
+	Variants    []Variant  `json:"variants,omitempty"` // Distinct full dumps, primary first
 	Checksums   Checksums  `json:"checksums,omitempty"`
 	Sources     []Source   `json:"sources"`
 	CreatedAt   time.Time  `json:"created_at"`
@@ -49,8 +55,165 @@ type Checksums struct {
 type Source struct {
 	DeviceMAC string    `json:"device_mac,omitempty"`
 	Timestamp time.Time `json:"timestamp"`
-	Method    string    `json:"method"` // "module_read", "snapshot_read", "support_dump", "import"
+	Method    string    `json:"method"` // "module_read", "snapshot_read", "support_dump", "import", "clone_batch", "snapshot_write"
 	Filename  string    `json:"filename,omitempty"`
+	Format    string    `json:"format,omitempty"` // Dump format for imports, e.g. "ethtool-hex"
+	Verify    string    `json:"verify,omitempty"` // Write verification outcome: "exact", "partial", "unchanged"
+	DiffPages []string  `json:"diff_pages,omitempty"` // Pages that differed from the intended image
+	Blob      string    `json:"blob,omitempty"` // BlobHash of the exact data from this source
+}
+
+// Note is a free-text user annotation on a profile.
+type Note struct {
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AddTags adds tags not already present (compared case-insensitively) and
+// returns the ones added.
+func (m *Metadata) AddTags(tags ...string) []string {
+	var added []string
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || hasTag(m.Tags, tag) {
+			continue
+		}
+		m.Tags = append(m.Tags, tag)
+		added = append(added, tag)
+	}
+	return added
+}
+
+// RemoveTags removes tags (compared case-insensitively) and returns the
+// ones removed.
+func (m *Metadata) RemoveTags(tags ...string) []string {
+	var removed, kept []string
+	for _, t := range m.Tags {
+		if hasTag(tags, t) {
+			removed = append(removed, t)
+		} else {
+			kept = append(kept, t)
+		}
+	}
+	m.Tags = kept
+	return removed
+}
+
+// AddNote appends a note unless an identical one is already present.
+func (m *Metadata) AddNote(note Note) bool {
+	for _, n := range m.Notes {
+		if n.Text == note.Text && n.CreatedAt.Equal(note.CreatedAt) {
+			return false
+		}
+	}
+	m.Notes = append(m.Notes, note)
+	return true
+}
+
+// MergeAnnotations adds the tags and notes of other, as carried in an
+// exported profile document.
+func (m *Metadata) MergeAnnotations(other *Metadata) bool {
+	changed := len(m.AddTags(other.Tags...)) > 0
+	for _, n := range other.Notes {
+		if m.AddNote(n) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// Merge folds another copy of the same profile into m: sources and variants
+// not already present, tags, notes and the earlier creation time. Parsed
+// fields that disagree are kept from m and reported as conflicts.
+func (m *Metadata) Merge(other *Metadata) (changed bool, conflicts []string) {
+	for _, src := range other.Sources {
+		if !m.hasSource(src) {
+			m.Sources = append(m.Sources, src)
+			changed = true
+		}
+	}
+	for _, v := range other.Variants {
+		if !m.hasVariant(v.Hash) {
+			m.Variants = append(m.Variants, v)
+			changed = true
+		}
+	}
+	if m.MergeAnnotations(other) {
+		changed = true
+	}
+	if !other.CreatedAt.IsZero() && other.CreatedAt.Before(m.CreatedAt) {
+		m.CreatedAt = other.CreatedAt
+		changed = true
+	}
+
+	if len(m.Variants) > 0 && len(other.Variants) > 0 && m.Variants[0].Hash != other.Variants[0].Hash {
+		conflicts = append(conflicts, fmt.Sprintf("primary dump differs (kept %s, added %s as a variant)",
+			ShortHash(m.Variants[0].Hash), ShortHash(other.Variants[0].Hash)))
+	}
+	if m.Identity != other.Identity {
+		conflicts = append(conflicts, "identity fields differ")
+	}
+	if m.Specs != other.Specs || m.Checksums != other.Checksums {
+		conflicts = append(conflicts, "parsed specs differ (run store fsck)")
+	}
+	return changed, conflicts
+}
+
+// hasSource reports whether an identical source is already recorded.
+func (m *Metadata) hasSource(src Source) bool {
+	for _, s := range m.Sources {
+		if s.Timestamp.Equal(src.Timestamp) && s.Method == src.Method && s.DeviceMAC == src.DeviceMAC &&
+			s.Filename == src.Filename && s.Blob == src.Blob {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Metadata) hasVariant(blob string) bool {
+	for _, v := range m.Variants {
+		if v.Hash == blob {
+			return true
+		}
+	}
+	return false
+}
+
+// Variant is one distinct full dump of a profile.
+type Variant struct {
+	Hash      string    `json:"hash"` // BlobHash of the data
+	Size      int       `json:"size"`
+	FirstSeen time.Time `json:"first_seen"`
+}
+
+// ResolveVariant finds a variant by full hash or hash prefix (with or
+// without the "sha256:" prefix).
+func (m *Metadata) ResolveVariant(ref string) (*Variant, error) {
+	ref = strings.TrimPrefix(ref, "sha256:")
+	var found *Variant
+	for i, v := range m.Variants {
+		if strings.HasPrefix(hashToFilename(v.Hash), ref) {
+			if found != nil {
+				return nil, fmt.Errorf("variant %s is ambiguous", ref)
+			}
+			found = &m.Variants[i]
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("variant not found: %s", ref)
+	}
+	return found, nil
+}
+
+// SourceCount returns the number of sources that produced a variant.
+func (m *Metadata) SourceCount(blob string) int {
+	n := 0
+	for _, src := range m.Sources {
+		if src.Blob == blob {
+			n++
+		}
+	}
+	return n
 }
 
 // ExtractMetadata parses EEPROM data and extracts metadata.
@@ -76,6 +239,7 @@ func ExtractMetadata(data []byte, hash string) *Metadata {
 		ContentHash: hash,
 		ModuleType:  moduleType,
 		Size:        len(data),
+		Compliance:  eeprom.ComplianceNames(data),
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
@@ -94,7 +258,7 @@ func ExtractMetadata(data []byte, hash string) *Metadata {
 		// Extract specs
 		meta.Specs = Specs{
 			ConnectorType: connectorType(data[2]),
-			BitrateMbps:   int(data[12]) * 100,
+			BitrateMbps:   eeprom.NominalBitrate(data, false),
 			Encoding:      encodingType(data[11]),
 		}
 
@@ -104,20 +268,40 @@ func ExtractMetadata(data []byte, hash string) *Metadata {
 		}
 
 		// Calculate checksums
-		if len(data) >= 64 {
-			ccBase := calculateChecksum(data[0:63])
-			meta.Checksums.CCBase = formatHex(ccBase)
-			meta.Checksums.Valid = (ccBase == data[63])
-		}
+		ccBase := calculateChecksum(data[0:63])
+		ccExt := calculateChecksum(data[64:95])
+		meta.Checksums.CCBase = formatHex(ccBase)
+		meta.Checksums.CCExt = formatHex(ccExt)
+		meta.Checksums.Valid = ccBase == data[63] && ccExt == data[95]
 	} else if (identifier == 0x0c || identifier == 0x0d || identifier == 0x11) && len(data) >= 192 {
 		// QSFP layout - identity in upper memory (bytes 128+)
 		meta.Identity = Identity{
 			VendorName:   strings.TrimSpace(string(data[148:164])),
+			VendorOUI:    formatOUI(data[165:168]),
 			PartNumber:   strings.TrimSpace(string(data[168:184])),
 			Revision:     strings.TrimSpace(string(data[184:186])),
 			SerialNumber: strings.TrimSpace(string(data[196:212])),
 			DateCode:     strings.TrimSpace(string(data[212:220])),
 		}
+
+		meta.Specs = Specs{
+			ConnectorType: connectorType(data[130]),
+			BitrateMbps:   eeprom.NominalBitrate(data, true),
+			Encoding:      encodingType(data[139]),
+		}
+
+		// Wavelength (bytes 186-187, units of 0.05 nm)
+		if data[186] != 0 || data[187] != 0 {
+			meta.Specs.WavelengthNM = (int(data[186])<<8 | int(data[187])) / 20
+		}
+
+		if len(data) >= 224 {
+			ccBase := calculateChecksum(data[128:191])
+			ccExt := calculateChecksum(data[192:223])
+			meta.Checksums.CCBase = formatHex(ccBase)
+			meta.Checksums.CCExt = formatHex(ccExt)
+			meta.Checksums.Valid = ccBase == data[191] && ccExt == data[223]
+		}
 	}
 
 	return meta
@@ -0,0 +1,372 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Query is a parsed store search expression, for example:
+//
+//	vendor~"FS" and wavelength=1310 and (type=SFP or type=SFP+) and bitrate>=10000
+//
+// Comparisons are field op value, where op is one of = != ~ !~ < <= > >=.
+// String matching is case-insensitive and ~ matches substrings. List fields
+// (compliance, method, device, tag, note) match if any element matches. Terms
+// combine with and, or, not and parentheses.
+type Query struct {
+	root queryNode
+}
+
+type queryNode interface {
+	match(e *IndexEntry, hash string) bool
+}
+
+type andNode struct{ left, right queryNode }
+type orNode struct{ left, right queryNode }
+type notNode struct{ inner queryNode }
+
+type compareNode struct {
+	field string
+	op    string
+	value string
+}
+
+func (n andNode) match(e *IndexEntry, hash string) bool {
+	return n.left.match(e, hash) && n.right.match(e, hash)
+}
+
+func (n orNode) match(e *IndexEntry, hash string) bool {
+	return n.left.match(e, hash) || n.right.match(e, hash)
+}
+
+func (n notNode) match(e *IndexEntry, hash string) bool {
+	return !n.inner.match(e, hash)
+}
+
+// queryField extracts the values of a searchable field from an index entry.
+type queryField struct {
+	numeric bool
+	values  func(e *IndexEntry, hash string) []string
+}
+
+func one(s string) []string { return []string{s} }
+
+var queryFields = map[string]queryField{
+	"vendor":     {values: func(e *IndexEntry, _ string) []string { return one(e.VendorName) }},
+	"pn":         {values: func(e *IndexEntry, _ string) []string { return one(e.PartNumber) }},
+	"sn":         {values: func(e *IndexEntry, _ string) []string { return one(e.SerialNumber) }},
+	"rev":        {values: func(e *IndexEntry, _ string) []string { return one(e.Revision) }},
+	"oui":        {values: func(e *IndexEntry, _ string) []string { return one(e.VendorOUI) }},
+	"date":       {values: func(e *IndexEntry, _ string) []string { return one(e.DateCode) }},
+	"type":       {values: func(e *IndexEntry, _ string) []string { return one(e.ModuleType) }},
+	"connector":  {values: func(e *IndexEntry, _ string) []string { return one(e.ConnectorType) }},
+	"compliance": {values: func(e *IndexEntry, _ string) []string { return e.Compliance }},
+	"method":     {values: func(e *IndexEntry, _ string) []string { return e.Methods }},
+	"device":     {values: func(e *IndexEntry, _ string) []string { return e.Devices }},
+	"tag":        {values: func(e *IndexEntry, _ string) []string { return e.Tags }},
+	"note":       {values: func(e *IndexEntry, _ string) []string { return e.Notes }},
+	"hash":       {values: func(_ *IndexEntry, hash string) []string { return one(hashToFilename(hash)) }},
+	"checksum": {values: func(e *IndexEntry, _ string) []string {
+		if e.ChecksumValid {
+			return one("valid")
+		}
+		return one("invalid")
+	}},
+	"wavelength": {numeric: true, values: func(e *IndexEntry, _ string) []string { return one(strconv.Itoa(e.WavelengthNM)) }},
+	"bitrate":    {numeric: true, values: func(e *IndexEntry, _ string) []string { return one(strconv.Itoa(e.BitrateMbps)) }},
+}
+
+var queryFieldAliases = map[string]string{
+	"part":          "pn",
+	"part_number":   "pn",
+	"serial":        "sn",
+	"serial_number": "sn",
+	"revision":      "rev",
+	"vendor_oui":    "oui",
+	"date_code":     "date",
+	"module_type":   "type",
+	"wavelength_nm": "wavelength",
+	"bitrate_mbps":  "bitrate",
+	"source":        "method",
+	"mac":           "device",
+	"tags":          "tag",
+	"notes":         "note",
+}
+
+// queryFieldNames returns the names of the searchable fields, sorted.
+func queryFieldNames() []string {
+	names := make([]string, 0, len(queryFields))
+	for name := range queryFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (n compareNode) match(e *IndexEntry, hash string) bool {
+	f := queryFields[n.field]
+	values := f.values(e, hash)
+
+	// Negated operators match when no value matches the positive form
+	switch n.op {
+	case "!=":
+		return !compareNode{n.field, "=", n.value}.match(e, hash)
+	case "!~":
+		return !compareNode{n.field, "~", n.value}.match(e, hash)
+	}
+
+	for _, v := range values {
+		if compareValue(v, n.op, n.value, f.numeric) {
+			return true
+		}
+	}
+	return false
+}
+
+func compareValue(v, op, want string, numeric bool) bool {
+	if numeric {
+		a, errA := strconv.Atoi(v)
+		b, errB := strconv.Atoi(want)
+		if errA == nil && errB == nil {
+			switch op {
+			case "=":
+				return a == b
+			case "~":
+				return strings.Contains(v, want)
+			case "<":
+				return a < b
+			case "<=":
+				return a <= b
+			case ">":
+				return a > b
+			case ">=":
+				return a >= b
+			}
+			return false
+		}
+	}
+
+	v, want = strings.ToLower(v), strings.ToLower(want)
+	switch op {
+	case "=":
+		return v == want
+	case "~":
+		return strings.Contains(v, want)
+	case "<":
+		return v < want
+	case "<=":
+		return v <= want
+	case ">":
+		return v > want
+	case ">=":
+		return v >= want
+	}
+	return false
+}
+
+// Match reports whether a profile's index entry satisfies the query.
+func (q *Query) Match(hash string, e IndexEntry) bool {
+	if q == nil || q.root == nil {
+		return true
+	}
+	return q.root.match(&e, hash)
+}
+
+// ParseQuery parses a search expression. An empty expression matches everything.
+func ParseQuery(expr string) (*Query, error) {
+	tokens, err := lexQuery(expr)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return &Query{}, nil
+	}
+
+	p := &queryParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+	return &Query{root: root}, nil
+}
+
+type tokenKind int
+
+const (
+	tokWord tokenKind = iota
+	tokString
+	tokOp
+	tokLParen
+	tokRParen
+)
+
+type queryToken struct {
+	kind tokenKind
+	text string
+}
+
+func isOpChar(r rune) bool {
+	return strings.ContainsRune("=!~<>", r)
+}
+
+func lexQuery(expr string) ([]queryToken, error) {
+	var tokens []queryToken
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, queryToken{tokLParen, "("})
+			i++
+		case r == ')':
+			tokens = append(tokens, queryToken{tokRParen, ")"})
+			i++
+		case r == '"' || r == '\'':
+			end := i + 1
+			var sb strings.Builder
+			for ; end < len(runes) && runes[end] != r; end++ {
+				if runes[end] == '\\' && end+1 < len(runes) {
+					end++
+				}
+				sb.WriteRune(runes[end])
+			}
+			if end >= len(runes) {
+				return nil, fmt.Errorf("unterminated string starting at position %d", i)
+			}
+			tokens = append(tokens, queryToken{tokString, sb.String()})
+			i = end + 1
+		case isOpChar(r):
+			end := i
+			for end < len(runes) && isOpChar(runes[end]) {
+				end++
+			}
+			op := string(runes[i:end])
+			switch op {
+			case "=", "==", "!=", "~", "!~", "<", "<=", ">", ">=":
+			default:
+				return nil, fmt.Errorf("unknown operator %q", op)
+			}
+			if op == "==" {
+				op = "="
+			}
+			tokens = append(tokens, queryToken{tokOp, op})
+			i = end
+		default:
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) && !isOpChar(runes[end]) &&
+				runes[end] != '(' && runes[end] != ')' && runes[end] != '"' && runes[end] != '\'' {
+				end++
+			}
+			tokens = append(tokens, queryToken{tokWord, string(runes[i:end])})
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+}
+
+func (p *queryParser) peekKeyword(kw string) bool {
+	return p.pos < len(p.tokens) && p.tokens[p.pos].kind == tokWord && strings.EqualFold(p.tokens[p.pos].text, kw)
+}
+
+func (p *queryParser) parseOr() (queryNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("or") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseAnd() (queryNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("and") {
+		p.pos++
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseNot() (queryNode, error) {
+	if p.peekKeyword("not") {
+		p.pos++
+		inner, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{inner}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *queryParser) parsePrimary() (queryNode, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("unexpected end of query")
+	}
+
+	tok := p.tokens[p.pos]
+	if tok.kind == tokLParen {
+		p.pos++
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.pos >= len(p.tokens) || p.tokens[p.pos].kind != tokRParen {
+			return nil, fmt.Errorf("missing closing parenthesis")
+		}
+		p.pos++
+		return node, nil
+	}
+
+	if tok.kind != tokWord {
+		return nil, fmt.Errorf("expected field name, got %q", tok.text)
+	}
+	field := strings.ToLower(tok.text)
+	if alias, ok := queryFieldAliases[field]; ok {
+		field = alias
+	}
+	if _, ok := queryFields[field]; !ok {
+		return nil, fmt.Errorf("unknown field %q (fields: %s)", tok.text, strings.Join(queryFieldNames(), ", "))
+	}
+	p.pos++
+
+	if p.pos >= len(p.tokens) || p.tokens[p.pos].kind != tokOp {
+		return nil, fmt.Errorf("expected operator after %q", tok.text)
+	}
+	op := p.tokens[p.pos].text
+	p.pos++
+
+	if p.pos >= len(p.tokens) || (p.tokens[p.pos].kind != tokWord && p.tokens[p.pos].kind != tokString) {
+		return nil, fmt.Errorf("expected value after %s%s", tok.text, op)
+	}
+	value := p.tokens[p.pos].text
+	p.pos++
+
+	return compareNode{field: field, op: op, value: value}, nil
+}
@@ -0,0 +1,288 @@
+package store
+
+import (
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Report output formats
+const (
+	ReportTable    = "table"
+	ReportCSV      = "csv"
+	ReportMarkdown = "markdown"
+	ReportHTML     = "html"
+)
+
+// ReportFilter selects the profiles included in a report. Zero fields match
+// everything. Since, Until and DeviceMAC must all match the same source.
+type ReportFilter struct {
+	Since     time.Time
+	Until     time.Time
+	DeviceMAC string
+	Tag       string
+}
+
+// Match reports whether a profile passes the filter.
+func (f ReportFilter) Match(m *Metadata) bool {
+	if f.Tag != "" && !hasTag(m.Tags, f.Tag) {
+		return false
+	}
+	if f.Since.IsZero() && f.Until.IsZero() && f.DeviceMAC == "" {
+		return true
+	}
+	for _, src := range m.Sources {
+		if !f.Since.IsZero() && src.Timestamp.Before(f.Since) {
+			continue
+		}
+		if !f.Until.IsZero() && !src.Timestamp.Before(f.Until) {
+			continue
+		}
+		if f.DeviceMAC != "" && !strings.EqualFold(normalizeMAC(src.DeviceMAC), normalizeMAC(f.DeviceMAC)) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+func normalizeMAC(mac string) string {
+	return strings.NewReplacer(":", "", "-", "").Replace(mac)
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReportRow is one group of profiles sharing vendor, part number, type,
+// wavelength, bitrate and connector.
+type ReportRow struct {
+	Vendor       string
+	PartNumber   string
+	ModuleType   string
+	WavelengthNM int
+	BitrateMbps  int
+	Connector    string
+	Profiles     int
+	Serials      int
+
+	// InvalidSerials lists the serials (or short hashes, for profiles
+	// without one) of the group's profiles with invalid checksums.
+	InvalidSerials []string
+}
+
+// Report is an inventory summary of the profile store.
+type Report struct {
+	Rows             []ReportRow
+	InvalidChecksums []*Metadata
+	Profiles         int
+	Serials          int
+	Generated        time.Time
+}
+
+// BuildReport aggregates the profiles that pass the filter.
+func BuildReport(metas []*Metadata, filter ReportFilter) *Report {
+	type key struct {
+		vendor, pn, moduleType, connector string
+		wavelength, bitrate               int
+	}
+	groups := make(map[key]*ReportRow)
+	groupSerials := make(map[key]map[string]bool)
+	allSerials := make(map[string]bool)
+
+	r := &Report{Generated: time.Now()}
+	for _, m := range metas {
+		if m == nil || !filter.Match(m) {
+			continue
+		}
+		r.Profiles++
+
+		k := key{m.Identity.VendorName, m.Identity.PartNumber, m.ModuleType, m.Specs.ConnectorType, m.Specs.WavelengthNM, m.Specs.BitrateMbps}
+		row, ok := groups[k]
+		if !ok {
+			row = &ReportRow{
+				Vendor:       k.vendor,
+				PartNumber:   k.pn,
+				ModuleType:   k.moduleType,
+				WavelengthNM: k.wavelength,
+				BitrateMbps:  k.bitrate,
+				Connector:    k.connector,
+			}
+			groups[k] = row
+			groupSerials[k] = make(map[string]bool)
+		}
+		row.Profiles++
+
+		serial := m.Identity.VendorName + "\x00" + m.Identity.SerialNumber
+		if m.Identity.SerialNumber != "" {
+			groupSerials[k][serial] = true
+			allSerials[serial] = true
+		}
+
+		if m.Checksums.CCBase != "" && !m.Checksums.Valid {
+			r.InvalidChecksums = append(r.InvalidChecksums, m)
+			id := strings.Trim(m.Identity.SerialNumber, " \x00")
+			if id == "" {
+				id = ShortHash(m.ContentHash)
+			}
+			row.InvalidSerials = append(row.InvalidSerials, id)
+		}
+	}
+
+	for k, row := range groups {
+		row.Serials = len(groupSerials[k])
+		r.Rows = append(r.Rows, *row)
+	}
+	r.Serials = len(allSerials)
+
+	sort.Slice(r.Rows, func(i, j int) bool {
+		a, b := r.Rows[i], r.Rows[j]
+		if a.Serials != b.Serials {
+			return a.Serials > b.Serials
+		}
+		if a.Vendor != b.Vendor {
+			return a.Vendor < b.Vendor
+		}
+		return a.PartNumber < b.PartNumber
+	})
+	return r
+}
+
+var reportHeader = []string{"Vendor", "Part Number", "Type", "Wavelength", "Bitrate", "Connector", "Profiles", "Serials"}
+
+func (row ReportRow) fields() []string {
+	wavelength := ""
+	if row.WavelengthNM > 0 {
+		wavelength = fmt.Sprintf("%dnm", row.WavelengthNM)
+	}
+	bitrate := ""
+	if row.BitrateMbps > 0 {
+		bitrate = fmt.Sprintf("%dMbps", row.BitrateMbps)
+	}
+	return []string{
+		row.Vendor, row.PartNumber, row.ModuleType, wavelength, bitrate, row.Connector,
+		strconv.Itoa(row.Profiles), strconv.Itoa(row.Serials),
+	}
+}
+
+// Write renders the report in the given format.
+func (r *Report) Write(w io.Writer, format string) error {
+	switch format {
+	case ReportTable:
+		return r.writeTable(w)
+	case ReportCSV:
+		return r.writeCSV(w)
+	case ReportMarkdown:
+		return r.writeMarkdown(w)
+	case ReportHTML:
+		return reportTemplate.Execute(w, r)
+	default:
+		return fmt.Errorf("unknown report format: %s", format)
+	}
+}
+
+func (r *Report) writeTable(w io.Writer) error {
+	fmt.Fprintf(w, "%d profile(s), %d distinct serial(s)\n\n", r.Profiles, r.Serials)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(reportHeader, "\t"))
+	for _, row := range r.Rows {
+		fmt.Fprintln(tw, strings.Join(row.fields(), "\t"))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if len(r.InvalidChecksums) > 0 {
+		fmt.Fprintf(w, "\nInvalid checksums (%d):\n", len(r.InvalidChecksums))
+		for _, m := range r.InvalidChecksums {
+			fmt.Fprintf(w, "  %-12s  %-16s  %-16s  S/N %s\n", ShortHash(m.ContentHash),
+				m.Identity.VendorName, m.Identity.PartNumber, m.Identity.SerialNumber)
+		}
+	}
+	return nil
+}
+
+// writeCSV writes one row per group. CSV has no room for a second table, so
+// the invalid checksums are carried in an extra column.
+func (r *Report) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write(append(reportHeader, "Invalid Checksum Serials"))
+	for _, row := range r.Rows {
+		cw.Write(append(row.fields(), strings.Join(row.InvalidSerials, ";")))
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func (r *Report) writeMarkdown(w io.Writer) error {
+	fmt.Fprintf(w, "# Module Inventory\n\n%d profile(s), %d distinct serial(s). Generated %s.\n\n",
+		r.Profiles, r.Serials, r.Generated.Format("2006-01-02 15:04"))
+
+	fmt.Fprintf(w, "| %s |\n", strings.Join(reportHeader, " | "))
+	fmt.Fprintf(w, "|%s\n", strings.Repeat(" --- |", len(reportHeader)))
+	for _, row := range r.Rows {
+		fields := row.fields()
+		for i, f := range fields {
+			fields[i] = strings.ReplaceAll(f, "|", `\|`)
+		}
+		fmt.Fprintf(w, "| %s |\n", strings.Join(fields, " | "))
+	}
+
+	if len(r.InvalidChecksums) > 0 {
+		fmt.Fprintf(w, "\n## Invalid Checksums\n\n| Hash | Vendor | Part Number | Serial |\n| --- | --- | --- | --- |\n")
+		for _, m := range r.InvalidChecksums {
+			fmt.Fprintf(w, "| %s | %s | %s | %s |\n", ShortHash(m.ContentHash),
+				m.Identity.VendorName, m.Identity.PartNumber, m.Identity.SerialNumber)
+		}
+	}
+	return nil
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"short": ShortHash,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Module Inventory</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+th { background: #eee; }
+td.num { text-align: right; }
+</style>
+</head>
+<body>
+<h1>Module Inventory</h1>
+<p>{{.Profiles}} profile(s), {{.Serials}} distinct serial(s). Generated {{.Generated.Format "2006-01-02 15:04"}}.</p>
+<table>
+<tr><th>Vendor</th><th>Part Number</th><th>Type</th><th>Wavelength</th><th>Bitrate</th><th>Connector</th><th>Profiles</th><th>Serials</th></tr>
+{{- range .Rows}}
+<tr><td>{{.Vendor}}</td><td>{{.PartNumber}}</td><td>{{.ModuleType}}</td><td>{{if .WavelengthNM}}{{.WavelengthNM}}nm{{end}}</td><td>{{if .BitrateMbps}}{{.BitrateMbps}}Mbps{{end}}</td><td>{{.Connector}}</td><td class="num">{{.Profiles}}</td><td class="num">{{.Serials}}</td></tr>
+{{- end}}
+</table>
+{{- if .InvalidChecksums}}
+<h2>Invalid Checksums</h2>
+<table>
+<tr><th>Hash</th><th>Vendor</th><th>Part Number</th><th>Serial</th></tr>
+{{- range .InvalidChecksums}}
+<tr><td>{{short .ContentHash}}</td><td>{{.Identity.VendorName}}</td><td>{{.Identity.PartNumber}}</td><td>{{.Identity.SerialNumber}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+</body>
+</html>
+`))
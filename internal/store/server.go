@@ -0,0 +1,234 @@
+package store
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxUploadSize bounds a profile upload (metadata plus dumps).
+const maxUploadSize = 16 << 20
+
+// ProfileList is the response of the profile listing endpoint.
+type ProfileList struct {
+	Time     time.Time        `json:"time"` // Server time, for the next incremental pull
+	Profiles []ProfileSummary `json:"profiles"`
+}
+
+// ProfileSummary identifies a profile and when it last changed.
+type ProfileSummary struct {
+	Hash      string    `json:"hash"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ProfileUpload is the body of a profile PUT. Profile is only needed when
+// the server does not have the profile yet, and Blobs only for variants the
+// server is missing.
+type ProfileUpload struct {
+	Metadata *Metadata `json:"metadata"`
+	Profile  []byte    `json:"profile,omitempty"`
+	Blobs    [][]byte  `json:"blobs,omitempty"`
+}
+
+// PutResult is the response of a profile PUT.
+type PutResult struct {
+	New       bool     `json:"new"`
+	Changed   bool     `json:"changed"`
+	Conflicts []string `json:"conflicts,omitempty"`
+}
+
+// Handler serves the store over HTTP:
+//
+//	GET /api/v1/profiles[?since=RFC3339]       list hashes with UpdatedAt
+//	GET /api/v1/profiles/{hash}                primary dump
+//	GET /api/v1/profiles/{hash}/metadata       metadata JSON
+//	GET /api/v1/profiles/{hash}/blobs/{blob}   variant dump
+//	PUT /api/v1/profiles/{hash}                merge a ProfileUpload
+//
+// Hashes are hex digests without the "sha256:" prefix. If token is set,
+// requests must carry it as a bearer token.
+func (s *Store) Handler(token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/profiles", s.handleList)
+	mux.HandleFunc("GET /api/v1/profiles/{hash}", s.handleProfile)
+	mux.HandleFunc("GET /api/v1/profiles/{hash}/metadata", s.handleMetadata)
+	mux.HandleFunc("GET /api/v1/profiles/{hash}/blobs/{blob}", s.handleBlob)
+	mux.HandleFunc("PUT /api/v1/profiles/{hash}", s.handlePut)
+
+	if token == "" {
+		return mux
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(got, []byte("Bearer "+token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// pathHash returns the full hash named by a path wildcard.
+func pathHash(r *http.Request, name string) (string, bool) {
+	hex := r.PathValue(name)
+	if len(hex) != 64 || strings.Trim(hex, "0123456789abcdef") != "" {
+		return "", false
+	}
+	return "sha256:" + hex, true
+}
+
+func (s *Store) handleList(w http.ResponseWriter, r *http.Request) {
+	var since time.Time
+	if v := r.URL.Query().Get("since"); v != "" {
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			http.Error(w, "invalid since: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		since = t
+	}
+
+	list := ProfileList{Time: time.Now()}
+	metas, err := s.AllMetadata()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, m := range metas {
+		if !since.IsZero() && !m.UpdatedAt.After(since) {
+			continue
+		}
+		list.Profiles = append(list.Profiles, ProfileSummary{Hash: m.ContentHash, UpdatedAt: m.UpdatedAt})
+	}
+	sort.Slice(list.Profiles, func(i, j int) bool { return list.Profiles[i].UpdatedAt.Before(list.Profiles[j].UpdatedAt) })
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (s *Store) handleProfile(w http.ResponseWriter, r *http.Request) {
+	hash, ok := pathHash(r, "hash")
+	if !ok {
+		http.Error(w, "invalid hash", http.StatusBadRequest)
+		return
+	}
+	data, err := s.Get(hash)
+	if os.IsNotExist(err) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(data)
+}
+
+func (s *Store) handleMetadata(w http.ResponseWriter, r *http.Request) {
+	hash, ok := pathHash(r, "hash")
+	if !ok {
+		http.Error(w, "invalid hash", http.StatusBadRequest)
+		return
+	}
+	meta, err := s.GetMetadata(hash)
+	if os.IsNotExist(err) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, meta)
+}
+
+func (s *Store) handleBlob(w http.ResponseWriter, r *http.Request) {
+	hash, ok := pathHash(r, "hash")
+	blob, blobOK := pathHash(r, "blob")
+	if !ok || !blobOK {
+		http.Error(w, "invalid hash", http.StatusBadRequest)
+		return
+	}
+	data, err := os.ReadFile(s.blobPath(hash, blob))
+	if os.IsNotExist(err) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(data)
+}
+
+func (s *Store) handlePut(w http.ResponseWriter, r *http.Request) {
+	hash, ok := pathHash(r, "hash")
+	if !ok {
+		http.Error(w, "invalid hash", http.StatusBadRequest)
+		return
+	}
+	var up ProfileUpload
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxUploadSize)).Decode(&up); err != nil {
+		http.Error(w, "invalid upload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.verifyUpload(hash, &up); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	isNew, changed, conflicts, err := s.MergeProfile(up.Metadata, up.Profile, up.Blobs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	status := http.StatusOK
+	if isNew {
+		status = http.StatusCreated
+	}
+	writeJSON(w, status, PutResult{New: isNew, Changed: changed, Conflicts: conflicts})
+}
+
+// verifyUpload checks an upload against its hash and makes sure every
+// variant's data is either uploaded or already stored.
+func (s *Store) verifyUpload(hash string, up *ProfileUpload) error {
+	if up.Metadata == nil || up.Metadata.ContentHash != hash {
+		return fmt.Errorf("metadata does not match %s", ShortHash(hash))
+	}
+
+	if up.Profile != nil {
+		if actual, err := ContentHash(up.Profile); err != nil || actual != hash {
+			return fmt.Errorf("profile data does not match %s", ShortHash(hash))
+		}
+	} else if _, err := os.Stat(s.profilePath(hash)); err != nil {
+		return fmt.Errorf("profile %s is not stored here; upload its data", ShortHash(hash))
+	}
+
+	uploaded := make(map[string]bool)
+	for _, data := range up.Blobs {
+		if actual, err := ContentHash(data); err != nil || actual != hash {
+			return fmt.Errorf("variant %s belongs to another profile", ShortHash(BlobHash(data)))
+		}
+		uploaded[BlobHash(data)] = true
+	}
+	for _, v := range up.Metadata.Variants {
+		if uploaded[v.Hash] {
+			continue
+		}
+		if _, err := os.Stat(s.blobPath(hash, v.Hash)); err != nil {
+			return fmt.Errorf("variant %s is not stored here; upload its data", ShortHash(v.Hash))
+		}
+	}
+	return nil
+}
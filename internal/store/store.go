@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
+	"strings"
 	"time"
 )
 
@@ -14,23 +16,72 @@ type Store struct {
 	baseDir      string
 	profilesDir  string
 	metadataDir  string
+	blobsDir     string
 	indexPath    string
 }
 
+// indexVersion is bumped whenever IndexEntry gains fields; older indexes
+// are rebuilt from metadata on load.
+const indexVersion = 3
+
 // Index contains quick lookup information for all profiles.
 type Index struct {
-	Profiles map[string]IndexEntry `json:"profiles"` // hash -> entry
-	UpdatedAt time.Time            `json:"updated_at"`
+	Version   int                   `json:"version,omitempty"`
+	Profiles  map[string]IndexEntry `json:"profiles"` // hash -> entry
+	UpdatedAt time.Time             `json:"updated_at"`
 }
 
-// IndexEntry contains summary info for quick listing.
+// IndexEntry contains summary info for quick listing and searching.
 type IndexEntry struct {
-	VendorName   string    `json:"vendor_name"`
-	PartNumber   string    `json:"part_number"`
-	SerialNumber string    `json:"serial_number"`
-	ModuleType   string    `json:"module_type"`
-	WavelengthNM int       `json:"wavelength_nm,omitempty"`
-	CreatedAt    time.Time `json:"created_at"`
+	VendorName    string    `json:"vendor_name"`
+	PartNumber    string    `json:"part_number"`
+	SerialNumber  string    `json:"serial_number"`
+	ModuleType    string    `json:"module_type"`
+	WavelengthNM  int       `json:"wavelength_nm,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	VendorOUI     string    `json:"vendor_oui,omitempty"`
+	Revision      string    `json:"revision,omitempty"`
+	DateCode      string    `json:"date_code,omitempty"`
+	BitrateMbps   int       `json:"bitrate_mbps,omitempty"`
+	ConnectorType string    `json:"connector_type,omitempty"`
+	Compliance    []string  `json:"compliance,omitempty"`
+	ChecksumValid bool      `json:"checksum_valid,omitempty"`
+	Methods       []string  `json:"methods,omitempty"` // Distinct source methods
+	Devices       []string  `json:"devices,omitempty"` // Distinct source device MACs
+	Tags          []string  `json:"tags,omitempty"`
+	Notes         []string  `json:"notes,omitempty"`
+}
+
+// newIndexEntry summarises profile metadata for the index.
+func newIndexEntry(meta *Metadata) IndexEntry {
+	e := IndexEntry{
+		VendorName:    meta.Identity.VendorName,
+		PartNumber:    meta.Identity.PartNumber,
+		SerialNumber:  meta.Identity.SerialNumber,
+		ModuleType:    meta.ModuleType,
+		WavelengthNM:  meta.Specs.WavelengthNM,
+		CreatedAt:     meta.CreatedAt,
+		VendorOUI:     meta.Identity.VendorOUI,
+		Revision:      meta.Identity.Revision,
+		DateCode:      meta.Identity.DateCode,
+		BitrateMbps:   meta.Specs.BitrateMbps,
+		ConnectorType: meta.Specs.ConnectorType,
+		Compliance:    meta.Compliance,
+		ChecksumValid: meta.Checksums.Valid,
+		Tags:          meta.Tags,
+	}
+	for _, n := range meta.Notes {
+		e.Notes = append(e.Notes, n.Text)
+	}
+	for _, src := range meta.Sources {
+		if src.Method != "" && !slices.Contains(e.Methods, src.Method) {
+			e.Methods = append(e.Methods, src.Method)
+		}
+		if src.DeviceMAC != "" && !slices.Contains(e.Devices, src.DeviceMAC) {
+			e.Devices = append(e.Devices, src.DeviceMAC)
+		}
+	}
+	return e
 }
 
 // DefaultPath returns the default store path (~/.local/share/sfpw-tools/store).
@@ -48,6 +99,7 @@ func Open(path string) (*Store, error) {
 		baseDir:     path,
 		profilesDir: filepath.Join(path, "profiles"),
 		metadataDir: filepath.Join(path, "metadata"),
+		blobsDir:    filepath.Join(path, "blobs"),
 		indexPath:   filepath.Join(path, "index.json"),
 	}
 
@@ -58,6 +110,14 @@ func Open(path string) (*Store, error) {
 	if err := os.MkdirAll(s.metadataDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create metadata dir: %w", err)
 	}
+	if err := os.MkdirAll(s.blobsDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create blobs dir: %w", err)
+	}
+
+	// Finish or roll back an import interrupted by a crash
+	if err := s.recoverJournal(); err != nil {
+		return nil, fmt.Errorf("failed to recover store: %w", err)
+	}
 
 	return s, nil
 }
@@ -72,13 +132,21 @@ func OpenDefault() (*Store, error) {
 }
 
 // Import adds a profile to the store.
-// If the profile already exists (same hash), it updates sources.
+// If the profile already exists (same hash), it updates sources. The exact
+// data is kept as a variant blob and linked from the source.
 // Returns the hash and whether it was a new profile.
 func (s *Store) Import(data []byte, source Source) (string, bool, error) {
 	hash, err := ContentHash(data)
 	if err != nil {
 		return "", false, err
 	}
+	source.Blob = BlobHash(data)
+
+	unlock, err := s.lock()
+	if err != nil {
+		return "", false, err
+	}
+	defer unlock()
 
 	profilePath := filepath.Join(s.profilesDir, hashToFilename(hash)+".bin")
 	metaPath := filepath.Join(s.metadataDir, hashToFilename(hash)+".json")
@@ -86,6 +154,7 @@ func (s *Store) Import(data []byte, source Source) (string, bool, error) {
 	// Check if profile already exists
 	isNew := false
 	var meta *Metadata
+	j := &journal{Hash: hash}
 
 	if _, err := os.Stat(metaPath); os.IsNotExist(err) {
 		// New profile
@@ -101,11 +170,7 @@ func (s *Store) Import(data []byte, source Source) (string, bool, error) {
 			}
 		}
 		meta.Sources = []Source{source}
-
-		// Write profile data
-		if err := os.WriteFile(profilePath, data, 0644); err != nil {
-			return "", false, fmt.Errorf("failed to write profile: %w", err)
-		}
+		j.Profile = data
 	} else {
 		// Existing profile - load and update sources
 		metaData, err := os.ReadFile(metaPath)
@@ -118,23 +183,147 @@ func (s *Store) Import(data []byte, source Source) (string, bool, error) {
 		}
 		meta.Sources = append(meta.Sources, source)
 		meta.UpdatedAt = time.Now()
+
+		// Profiles from before variants were tracked: the primary dump
+		// becomes the first variant
+		if len(meta.Variants) == 0 {
+			primary, err := os.ReadFile(profilePath)
+			if err != nil {
+				return "", false, fmt.Errorf("failed to read profile: %w", err)
+			}
+			addVariant(meta, primary, meta.CreatedAt)
+			j.Blobs = append(j.Blobs, primary)
+		}
 	}
 
-	// Write metadata
-	metaJSON, err := json.MarshalIndent(meta, "", "  ")
+	if addVariant(meta, data, source.Timestamp) {
+		j.Blobs = append(j.Blobs, data)
+	}
+	j.Metadata = meta
+
+	if err := s.commit(j); err != nil {
+		return "", false, err
+	}
+	return hash, isNew, nil
+}
+
+// UpdateMetadata applies fn to a profile's metadata and saves the result
+// along with its index entry. Nothing is written if fn returns an error.
+func (s *Store) UpdateMetadata(hash string, fn func(meta *Metadata) error) error {
+	unlock, err := s.lock()
 	if err != nil {
-		return "", false, fmt.Errorf("failed to marshal metadata: %w", err)
+		return err
 	}
-	if err := os.WriteFile(metaPath, metaJSON, 0644); err != nil {
-		return "", false, fmt.Errorf("failed to write metadata: %w", err)
+	defer unlock()
+
+	meta, err := s.GetMetadata(hash)
+	if err != nil {
+		return fmt.Errorf("failed to read metadata: %w", err)
 	}
+	if err := fn(meta); err != nil {
+		return err
+	}
+	meta.UpdatedAt = time.Now()
+	return s.commit(&journal{Hash: hash, Metadata: meta})
+}
 
-	// Update index
-	if err := s.updateIndex(hash, meta); err != nil {
-		return "", false, fmt.Errorf("failed to update index: %w", err)
+// MergeProfile adds a profile copied from another store, or merges its
+// sources, variants, tags and notes into the local copy. The caller must
+// have verified profile and blobs against meta. Returns whether the profile
+// was new, whether anything changed, and any conflicts found.
+func (s *Store) MergeProfile(meta *Metadata, profile []byte, blobs [][]byte) (bool, bool, []string, error) {
+	hash := meta.ContentHash
+	unlock, err := s.lock()
+	if err != nil {
+		return false, false, nil, err
 	}
+	defer unlock()
 
-	return hash, isNew, nil
+	local, err := s.GetMetadata(hash)
+	if os.IsNotExist(err) {
+		// UpdatedAt is when this store changed, not the sender, so that
+		// incremental pulls from here pick the profile up
+		received := *meta
+		received.UpdatedAt = time.Now()
+		err := s.commit(&journal{Hash: hash, Profile: profile, Blobs: blobs, Metadata: &received})
+		return true, true, nil, err
+	}
+	if err != nil {
+		return false, false, nil, fmt.Errorf("failed to read metadata: %w", err)
+	}
+
+	j := &journal{Hash: hash, Metadata: local}
+	if len(local.Variants) == 0 {
+		primary, err := s.Get(hash)
+		if err != nil {
+			return false, false, nil, fmt.Errorf("failed to read profile: %w", err)
+		}
+		addVariant(local, primary, local.CreatedAt)
+		j.Blobs = append(j.Blobs, primary)
+	}
+
+	changed, conflicts := local.Merge(meta)
+	if !changed {
+		return false, false, conflicts, nil
+	}
+	for _, data := range blobs {
+		if _, err := os.Stat(s.blobPath(hash, BlobHash(data))); os.IsNotExist(err) {
+			j.Blobs = append(j.Blobs, data)
+		}
+	}
+	local.UpdatedAt = time.Now()
+	return false, true, conflicts, s.commit(j)
+}
+
+// addVariant records data as a variant of the profile, reporting whether it
+// is new. The blob itself is written when the import is committed.
+func addVariant(meta *Metadata, data []byte, seen time.Time) bool {
+	blob := BlobHash(data)
+	for _, v := range meta.Variants {
+		if v.Hash == blob {
+			return false
+		}
+	}
+	if seen.IsZero() {
+		seen = time.Now()
+	}
+	meta.Variants = append(meta.Variants, Variant{Hash: blob, Size: len(data), FirstSeen: seen})
+	return true
+}
+
+func (s *Store) blobPath(hash, blob string) string {
+	return filepath.Join(s.blobsDir, hashToFilename(hash), hashToFilename(blob)+".bin")
+}
+
+// GetVariant retrieves the exact data of a profile variant by full hash or
+// hash prefix. Returns the data and the variant's full hash.
+func (s *Store) GetVariant(hash, ref string) ([]byte, string, error) {
+	meta, err := s.GetMetadata(hash)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(meta.Variants) == 0 {
+		// Only the primary dump exists
+		data, err := s.Get(hash)
+		if err != nil {
+			return nil, "", err
+		}
+		blob := BlobHash(data)
+		if !strings.HasPrefix(hashToFilename(blob), strings.TrimPrefix(ref, "sha256:")) {
+			return nil, "", fmt.Errorf("variant not found: %s", ref)
+		}
+		return data, blob, nil
+	}
+
+	v, err := meta.ResolveVariant(ref)
+	if err != nil {
+		return nil, "", err
+	}
+	data, err := os.ReadFile(s.blobPath(hash, v.Hash))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read variant: %w", err)
+	}
+	return data, v.Hash, nil
 }
 
 // Get retrieves profile data by hash.
@@ -189,6 +378,60 @@ func (s *Store) ListWithHashes() (map[string]IndexEntry, error) {
 	return index.Profiles, nil
 }
 
+// AllMetadata loads the metadata of every profile, ordered by hash.
+func (s *Store) AllMetadata() ([]*Metadata, error) {
+	index, err := s.loadIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([]string, 0, len(index.Profiles))
+	for hash := range index.Profiles {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+
+	metas := make([]*Metadata, 0, len(hashes))
+	for _, hash := range hashes {
+		meta, err := s.GetMetadata(hash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read metadata for %s: %w", ShortHash(hash), err)
+		}
+		metas = append(metas, meta)
+	}
+	return metas, nil
+}
+
+// Find returns the profiles whose index entries match the query.
+func (s *Store) Find(q *Query) (map[string]IndexEntry, error) {
+	index, err := s.loadIndex()
+	if err != nil {
+		return nil, err
+	}
+	matches := make(map[string]IndexEntry)
+	for hash, entry := range index.Profiles {
+		if q.Match(hash, entry) {
+			matches[hash] = entry
+		}
+	}
+	return matches, nil
+}
+
+// Resolve finds the full hash of a profile given a full hash, short hash,
+// or hex digest without the "sha256:" prefix.
+func (s *Store) Resolve(ref string) (string, error) {
+	index, err := s.loadIndex()
+	if err != nil {
+		return "", err
+	}
+	for hash := range index.Profiles {
+		if hash == ref || ShortHash(hash) == ref || hashToFilename(hash) == ref {
+			return hash, nil
+		}
+	}
+	return "", fmt.Errorf("profile not found: %s", ref)
+}
+
 // Export writes a profile to a file.
 func (s *Store) Export(hash, destPath string) error {
 	data, err := s.Get(hash)
@@ -207,10 +450,13 @@ func (s *Store) Count() (int, error) {
 	return len(index.Profiles), nil
 }
 
+// loadIndex reads the index. A missing or outdated index is rebuilt in
+// memory only: readers do not hold the store lock, so the rebuilt index is
+// saved by the next write (or store reindex) instead.
 func (s *Store) loadIndex() (*Index, error) {
 	data, err := os.ReadFile(s.indexPath)
 	if os.IsNotExist(err) {
-		return &Index{Profiles: make(map[string]IndexEntry)}, nil
+		return s.buildIndex()
 	}
 	if err != nil {
 		return nil, err
@@ -220,33 +466,69 @@ func (s *Store) loadIndex() (*Index, error) {
 	if err := json.Unmarshal(data, &index); err != nil {
 		return nil, err
 	}
+	if index.Version < indexVersion {
+		return s.buildIndex()
+	}
 	if index.Profiles == nil {
 		index.Profiles = make(map[string]IndexEntry)
 	}
 	return &index, nil
 }
 
-func (s *Store) updateIndex(hash string, meta *Metadata) error {
-	index, err := s.loadIndex()
+// rebuildIndex regenerates the index from the metadata files and saves it.
+// The caller must hold the store lock.
+func (s *Store) rebuildIndex() (*Index, error) {
+	index, err := s.buildIndex()
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if err := s.saveIndex(index); err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+// buildIndex generates the index from the metadata files.
+func (s *Store) buildIndex() (*Index, error) {
+	files, err := filepath.Glob(filepath.Join(s.metadataDir, "*.json"))
+	if err != nil {
+		return nil, err
 	}
 
-	index.Profiles[hash] = IndexEntry{
-		VendorName:   meta.Identity.VendorName,
-		PartNumber:   meta.Identity.PartNumber,
-		SerialNumber: meta.Identity.SerialNumber,
-		ModuleType:   meta.ModuleType,
-		WavelengthNM: meta.Specs.WavelengthNM,
-		CreatedAt:    meta.CreatedAt,
+	index := &Index{Version: indexVersion, Profiles: make(map[string]IndexEntry)}
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		var meta Metadata
+		if err := json.Unmarshal(data, &meta); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", filepath.Base(f), err)
+		}
+		index.Profiles[meta.ContentHash] = newIndexEntry(&meta)
 	}
+	return index, nil
+}
+
+func (s *Store) saveIndex(index *Index) error {
+	index.Version = indexVersion
 	index.UpdatedAt = time.Now()
 
 	data, err := json.MarshalIndent(index, "", "  ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(s.indexPath, data, 0644)
+	return writeFileAtomic(s.indexPath, data, 0644)
+}
+
+func (s *Store) updateIndex(hash string, meta *Metadata) error {
+	index, err := s.loadIndex()
+	if err != nil {
+		return err
+	}
+
+	index.Profiles[hash] = newIndexEntry(meta)
+	return s.saveIndex(index)
 }
 
 // hashToFilename converts a full hash to a safe filename.
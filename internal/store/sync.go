@@ -0,0 +1,332 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// syncOverlap is subtracted from the last sync time so changes that raced
+// with the previous sync are picked up again. Merging is idempotent.
+const syncOverlap = time.Minute
+
+// errNotFound is returned by Remote for a missing profile or blob.
+var errNotFound = errors.New("not found")
+
+// Remote is a client for a store served with Handler.
+type Remote struct {
+	URL   string
+	Token string
+	http  *http.Client
+}
+
+// NewRemote creates a client for the store server at baseURL.
+func NewRemote(baseURL, token string) *Remote {
+	return &Remote{
+		URL:   strings.TrimSuffix(baseURL, "/"),
+		Token: token,
+		http:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (r *Remote) do(method, path string, body io.Reader) ([]byte, error) {
+	req, err := http.NewRequest(method, r.URL+"/api/v1/"+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if r.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.Token)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := r.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errNotFound
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
+
+// List returns the remote profiles changed after since (all if zero).
+func (r *Remote) List(since time.Time) (*ProfileList, error) {
+	path := "profiles"
+	if !since.IsZero() {
+		path += "?since=" + url.QueryEscape(since.Format(time.RFC3339Nano))
+	}
+	data, err := r.do("GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	var list ProfileList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("invalid profile list: %w", err)
+	}
+	return &list, nil
+}
+
+// Metadata fetches a profile's metadata, or nil if the remote lacks it.
+func (r *Remote) Metadata(hash string) (*Metadata, error) {
+	data, err := r.do("GET", "profiles/"+hashToFilename(hash)+"/metadata", nil)
+	if err == errNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var meta Metadata
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("invalid metadata: %w", err)
+	}
+	return &meta, nil
+}
+
+// Profile fetches a profile's primary dump.
+func (r *Remote) Profile(hash string) ([]byte, error) {
+	return r.do("GET", "profiles/"+hashToFilename(hash), nil)
+}
+
+// Blob fetches a variant dump.
+func (r *Remote) Blob(hash, blob string) ([]byte, error) {
+	return r.do("GET", "profiles/"+hashToFilename(hash)+"/blobs/"+hashToFilename(blob), nil)
+}
+
+// Put uploads a profile for the remote to merge.
+func (r *Remote) Put(up *ProfileUpload) (*PutResult, error) {
+	body, err := json.Marshal(up)
+	if err != nil {
+		return nil, err
+	}
+	data, err := r.do("PUT", "profiles/"+hashToFilename(up.Metadata.ContentHash), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	var result PutResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	return &result, nil
+}
+
+// SyncResult summarises a push or pull.
+type SyncResult struct {
+	New       []string            // Profiles the receiving side did not have
+	Merged    []string            // Profiles the receiving side gained data for
+	Unchanged int                 // Profiles already up to date
+	Conflicts map[string][]string // Conflicts per hash
+}
+
+func (r *SyncResult) record(hash string, isNew, changed bool, conflicts []string) {
+	switch {
+	case isNew:
+		r.New = append(r.New, hash)
+	case changed:
+		r.Merged = append(r.Merged, hash)
+	default:
+		r.Unchanged++
+	}
+	if len(conflicts) > 0 {
+		r.Conflicts[hash] = conflicts
+	}
+}
+
+// syncState records the last successful push and pull per remote URL.
+type syncState struct {
+	Remotes map[string]*remoteState `json:"remotes"`
+}
+
+type remoteState struct {
+	LastPush time.Time `json:"last_push,omitempty"` // Local clock
+	LastPull time.Time `json:"last_pull,omitempty"` // Remote clock
+}
+
+func (s *Store) syncStatePath() string {
+	return filepath.Join(s.baseDir, "sync.json")
+}
+
+// remoteState returns the sync state for a remote.
+func (s *Store) remoteState(r *Remote) (*remoteState, error) {
+	state, err := s.loadSyncState()
+	if err != nil {
+		return nil, err
+	}
+	if rs, ok := state.Remotes[r.URL]; ok {
+		return rs, nil
+	}
+	return &remoteState{}, nil
+}
+
+func (s *Store) loadSyncState() (*syncState, error) {
+	state := &syncState{Remotes: make(map[string]*remoteState)}
+	data, err := os.ReadFile(s.syncStatePath())
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("invalid sync state: %w", err)
+	}
+	if state.Remotes == nil {
+		state.Remotes = make(map[string]*remoteState)
+	}
+	return state, nil
+}
+
+// updateSyncState applies fn to a remote's sync state and saves it.
+func (s *Store) updateSyncState(r *Remote, fn func(rs *remoteState)) error {
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	state, err := s.loadSyncState()
+	if err != nil {
+		return err
+	}
+	rs, ok := state.Remotes[r.URL]
+	if !ok {
+		rs = &remoteState{}
+		state.Remotes[r.URL] = rs
+	}
+	fn(rs)
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.syncStatePath(), data, 0644)
+}
+
+// Push uploads local profiles changed since the last push to the remote
+// (all of them with full). Only data the remote lacks is sent.
+func (s *Store) Push(r *Remote, full bool) (*SyncResult, error) {
+	rs, err := s.remoteState(r)
+	if err != nil {
+		return nil, err
+	}
+	since := rs.LastPush.Add(-syncOverlap)
+	if full || rs.LastPush.IsZero() {
+		since = time.Time{}
+	}
+	started := time.Now()
+
+	metas, err := s.AllMetadata()
+	if err != nil {
+		return nil, err
+	}
+	result := &SyncResult{Conflicts: make(map[string][]string)}
+	for _, m := range metas {
+		if !since.IsZero() && !m.UpdatedAt.After(since) {
+			continue
+		}
+		hash := m.ContentHash
+		local, err := s.loadBundleProfile(hash)
+		if err != nil {
+			return result, fmt.Errorf("%s: %w", ShortHash(hash), err)
+		}
+		remote, err := r.Metadata(hash)
+		if err != nil {
+			return result, fmt.Errorf("%s: %w", ShortHash(hash), err)
+		}
+
+		up := &ProfileUpload{Metadata: local.meta}
+		if remote == nil {
+			up.Profile = local.profile
+		}
+		for _, v := range local.meta.Variants {
+			if remote == nil || !remote.hasVariant(v.Hash) {
+				up.Blobs = append(up.Blobs, local.blobs[v.Hash])
+			}
+		}
+
+		put, err := r.Put(up)
+		if err != nil {
+			return result, fmt.Errorf("%s: %w", ShortHash(hash), err)
+		}
+		result.record(hash, put.New, put.Changed, put.Conflicts)
+	}
+
+	return result, s.updateSyncState(r, func(rs *remoteState) { rs.LastPush = started })
+}
+
+// Pull fetches remote profiles changed since the last pull (all of them
+// with full) and merges them into the store. Only data missing locally is
+// downloaded.
+func (s *Store) Pull(r *Remote, full bool) (*SyncResult, error) {
+	rs, err := s.remoteState(r)
+	if err != nil {
+		return nil, err
+	}
+	since := rs.LastPull.Add(-syncOverlap)
+	if full || rs.LastPull.IsZero() {
+		since = time.Time{}
+	}
+
+	list, err := r.List(since)
+	if err != nil {
+		return nil, err
+	}
+	result := &SyncResult{Conflicts: make(map[string][]string)}
+	for _, summary := range list.Profiles {
+		hash := summary.Hash
+		remote, err := r.Metadata(hash)
+		if err != nil || remote == nil {
+			return result, fmt.Errorf("%s: metadata unavailable: %v", ShortHash(hash), err)
+		}
+		local, _ := s.GetMetadata(hash)
+
+		var profile []byte
+		if local == nil {
+			if profile, err = r.Profile(hash); err != nil {
+				return result, fmt.Errorf("%s: %w", ShortHash(hash), err)
+			}
+		}
+		var blobs [][]byte
+		for _, v := range remote.Variants {
+			if local != nil && local.hasVariant(v.Hash) {
+				continue
+			}
+			data, err := r.Blob(hash, v.Hash)
+			if err != nil {
+				return result, fmt.Errorf("%s: variant %s: %w", ShortHash(hash), ShortHash(v.Hash), err)
+			}
+			blobs = append(blobs, data)
+		}
+
+		// Verify exactly as the server does for uploads
+		up := &ProfileUpload{Metadata: remote, Profile: profile, Blobs: blobs}
+		if err := s.verifyUpload(hash, up); err != nil {
+			return result, fmt.Errorf("%s: %w", ShortHash(hash), err)
+		}
+
+		isNew, changed, conflicts, err := s.MergeProfile(remote, profile, blobs)
+		if err != nil {
+			return result, fmt.Errorf("%s: %w", ShortHash(hash), err)
+		}
+		result.record(hash, isNew, changed, conflicts)
+	}
+
+	return result, s.updateSyncState(r, func(rs *remoteState) { rs.LastPull = list.Time })
+}
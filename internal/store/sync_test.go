@@ -0,0 +1,100 @@
+package store
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// testProfile returns an SFP dump whose identity depends on serial.
+func testProfile(serial string) []byte {
+	data := make([]byte, 512)
+	data[0] = 0x03
+	copy(data[20:36], "TESTVENDOR      ")
+	copy(data[40:56], "TEST-PN         ")
+	copy(data[68:84], serial)
+	return data
+}
+
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestPushPull(t *testing.T) {
+	server := openTestStore(t)
+	srv := httptest.NewServer(server.Handler("secret"))
+	defer srv.Close()
+
+	a, b := openTestStore(t), openTestStore(t)
+	remote := NewRemote(srv.URL, "secret")
+
+	// B's first pull sets its incremental pull time
+	if _, err := b.Pull(remote, false); err != nil {
+		t.Fatalf("initial pull: %v", err)
+	}
+
+	// A pushes a profile that was last changed well before B's pull
+	hash, _, err := a.Import(testProfile("SN0001"), Source{Method: "import", Timestamp: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta, err := a.GetMetadata(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta.UpdatedAt = time.Now().Add(-24 * time.Hour)
+	if err := a.commit(&journal{Hash: hash, Metadata: meta}); err != nil {
+		t.Fatal(err)
+	}
+	pushed, err := a.Push(remote, false)
+	if err != nil {
+		t.Fatalf("push: %v", err)
+	}
+	if len(pushed.New) != 1 {
+		t.Fatalf("push: got %d new profiles, want 1", len(pushed.New))
+	}
+
+	pulled, err := b.Pull(remote, false)
+	if err != nil {
+		t.Fatalf("pull: %v", err)
+	}
+	if len(pulled.New) != 1 || pulled.New[0] != hash {
+		t.Fatalf("pull: got new %v, want [%s]", pulled.New, hash)
+	}
+	got, err := b.GetMetadata(hash)
+	if err != nil {
+		t.Fatalf("pulled profile missing: %v", err)
+	}
+	if len(got.Variants) != 1 {
+		t.Errorf("pulled profile has %d variants, want 1", len(got.Variants))
+	}
+
+	// Nothing changed since, so the next pull is empty
+	again, err := b.Pull(remote, false)
+	if err != nil {
+		t.Fatalf("second pull: %v", err)
+	}
+	if len(again.New) != 0 || len(again.Merged) != 0 {
+		t.Errorf("second pull: got new %v merged %v, want none", again.New, again.Merged)
+	}
+}
+
+func TestHandlerToken(t *testing.T) {
+	srv := httptest.NewServer(openTestStore(t).Handler("secret"))
+	defer srv.Close()
+
+	if _, err := NewRemote(srv.URL, "wrong").List(time.Time{}); err == nil {
+		t.Error("list with wrong token succeeded")
+	}
+	if _, err := NewRemote(srv.URL, "").List(time.Time{}); err == nil {
+		t.Error("list without token succeeded")
+	}
+	if _, err := NewRemote(srv.URL, "secret").List(time.Time{}); err != nil {
+		t.Errorf("list with token: %v", err)
+	}
+}
@@ -1,6 +1,7 @@
 package tui
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -1500,6 +1501,19 @@ func (m Model) viewStoreDetail() string {
 			b.WriteString(m.renderField("Connector", meta.Specs.ConnectorType))
 		}
 		b.WriteString(m.renderField("Sources", fmt.Sprintf("%d", len(meta.Sources))))
+		if len(meta.Tags) > 0 {
+			b.WriteString(m.renderField("Tags", strings.Join(meta.Tags, ", ")))
+		}
+		if len(meta.Notes) > 0 {
+			b.WriteString("\n")
+			b.WriteString(m.styles.Highlight.Render("Notes"))
+			b.WriteString("\n")
+			for _, n := range meta.Notes {
+				b.WriteString(m.styles.Muted.Render("  " + n.CreatedAt.Format("2006-01-02") + "  "))
+				b.WriteString(n.Text)
+				b.WriteString("\n")
+			}
+		}
 	}
 
 	b.WriteString("\n")
@@ -1697,7 +1711,7 @@ func (m Model) viewFirmwareSelect() string {
 			fw.Downloaded.Format("2006-01-02 15:04"))
 
 		// Mark current version
-		if m.firmware != nil && fw.Version == "v"+m.firmware.FWVersion {
+		if m.firmware != nil && firmware.SameVersion(fw.Version, m.firmware.FWVersion) {
 			line += " (current)"
 		}
 
@@ -2033,15 +2047,32 @@ func flashFirmwareCmd(client *api.Client, path string) tea.Cmd {
 			return firmwareFlashCompleteMsg{err: fmt.Errorf("failed to read file: %w", err)}
 		}
 
-		// Use the client to update firmware (no progress callback for simplicity)
-		err = client.UpdateFirmware(data, nil)
+		// Refuse corrupt images, and anything the CLI would only flash with --force
+		img, err := firmware.ParseESP32ImageReader(bytes.NewReader(data))
+		if err != nil {
+			return firmwareFlashCompleteMsg{err: fmt.Errorf("invalid firmware image: %w", err)}
+		}
+		if err := img.Verify(); err != nil {
+			return firmwareFlashCompleteMsg{err: fmt.Errorf("corrupt firmware image: %w", err)}
+		}
+		current := ""
+		if status, err := client.GetFirmwareStatus(); err == nil {
+			current = status.FWVersion
+		}
+		if problems := img.FlashProblems(current); len(problems) > 0 {
+			return firmwareFlashCompleteMsg{err: fmt.Errorf("refusing to flash: %s (use sfpw fw update --force)", strings.Join(problems, "; "))}
+		}
+
+		// Use the client to update firmware (no progress callback for simplicity).
+		// It waits for the reboot and reconnects.
+		version, err := client.UpdateFirmware(data, nil)
 		if err != nil {
 			return firmwareFlashCompleteMsg{err: err}
 		}
 
 		return firmwareFlashCompleteMsg{
 			success: true,
-			message: "Firmware update complete! Device may reboot.",
+			message: fmt.Sprintf("Firmware update complete! Device runs v%s", strings.TrimPrefix(version, "v")),
 		}
 	}
 }
@@ -2056,10 +2087,13 @@ func syncFirmwareCacheCmd() tea.Cmd {
 		}
 
 		client := firmware.NewManifestClient()
-		versions, err := client.GetAvailable(firmware.DefaultSFPWizardFilter())
+		filter := firmware.DefaultSFPWizardFilter()
+		versions, err := client.GetAvailable(filter)
 		if err != nil {
 			return firmwareSyncCompleteMsg{err: fmt.Errorf("fetch error: %w", err)}
 		}
+		// Keep the manifest for offline use; failing to is not fatal
+		cache.SaveManifest(&firmware.SavedManifest{Fetched: time.Now(), URL: client.BaseURL(), Filter: filter, Versions: versions})
 
 		// Download missing versions
 		for i, v := range versions {
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
@@ -28,6 +29,11 @@ func main() {
 	err := ctx.Run(&c)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		// Errors such as failed firmware updates carry their own exit code
+		var exitErr interface{ ExitCode() int }
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.ExitCode())
+		}
 		os.Exit(1)
 	}
 }